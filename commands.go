@@ -0,0 +1,219 @@
+package main
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/services"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+)
+
+// cliServices 命令行子命令使用的仓库和服务，与HTTP服务共用同一套实现
+type cliServices struct {
+	userRepo    repositories.UserRepository
+	roleService services.RoleService
+	userService services.UserService
+}
+
+func newCLIServices() (*cliServices, error) {
+	if err := config.ConnectDatabase(); err != nil {
+		return nil, fmt.Errorf("数据库连接失败: %w", err)
+	}
+
+	userRepo := repositories.NewUserRepository()
+	roleRepo := repositories.NewRoleRepository()
+	hasher := services.NewPasswordHasher()
+
+	refreshTokenService := services.NewRefreshTokenService(repositories.NewRefreshTokenRepository(), repositories.NewSessionRepository(), config.AppConfig.RefreshTokenExpire)
+	revocations := services.NewTokenRevocationService(repositories.NewTokenRevocationRepository(), refreshTokenService)
+	auditService := services.NewAuditService(repositories.NewAuditRepository())
+	roleService := services.NewRoleService(roleRepo, userRepo, revocations, auditService)
+
+	if err := roleService.EnsureDefaultRoles(); err != nil {
+		return nil, err
+	}
+
+	return &cliServices{
+		userRepo:    userRepo,
+		roleService: roleService,
+		userService: services.NewUserService(userRepo, roleRepo, repositories.NewPasswordResetRepository(), roleService, hasher, revocations, services.NewLoginGuard(repositories.NewLoginAttemptRepository()), auditService),
+	}, nil
+}
+
+func runMigrate() error {
+	if _, err := newCLIServices(); err != nil {
+		return err
+	}
+
+	log.Println("数据库迁移完成，内置角色已就绪")
+	return nil
+}
+
+func runCreateAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := fs.String("username", "", "管理员用户名")
+	email := fs.String("email", "", "管理员邮箱")
+	fs.Parse(args)
+
+	if len(*username) < 3 || len(*username) > 50 {
+		return errors.New("用户名长度必须为3-50个字符")
+	}
+	if *email == "" {
+		return errors.New("邮箱不能为空")
+	}
+
+	// 密码不通过命令行参数传入，避免出现在 shell 历史和进程列表中
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		value, err := readPassword("请输入管理员密码: ")
+		if err != nil {
+			return err
+		}
+		password = value
+	}
+	if len(password) < 6 {
+		return errors.New("密码长度至少6个字符")
+	}
+
+	svc, err := newCLIServices()
+	if err != nil {
+		return err
+	}
+
+	user, err := svc.userService.CreateUser(cliActor(), *username, password, *email, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("管理员 %s 创建成功 (ID: %d)", user.Username, user.ID)
+	return nil
+}
+
+func runResetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	username := fs.String("username", "", "用户名")
+	fs.Parse(args)
+
+	if *username == "" {
+		return errors.New("用户名不能为空")
+	}
+
+	password, err := readPassword("请输入新密码: ")
+	if err != nil {
+		return err
+	}
+	if len(password) < 6 {
+		return errors.New("密码长度至少6个字符")
+	}
+
+	svc, err := newCLIServices()
+	if err != nil {
+		return err
+	}
+
+	user, err := svc.userRepo.FindByUsername(*username)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.userService.ResetPassword(cliActor(), user.ID, password); err != nil {
+		return err
+	}
+
+	log.Printf("用户 %s 的密码已重置，现有会话和 API 密钥已撤销", user.Username)
+	return nil
+}
+
+func runListUsers(args []string) error {
+	fs := flag.NewFlagSet("list-users", flag.ExitOnError)
+	role := fs.String("role", "", "按角色过滤")
+	fs.Parse(args)
+
+	svc, err := newCLIServices()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tSTATUS\tCREATED_AT")
+
+	// 按游标逐页读取，用户很多时也不会一次载入全表
+	filter := repositories.UserFilter{Role: models.UserRole(*role)}
+	spec := repositories.QuerySpec{PageSize: repositories.MaxPageSize}
+	for {
+		page, err := svc.userRepo.Find(filter, spec)
+		if err != nil {
+			return err
+		}
+		for _, user := range page.Items {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				user.ID, user.Username, user.Email, user.Role, user.Status,
+				user.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		spec.Cursor = page.NextCursor
+	}
+	return w.Flush()
+}
+
+// cliActor 命令行操作在审计日志中记录为 cli 加上执行命令的系统用户
+func cliActor() services.Actor {
+	name := "cli"
+	if user := os.Getenv("USER"); user != "" {
+		name += ":" + user
+	}
+	return services.SystemActor(name)
+}
+
+// readPassword 在终端中不回显地读取密码并要求再输入一次确认；标准输入不是终端时按行读取，便于脚本通过管道传入
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine(prompt)
+	}
+
+	password, err := readTerminalPassword(fd, prompt)
+	if err != nil {
+		return "", err
+	}
+	confirm, err := readTerminalPassword(fd, "请再次输入密码: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", errors.New("两次输入的密码不一致")
+	}
+	return password, nil
+}
+
+func readTerminalPassword(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取密码失败: %w", err)
+	}
+	return string(value), nil
+}
+
+// readLine 读取一行并只去掉行尾的换行符，密码首尾的空格与通过接口设置时一样保留
+func readLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("读取输入失败: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
@@ -1,23 +1,139 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultJWTSecret 未配置 JWT_SECRET 时使用的默认值，只允许在开发环境中使用
+const DefaultJWTSecret = "your-secret-key"
+
 type Config struct {
+	AppEnv     string // development、test 或 production
 	DBHost     string
 	DBPort     string
 	DBUser     string
 	DBPassword string
 	DBName     string
 	JWTSecret  string
-	JWTExpire  time.Duration
+	JWTExpire  time.Duration // 访问令牌有效期
 	ServerPort string
+
+	// 令牌签名密钥
+	JWTSigningAlgorithm     string   // HS256、RS256 或 EdDSA
+	JWTPrivateKeyFile       string   // RS256/EdDSA 的当前签名私钥（PEM）
+	JWTVerificationKeyFiles []string // 仍然有效的其他公钥（PEM），用于密钥轮换
+	JWTPreviousSecrets      []string // HS256 轮换前的旧密钥
+	JWTIssuer               string   // 令牌签发者（iss）
+	JWTAudience             []string // 令牌受众（aud），校验时至少匹配其中之一
+	JWTLeeway               time.Duration
+
+	RefreshTokenExpire   time.Duration
+	TokenRevocationStore string // memory 或 database
+
+	// 邮箱验证
+	AppBaseURL                string // 邮件中链接使用的对外地址
+	EmailVerificationRequired bool
+	EmailVerificationExpire   time.Duration
+	UnverifiedLoginPolicy     string // deny 拒绝登录；restrict 允许登录但不授予任何权限；allow 不限制
+
+	PasswordResetExpire time.Duration // 密码重置链接有效期
+
+	// 两步验证
+	TOTPIssuer               string        // 验证器应用中显示的发行方名称
+	Admin2FARequired         bool          // 拥有管理类权限（user:manage、role:manage、audit:read）的账户必须启用两步验证才能使用权限
+	TwoFactorChallengeExpire time.Duration // 密码校验通过后等待输入验证码的时限
+
+	// OpenID Connect 单点登录
+	OIDCEnabled       bool
+	OIDCIssuer        string // 身份提供方的 issuer 地址，用于自动发现端点
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string // 回调地址，需与身份提供方登记的一致
+	OIDCScopes        []string
+	OIDCGroupsClaim   string        // ID Token 中携带用户组的声明名称
+	OIDCRoleMapping   []RoleMapping // 用户组到角色的映射，按配置顺序取第一个匹配项
+	OIDCDefaultRole   string        // 没有匹配的用户组时新建账户使用的角色
+	OIDCAutoProvision bool          // 首次登录且邮箱未关联本地账户时自动创建账户
+
+	// 凭据校验
+	AuthBackends []string // 用户名密码登录依次尝试的后端：local、ldap
+
+	// LDAP 认证
+	LDAPURL                string // ldap://host:389 或 ldaps://host:636
+	LDAPStartTLS           bool
+	LDAPInsecureSkipVerify bool
+	LDAPBindDN             string // 用于查找用户的服务账号，留空时匿名查找
+	LDAPBindPassword       string
+	LDAPBaseDN             string
+	LDAPUserFilter         string // %s 会被替换为转义后的用户名
+	LDAPEmailAttribute     string
+	LDAPGroupBaseDN        string
+	LDAPGroupFilter        string // %s 会被替换为转义后的用户 DN
+	LDAPGroupAttribute     string // 用于角色映射的组名属性
+	LDAPRoleMapping        []RoleMapping
+	LDAPDefaultRole        string
+	LDAPTimeout            time.Duration
+
+	// 登录防护
+	LoginAttemptStore      string        // memory 或 database
+	LoginMaxFailedAttempts int           // 连续失败达到该次数后锁定账户，0 表示不锁定
+	LoginLockoutBase       time.Duration // 首次锁定时长，之后每次失败翻倍
+	LoginLockoutMax        time.Duration // 锁定时长上限
+	LoginIPMaxRequests     int           // 单个 IP 每个窗口内允许的登录请求数，0 表示不限制
+	RegisterIPMaxRequests  int           // 单个 IP 每个窗口内允许的注册请求数，0 表示不限制
+	IPThrottleWindow       time.Duration
+	MailIPMaxRequests      int // 单个 IP 每个窗口内允许的找回密码、重发验证邮件请求数，0 表示不限制
+	MailRecipientMax       int // 同一收件地址每个窗口内最多收到的找回密码、验证邮件数，0 表示不限制
+	MailRecipientWindow    time.Duration
+
+	// 邮件发送
+	MailerDriver string // log、file 或 smtp，非开发环境只允许 smtp
+	MailFrom     string
+	MailFilePath string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// 请求频率限制（令牌桶）
+	RateLimitStore   string    // 令牌桶存储，目前只支持 memory
+	RateLimitCatalog RateLimit // 公开的图书查询，RATE_LIMIT_CATALOG_ 开头的配置项
+	RateLimitSearch  RateLimit // 图书搜索，在图书查询之外单独计数，RATE_LIMIT_SEARCH_ 开头的配置项
+	RateLimitAuth    RateLimit // 需要认证的接口在校验凭据之前按 IP 计数，RATE_LIMIT_AUTH_ 开头的配置项
+	RateLimitAPI     RateLimit // 需要认证的接口，RATE_LIMIT_API_ 开头的配置项
+	TrustedProxies   []string  // 可信的反向代理地址，配置后只信任这些代理传入的 X-Forwarded-For
+
+	// 安全加固
+	HSTSMaxAge            time.Duration // Strict-Transport-Security 的 max-age，0 表示不发送
+	HSTSIncludeSubdomains bool
+	MaxRequestBodyBytes   int64 // 请求体大小上限
+	SwaggerEnabled        bool  // 是否提供 /swagger 接口文档，生产环境默认关闭
+
+	// 跨域访问策略，未单独配置的项沿用默认策略
+	CORS        CORSPolicy // 默认策略，CORS_ 开头的配置项
+	CatalogCORS CORSPolicy // 公开的图书、作者、分类和标签查询，不含需要认证的借还接口，CATALOG_CORS_ 开头的配置项
+	AdminCORS   CORSPolicy // 管理接口（/api/admin），ADMIN_CORS_ 开头的配置项
+
+	// 密码哈希
+	PasswordHashAlgorithm string // bcrypt 或 argon2id
+	BcryptCost            int
+	Argon2Memory          uint32 // KiB
+	Argon2Iterations      uint32
+	Argon2Parallelism     uint8
+	AllowLegacyPlaintext  bool // 是否接受迁移前遗留的明文密码，登录成功后会被重新哈希；迁移完成后应关闭
+}
+
+// RoleMapping 外部身份源（OIDC、LDAP）中的用户组与系统角色的对应关系
+type RoleMapping struct {
+	Group string
+	Role  string
 }
 
 var AppConfig *Config
@@ -28,18 +144,237 @@ func LoadConfig() {
 		log.Println("Warning: .env file not found")
 	}
 
-	jwtExpire, _ := strconv.Atoi(getEnv("JWT_EXPIRE_HOURS", "24"))
+	// 访问令牌默认只有15分钟，长期登录依赖刷新令牌；仍兼容旧的 JWT_EXPIRE_HOURS 配置
+	jwtExpire := time.Duration(getEnvInt("JWT_EXPIRE_MINUTES", 15)) * time.Minute
+	if _, exists := os.LookupEnv("JWT_EXPIRE_HOURS"); exists {
+		jwtExpire = time.Duration(getEnvInt("JWT_EXPIRE_HOURS", 24)) * time.Hour
+	}
+
+	jwtAudience := getEnvList("JWT_AUDIENCE")
+	if len(jwtAudience) == 0 {
+		jwtAudience = []string{"book-management-system"}
+	}
+
+	authBackends := getEnvList("AUTH_BACKENDS")
+	if len(authBackends) == 0 {
+		authBackends = []string{"local"}
+	}
+	ldapBaseDN := getEnv("LDAP_BASE_DN", "")
+
+	cors := loadCORSPolicy("CORS_", defaultCORSPolicy())
+
+	// 开发环境通常没有 HTTPS，默认不发送 HSTS，也默认开放接口文档
+	appEnv := getEnv("APP_ENV", "development")
+	development := (&Config{AppEnv: appEnv}).IsDevelopment()
+	hstsMaxAge := 180 * 24 * 60 * 60
+	if development {
+		hstsMaxAge = 0
+	}
+
+	oidcScopes := getEnvList("OIDC_SCOPES")
+	if len(oidcScopes) == 0 {
+		oidcScopes = []string{"openid", "profile", "email"}
+	}
 
 	AppConfig = &Config{
+		AppEnv:     appEnv,
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "3306"),
 		DBUser:     getEnv("DB_USER", "root"),
 		DBPassword: getEnv("DB_PASSWORD", "password"),
 		DBName:     getEnv("DB_NAME", "book_management"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key"),
-		JWTExpire:  time.Duration(jwtExpire) * time.Hour,
+		JWTSecret:  getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTExpire:  jwtExpire,
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+
+		JWTSigningAlgorithm:     getEnv("JWT_SIGNING_ALG", "HS256"),
+		JWTPrivateKeyFile:       getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTVerificationKeyFiles: getEnvList("JWT_VERIFICATION_KEY_FILES"),
+		JWTPreviousSecrets:      getEnvList("JWT_PREVIOUS_SECRETS"),
+		JWTIssuer:               getEnv("JWT_ISSUER", "book-management-system"),
+		JWTAudience:             jwtAudience,
+		JWTLeeway:               time.Duration(getEnvInt("JWT_LEEWAY_SECONDS", 30)) * time.Second,
+
+		RefreshTokenExpire:   time.Duration(getEnvInt("REFRESH_TOKEN_EXPIRE_HOURS", 24*30)) * time.Hour,
+		TokenRevocationStore: getEnv("TOKEN_REVOCATION_STORE", "memory"),
+
+		AppBaseURL:                getEnv("APP_BASE_URL", "http://localhost:8080"),
+		EmailVerificationRequired: getEnvBool("EMAIL_VERIFICATION_REQUIRED", true),
+		EmailVerificationExpire:   time.Duration(getEnvInt("EMAIL_VERIFICATION_EXPIRE_HOURS", 24)) * time.Hour,
+		UnverifiedLoginPolicy:     getEnv("UNVERIFIED_LOGIN_POLICY", "deny"),
+
+		PasswordResetExpire: time.Duration(getEnvInt("PASSWORD_RESET_EXPIRE_MINUTES", 30)) * time.Minute,
+
+		TOTPIssuer:               getEnv("TOTP_ISSUER", "BookManagementSystem"),
+		Admin2FARequired:         getEnvBool("ADMIN_2FA_REQUIRED", false),
+		TwoFactorChallengeExpire: time.Duration(getEnvInt("TWO_FACTOR_CHALLENGE_EXPIRE_MINUTES", 5)) * time.Minute,
+
+		OIDCEnabled:       getEnvBool("OIDC_ENABLED", false),
+		OIDCIssuer:        getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		OIDCScopes:        oidcScopes,
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:   parseRoleMapping("OIDC_ROLE_MAPPING"),
+		OIDCDefaultRole:   getEnv("OIDC_DEFAULT_ROLE", "user"),
+		OIDCAutoProvision: getEnvBool("OIDC_AUTO_PROVISION", true),
+
+		AuthBackends: authBackends,
+
+		LDAPURL:                getEnv("LDAP_URL", ""),
+		LDAPStartTLS:           getEnvBool("LDAP_START_TLS", false),
+		LDAPInsecureSkipVerify: getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+		LDAPBindDN:             getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:             ldapBaseDN,
+		LDAPUserFilter:         getEnv("LDAP_USER_FILTER", "(uid=%s)"),
+		LDAPEmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPGroupBaseDN:        getEnv("LDAP_GROUP_BASE_DN", ldapBaseDN),
+		LDAPGroupFilter:        getEnv("LDAP_GROUP_FILTER", "(member=%s)"),
+		LDAPGroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "cn"),
+		LDAPRoleMapping:        parseRoleMapping("LDAP_ROLE_MAPPING"),
+		LDAPDefaultRole:        getEnv("LDAP_DEFAULT_ROLE", "user"),
+		LDAPTimeout:            time.Duration(getEnvInt("LDAP_TIMEOUT_SECONDS", 5)) * time.Second,
+
+		LoginAttemptStore:      getEnv("LOGIN_ATTEMPT_STORE", "memory"),
+		LoginMaxFailedAttempts: getEnvInt("LOGIN_MAX_FAILED_ATTEMPTS", 5),
+		LoginLockoutBase:       time.Duration(getEnvInt("LOGIN_LOCKOUT_BASE_SECONDS", 60)) * time.Second,
+		LoginLockoutMax:        time.Duration(getEnvInt("LOGIN_LOCKOUT_MAX_MINUTES", 60)) * time.Minute,
+		LoginIPMaxRequests:     getEnvInt("LOGIN_IP_MAX_REQUESTS", 20),
+		RegisterIPMaxRequests:  getEnvInt("REGISTER_IP_MAX_REQUESTS", 5),
+		IPThrottleWindow:       time.Duration(getEnvInt("IP_THROTTLE_WINDOW_SECONDS", 60)) * time.Second,
+		MailIPMaxRequests:      getEnvInt("MAIL_IP_MAX_REQUESTS", 5),
+		MailRecipientMax:       getEnvInt("MAIL_RECIPIENT_MAX", 3),
+		MailRecipientWindow:    time.Duration(getEnvInt("MAIL_RECIPIENT_WINDOW_MINUTES", 60)) * time.Minute,
+
+		MailerDriver: getEnv("MAILER_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@example.com"),
+		MailFilePath: getEnv("MAIL_FILE_PATH", "mail.log"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		RateLimitStore:   getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitCatalog: loadRateLimit("RATE_LIMIT_CATALOG_", RateLimit{Requests: 120, Period: time.Minute, Burst: 60, Key: RateLimitKeyIP}),
+		RateLimitSearch:  loadRateLimit("RATE_LIMIT_SEARCH_", RateLimit{Requests: 30, Period: time.Minute, Burst: 10, Key: RateLimitKeyIP}),
+		RateLimitAuth:    loadRateLimit("RATE_LIMIT_AUTH_", RateLimit{Requests: 1200, Period: time.Minute, Burst: 240, Key: RateLimitKeyIP}),
+		RateLimitAPI:     loadRateLimit("RATE_LIMIT_API_", RateLimit{Requests: 600, Period: time.Minute, Burst: 120, Key: RateLimitKeyClient}),
+		TrustedProxies:   getEnvList("TRUSTED_PROXIES"),
+
+		HSTSMaxAge:            time.Duration(getEnvInt("HSTS_MAX_AGE_SECONDS", hstsMaxAge)) * time.Second,
+		HSTSIncludeSubdomains: getEnvBool("HSTS_INCLUDE_SUBDOMAINS", false),
+		MaxRequestBodyBytes:   int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20)),
+		SwaggerEnabled:        getEnvBool("SWAGGER_ENABLED", development),
+
+		CORS:        cors,
+		CatalogCORS: loadCORSPolicy("CATALOG_CORS_", cors),
+		AdminCORS:   loadCORSPolicy("ADMIN_CORS_", cors),
+
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+		BcryptCost:            getEnvInt("BCRYPT_COST", 12),
+		// 先限定取值范围再转换类型，避免负数或过大的值溢出成意料之外的参数
+		Argon2Memory:         uint32(getEnvIntInRange("ARGON2_MEMORY_KB", 64*1024, 8*1024, 4*1024*1024)),
+		Argon2Iterations:     uint32(getEnvIntInRange("ARGON2_ITERATIONS", 3, 1, 64)),
+		Argon2Parallelism:    uint8(getEnvIntInRange("ARGON2_PARALLELISM", 2, 1, 255)),
+		AllowLegacyPlaintext: getEnvBool("ALLOW_LEGACY_PLAINTEXT", false),
+	}
+}
+
+// IsDevelopment 是否运行在开发或测试环境
+func (c *Config) IsDevelopment() bool {
+	switch strings.ToLower(c.AppEnv) {
+	case "development", "dev", "local", "test":
+		return true
+	}
+	return false
+}
+
+// Validate 检查不安全的配置，非开发环境下拒绝使用默认的 JWT 密钥或非 SMTP 的邮件发送方式启动
+// 即使令牌改用非对称签名，邮件链接等签名令牌仍依赖 JWT_SECRET
+func (c *Config) Validate() error {
+	if c.OIDCEnabled && (c.OIDCIssuer == "" || c.OIDCClientID == "") {
+		return fmt.Errorf("启用 OIDC 时必须配置 OIDC_ISSUER 和 OIDC_CLIENT_ID")
+	}
+
+	for _, backend := range c.AuthBackends {
+		switch backend {
+		case "local":
+		case "ldap":
+			if c.LDAPURL == "" || c.LDAPBaseDN == "" {
+				return fmt.Errorf("AUTH_BACKENDS 包含 ldap 时必须配置 LDAP_URL 和 LDAP_BASE_DN")
+			}
+		default:
+			return fmt.Errorf("不支持的认证后端: %s", backend)
+		}
+	}
+
+	if c.MaxRequestBodyBytes <= 0 {
+		return fmt.Errorf("MAX_REQUEST_BODY_BYTES 必须大于0")
+	}
+
+	switch c.MailerDriver {
+	case "log", "file", "smtp":
+	default:
+		return fmt.Errorf("不支持的 MAILER_DRIVER: %s", c.MailerDriver)
+	}
+
+	// 拼错的算法名不能静默回退到 bcrypt，否则切换算法的配置看似生效实则没有
+	switch c.PasswordHashAlgorithm {
+	case "bcrypt", "argon2id":
+	default:
+		return fmt.Errorf("不支持的 PASSWORD_HASH_ALGORITHM: %s，可选值为 bcrypt 或 argon2id", c.PasswordHashAlgorithm)
+	}
+
+	if c.RateLimitStore != "memory" {
+		return fmt.Errorf("不支持的 RATE_LIMIT_STORE: %s", c.RateLimitStore)
+	}
+	if err := c.RateLimitCatalog.validate("RATE_LIMIT_CATALOG_"); err != nil {
+		return err
+	}
+	if err := c.RateLimitSearch.validate("RATE_LIMIT_SEARCH_"); err != nil {
+		return err
+	}
+	if err := c.RateLimitAuth.validate("RATE_LIMIT_AUTH_"); err != nil {
+		return err
+	}
+	if err := c.RateLimitAPI.validate("RATE_LIMIT_API_"); err != nil {
+		return err
+	}
+
+	if err := c.CORS.validate("CORS_"); err != nil {
+		return err
+	}
+	if err := c.CatalogCORS.validate("CATALOG_CORS_"); err != nil {
+		return err
+	}
+	if err := c.AdminCORS.validate("ADMIN_CORS_"); err != nil {
+		return err
+	}
+
+	if c.AllowLegacyPlaintext {
+		log.Println("Warning: ALLOW_LEGACY_PLAINTEXT 已开启，数据库中的明文密码可以直接登录，迁移完成后应关闭")
+	}
+
+	if c.IsDevelopment() {
+		if c.JWTSecret == DefaultJWTSecret {
+			log.Println("Warning: 正在使用默认的 JWT_SECRET，仅限开发环境")
+		}
+		return nil
 	}
+
+	if c.JWTSecret == "" || c.JWTSecret == DefaultJWTSecret {
+		return fmt.Errorf("APP_ENV=%s 时必须通过 JWT_SECRET 配置自定义密钥", c.AppEnv)
+	}
+	if len(c.JWTSecret) < 32 {
+		return fmt.Errorf("JWT_SECRET 长度至少为32个字符")
+	}
+	// log 和 file 会把密码重置和邮箱验证链接原样写入日志或文件，拿到日志就能接管账户
+	if c.MailerDriver != "smtp" {
+		return fmt.Errorf("APP_ENV=%s 时 MAILER_DRIVER 必须为 smtp，%s 会把重置和验证链接写入日志或文件", c.AppEnv, c.MailerDriver)
+	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -48,3 +383,74 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s: %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvIntInRange 读取整数配置，超出 [min, max] 的值截断到边界
+func getEnvIntInRange(key string, defaultValue, min, max int) int {
+	n := getEnvInt(key, defaultValue)
+	if n < min || n > max {
+		clamped := n
+		if clamped < min {
+			clamped = min
+		}
+		if clamped > max {
+			clamped = max
+		}
+		log.Printf("Warning: %s=%d is out of range [%d, %d], using %d", key, n, min, max, clamped)
+		return clamped
+	}
+	return n
+}
+
+// parseRoleMapping 解析形如 "library-staff=librarian,it-admins=admin" 的映射配置
+func parseRoleMapping(key string) []RoleMapping {
+	var mappings []RoleMapping
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		group, role, found := strings.Cut(item, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !found || group == "" || role == "" {
+			if strings.TrimSpace(item) != "" {
+				log.Printf("Warning: invalid %s entry %q, ignored", key, item)
+			}
+			continue
+		}
+		mappings = append(mappings, RoleMapping{Group: group, Role: role})
+	}
+	return mappings
+}
+
+// getEnvList 读取逗号分隔的列表，忽略空项
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s: %q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// CORSPolicy 跨域访问策略
+type CORSPolicy struct {
+	AllowedOrigins   []string // 精确的源（https://portal.example.edu）、子域名通配（https://*.example.edu）或 *
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // 预检结果的缓存时间
+}
+
+// defaultCORSPolicy 允许任意源以令牌方式访问，不携带 Cookie
+func defaultCORSPolicy() CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "Accept", "X-API-Key", "X-Request-ID", "X-Requested-With"},
+		ExposedHeaders: []string{"X-Request-ID", "Retry-After", "Content-Disposition",
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+		MaxAge: 10 * time.Minute,
+	}
+}
+
+// loadCORSPolicy 读取以 prefix 开头的配置项，未配置的项沿用 base
+func loadCORSPolicy(prefix string, base CORSPolicy) CORSPolicy {
+	policy := base
+	if _, ok := os.LookupEnv(prefix + "ALLOWED_ORIGINS"); ok {
+		policy.AllowedOrigins = getEnvList(prefix + "ALLOWED_ORIGINS")
+	}
+	if methods := getEnvList(prefix + "ALLOWED_METHODS"); len(methods) > 0 {
+		policy.AllowedMethods = methods
+	}
+	if headers := getEnvList(prefix + "ALLOWED_HEADERS"); len(headers) > 0 {
+		policy.AllowedHeaders = headers
+	}
+	if _, ok := os.LookupEnv(prefix + "EXPOSED_HEADERS"); ok {
+		policy.ExposedHeaders = getEnvList(prefix + "EXPOSED_HEADERS")
+	}
+	policy.AllowCredentials = getEnvBool(prefix+"ALLOW_CREDENTIALS", base.AllowCredentials)
+	policy.MaxAge = time.Duration(getEnvInt(prefix+"MAX_AGE_SECONDS", int(base.MaxAge/time.Second))) * time.Second
+	return policy
+}
+
+// validate 检查源的格式；允许携带凭据时浏览器不接受通配的源，必须逐个列出
+func (p CORSPolicy) validate(name string) error {
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			if p.AllowCredentials {
+				return fmt.Errorf("%sALLOW_CREDENTIALS=true 时 %sALLOWED_ORIGINS 不能包含 *", name, name)
+			}
+			continue
+		}
+
+		u, err := url.Parse(strings.Replace(origin, "*.", "wildcard.", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.User != nil {
+			return fmt.Errorf("%sALLOWED_ORIGINS 中的源 %q 无效，应为 https://host[:port] 或 https://*.domain", name, origin)
+		}
+		if strings.Contains(origin, "*") && !strings.HasPrefix(u.Host, "wildcard.") {
+			return fmt.Errorf("%sALLOWED_ORIGINS 中的源 %q 无效，通配符只能出现在主机名开头", name, origin)
+		}
+	}
+	return nil
+}
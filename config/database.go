@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"book-management-system/models"
 
@@ -30,7 +32,148 @@ func ConnectDatabase() error {
 	log.Println("Database connection established")
 
 	// 自动迁移
-	db.AutoMigrate(&models.User{}, &models.Book{}, &models.BorrowRecord{})
+	if err := MigrateDatabase(db); err != nil {
+		return err
+	}
 	log.Println("Database migrated successfully")
 	return nil
 }
+
+// MigrateDatabase 根据模型定义自动迁移表结构
+func MigrateDatabase(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.User{}, &models.Book{}, &models.BookCopy{}, &models.Author{}, &models.BookAuthor{},
+		&models.Category{}, &models.BookCategory{}, &models.Tag{}, &models.BookTag{}, &models.BorrowRecord{}, &models.RefreshToken{},
+		&models.RevokedToken{}, &models.UserTokenRevocation{},
+		&models.Role{}, &models.PasswordResetToken{},
+		&models.LoginAttempt{}, &models.RecoveryCode{},
+		&models.APIKey{},
+		&models.Session{}, &models.AuditEvent{}); err != nil {
+		return fmt.Errorf("数据库迁移失败: %w", err)
+	}
+	if err := migrateBookCopies(db); err != nil {
+		return fmt.Errorf("生成馆藏副本失败: %w", err)
+	}
+	if err := migrateBookAuthors(db); err != nil {
+		return fmt.Errorf("拆分图书作者失败: %w", err)
+	}
+	return nil
+}
+
+// migrateBookAuthors 把升级前的作者字符串拆分为责任者记录，写法只差空白和标点的姓名视为同一人
+// 只处理还没有关联责任者的图书，重复执行不会产生变化；
+// 无法判断是几位作者的字符串整体关联为一位作者并标记 needs_review，留待人工拆分或确认
+func migrateBookAuthors(db *gorm.DB) error {
+	var books []models.Book
+	if err := db.Where("author <> '' AND NOT EXISTS (SELECT 1 FROM book_authors WHERE book_authors.book_id = books.id)").
+		Find(&books).Error; err != nil {
+		return err
+	}
+
+	authorIDs := make(map[string]uint)
+	migrated, unsplit := 0, 0
+	for _, book := range books {
+		names, ambiguous := models.SplitAuthorNames(book.Author)
+		if ambiguous {
+			names = []string{strings.TrimSpace(book.Author)}
+			unsplit++
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			linked := make(map[uint]bool)
+			for _, name := range names {
+				key := models.AuthorNameKey(name)
+				authorID, ok := authorIDs[key]
+				if !ok {
+					var author models.Author
+					err := tx.Where("name_key = ?", key).Order("id").First(&author).Error
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						author = models.Author{Name: name, NameKey: key, NeedsReview: ambiguous}
+						err = tx.Create(&author).Error
+					}
+					if err != nil {
+						return err
+					}
+					// 任何一本书失败都会中止迁移，缓存中不会留下已回滚的作者
+					authorID = author.ID
+					authorIDs[key] = authorID
+				}
+
+				if linked[authorID] {
+					continue
+				}
+				link := models.BookAuthor{BookID: book.ID, AuthorID: authorID, Role: models.ContributorAuthor, Position: len(linked)}
+				if err := tx.Create(&link).Error; err != nil {
+					return err
+				}
+				linked[authorID] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("图书 %d: %w", book.ID, err)
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		log.Printf("已为 %d 种图书拆分作者", migrated)
+	}
+	if unsplit > 0 {
+		log.Printf("%d 种图书的作者含有逗号、& 或 and，无法判断是几位作者，已整体关联为一位作者，请通过 GET /api/authors?needs_review=true 核对", unsplit)
+	}
+	return nil
+}
+
+// migrateBookCopies 为升级前只有库存计数的图书生成副本，并把未归还的借阅记录关联到副本
+// 只处理还没有任何副本的图书，重复执行不会产生变化
+func migrateBookCopies(db *gorm.DB) error {
+	var books []models.Book
+	if err := db.Where("NOT EXISTS (SELECT 1 FROM book_copies WHERE book_copies.book_id = books.id)").
+		Find(&books).Error; err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var active []models.BorrowRecord
+			if err := tx.Where("book_id = ? AND returned_at IS NULL AND copy_id IS NULL", book.ID).
+				Order("id").Find(&active).Error; err != nil {
+				return err
+			}
+
+			// 借出的册数以借阅记录为准，计数与记录不一致时按较大者生成
+			count := max(book.TotalCopies, len(active))
+			copies := make([]models.BookCopy, count)
+			for i := range copies {
+				copies[i] = models.BookCopy{
+					BookID:    book.ID,
+					Barcode:   models.GeneratedBarcode(book.ID, i+1),
+					Status:    models.CopyStatusAvailable,
+					Condition: models.CopyConditionGood,
+				}
+				if i < len(active) {
+					copies[i].Status = models.CopyStatusOnLoan
+				}
+			}
+			if count > 0 {
+				if err := tx.Create(&copies).Error; err != nil {
+					return err
+				}
+			}
+
+			for i, record := range active {
+				if err := tx.Model(&models.BorrowRecord{}).Where("id = ?", record.ID).
+					Update("copy_id", copies[i].ID).Error; err != nil {
+					return err
+				}
+			}
+			return models.RefreshBookCopyCounts(tx, book.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("图书 %d: %w", book.ID, err)
+		}
+	}
+	if len(books) > 0 {
+		log.Printf("已为 %d 种图书生成馆藏副本", len(books))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 频率限制的计数维度
+const (
+	RateLimitKeyIP     = "ip"     // 按客户端 IP
+	RateLimitKeyUser   = "user"   // 按登录用户，未登录时按 IP
+	RateLimitKeyClient = "client" // 按 API 密钥，其次按登录用户，最后按 IP
+)
+
+// RateLimit 令牌桶限流参数：每 Period 补充 Requests 个令牌，桶容量为 Burst
+type RateLimit struct {
+	Requests int // 0 表示不限制
+	Period   time.Duration
+	Burst    int
+	Key      string // ip、user 或 client
+}
+
+// loadRateLimit 读取以 prefix 开头的配置项，未配置的项使用 defaults
+func loadRateLimit(prefix string, defaults RateLimit) RateLimit {
+	limit := RateLimit{
+		Requests: getEnvInt(prefix+"REQUESTS", defaults.Requests),
+		Period:   time.Duration(getEnvInt(prefix+"PERIOD_SECONDS", int(defaults.Period/time.Second))) * time.Second,
+		Burst:    getEnvInt(prefix+"BURST", defaults.Burst),
+		Key:      getEnv(prefix+"KEY", defaults.Key),
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Requests
+	}
+	return limit
+}
+
+func (l RateLimit) validate(name string) error {
+	if l.Requests <= 0 {
+		return nil
+	}
+	if l.Period <= 0 {
+		return fmt.Errorf("%sPERIOD_SECONDS 必须大于0", name)
+	}
+	switch l.Key {
+	case RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyClient:
+		return nil
+	}
+	return fmt.Errorf("%sKEY 只能是 ip、user 或 client", name)
+}
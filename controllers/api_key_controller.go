@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	apiKeyService services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKeyRequest 创建API密钥请求
+type CreateAPIKeyRequest struct {
+	Name      string              `json:"name" binding:"required,max=100" example:"目录导入脚本"`
+	Scopes    []models.Permission `json:"scopes" binding:"required,min=1" example:"book:create,book:update"`
+	ExpiresAt *time.Time          `json:"expires_at" example:"2026-12-31T23:59:59Z"`
+}
+
+// CreateAPIKeyResponse 创建API密钥响应，Key 为密钥明文，只返回这一次
+type CreateAPIKeyResponse struct {
+	Message string         `json:"message"`
+	Key     string         `json:"key"`
+	APIKey  *models.APIKey `json:"api_key"`
+}
+
+// CreateAPIKey godoc
+// @Summary      创建API密钥
+// @Description  为脚本和集成创建个人API密钥，权限范围不能超出当前角色；请求时使用 Authorization: ApiKey <密钥> 或 X-API-Key 头
+// @Tags         API密钥
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateAPIKeyRequest  true  "密钥信息"
+// @Success      201  {object}  CreateAPIKeyResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /users/api-keys [post]
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, rawKey, err := c.apiKeyService.Create(actorFrom(ctx), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		Message: "API密钥创建成功，请立即保存，之后将无法再次查看",
+		Key:     rawKey,
+		APIKey:  key,
+	})
+}
+
+// GetAPIKeys godoc
+// @Summary      获取API密钥列表
+// @Description  分页列出当前用户的API密钥（不含密钥明文），包括已撤销的密钥
+// @Description  sort 可选 created_at（默认，最新的在前）、name、id
+// @Tags         API密钥
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        query  query     ListQuery  false  "分页和排序"
+// @Success      200  {object}  repositories.Page[models.APIKey]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/api-keys [get]
+func (c *APIKeyController) GetAPIKeys(ctx *gin.Context) {
+	var query ListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys, err := c.apiKeyService.List(ctx.GetUint("userID"), query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary      撤销API密钥
+// @Description  撤销当前用户的指定API密钥，撤销后立即失效
+// @Tags         API密钥
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "密钥ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /users/api-keys/{id} [delete]
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的密钥ID"})
+		return
+	}
+
+	if err := c.apiKeyService.Revoke(actorFrom(ctx), uint(id)); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "API密钥已撤销"})
+}
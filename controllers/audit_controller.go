@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/services"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditActionPattern = regexp.MustCompile(`^[a-z0-9_.]+\*?$`)
+
+type AuditController struct {
+	auditService services.AuditService
+}
+
+func NewAuditController(auditService services.AuditService) *AuditController {
+	return &AuditController{auditService: auditService}
+}
+
+// AuditQuery 审计日志查询参数
+type AuditQuery struct {
+	ListQuery
+	ActorID    *uint  `form:"actor_id" example:"1"`
+	Action     string `form:"action" example:"book.*"`
+	TargetType string `form:"target_type" example:"book"`
+	TargetID   string `form:"target_id" example:"42"`
+	RequestID  string `form:"request_id"`
+	From       string `form:"from" example:"2026-01-01"`
+	To         string `form:"to" example:"2026-03-31"`
+	Format     string `form:"format" enums:"json,csv"`
+}
+
+// GetAuditEvents godoc
+// @Summary      查询审计日志
+// @Description  按操作人、操作类型、操作对象、请求ID和时间范围查询审计日志，action 以 * 结尾时按前缀匹配（如 book.*）
+// @Description  from/to 支持 RFC3339 时间或 YYYY-MM-DD 日期，日期形式的 to 包含当天
+// @Description  sort 可选 created_at（默认，最新的在前）、id；审计日志很大，翻页时应使用响应中的 next_cursor
+// @Description  format=csv 时导出全部符合条件的记录（忽略分页），按时间正序排列
+// @Tags         审计
+// @Accept       json
+// @Produce      json,text/csv
+// @Security     BearerAuth
+// @Param        query  query  AuditQuery  false  "查询条件"
+// @Success      200  {object}  repositories.Page[models.AuditEvent]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/audit [get]
+func (c *AuditController) GetAuditEvents(ctx *gin.Context) {
+	var query AuditQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := query.filter()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if strings.EqualFold(query.Format, "csv") {
+		c.exportCSV(ctx, filter)
+		return
+	}
+
+	events, err := c.auditService.Find(filter, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
+
+// exportCSV 边查询边输出，导出大量记录时不占用过多内存
+func (c *AuditController) exportCSV(ctx *gin.Context, filter repositories.AuditFilter) {
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().Format("20060102-150405"))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+
+	w := csv.NewWriter(ctx.Writer)
+	w.Write([]string{"id", "created_at", "actor_id", "actor_name", "api_key_id", "action",
+		"target_type", "target_id", "ip", "request_id", "changes"})
+
+	err := c.auditService.Export(filter, func(events []models.AuditEvent) error {
+		for _, event := range events {
+			changes := ""
+			if len(event.Changes) > 0 {
+				data, err := json.Marshal(event.Changes)
+				if err != nil {
+					return err
+				}
+				changes = string(data)
+			}
+
+			w.Write([]string{
+				strconv.FormatUint(uint64(event.ID), 10),
+				event.CreatedAt.Format(time.RFC3339),
+				optionalID(event.ActorID),
+				csvSafe(event.ActorName),
+				optionalID(event.APIKeyID),
+				string(event.Action),
+				event.TargetType,
+				csvSafe(event.TargetID),
+				event.IP,
+				csvSafe(event.RequestID),
+				changes,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		// 响应头已经发出，只能记录日志，导出的文件不完整
+		log.Printf("导出审计日志失败: %v", err)
+	}
+	w.Flush()
+}
+
+// filter 校验查询参数并转换为仓库的查询条件
+func (q *AuditQuery) filter() (repositories.AuditFilter, error) {
+	filter := repositories.AuditFilter{
+		ActorID:    q.ActorID,
+		Action:     q.Action,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+		RequestID:  q.RequestID,
+	}
+
+	if q.Action != "" && !auditActionPattern.MatchString(q.Action) {
+		return filter, errors.New("无效的操作类型")
+	}
+
+	if q.From != "" {
+		from, _, err := parseQueryTime(q.From)
+		if err != nil {
+			return filter, errors.New("无效的起始时间，请使用 RFC3339 或 YYYY-MM-DD 格式")
+		}
+		filter.From = &from
+	}
+	if q.To != "" {
+		to, dateOnly, err := parseQueryTime(q.To)
+		if err != nil {
+			return filter, errors.New("无效的结束时间，请使用 RFC3339 或 YYYY-MM-DD 格式")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// csvSafe 防止用户可控的内容在电子表格中被当作公式执行
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
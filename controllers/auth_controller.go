@@ -1,20 +1,31 @@
 package controllers
 
 import (
+	"book-management-system/config"
 	"book-management-system/models"
+	"book-management-system/repositories"
 	"book-management-system/services"
 	"book-management-system/utils"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthController struct {
-	authService services.AuthService
+	authService   services.AuthService
+	verification  services.EmailVerificationService
+	passwordReset services.PasswordResetService
 }
 
-func NewAuthController(authService services.AuthService) *AuthController {
-	return &AuthController{authService: authService}
+func NewAuthController(authService services.AuthService, verification services.EmailVerificationService, passwordReset services.PasswordResetService) *AuthController {
+	return &AuthController{
+		authService:   authService,
+		verification:  verification,
+		passwordReset: passwordReset,
+	}
 }
 
 // RegisterRequest 注册请求体
@@ -30,10 +41,41 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// AuthResponse 认证响应
+// AuthResponse 认证响应，需要先验证邮箱时不包含令牌
 type AuthResponse struct {
-	User  *models.User `json:"user"`
-	Token string       `json:"token"`
+	User         *models.User `json:"user"`
+	Token        string       `json:"token,omitempty"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresIn    int64        `json:"expires_in,omitempty" example:"900"`
+	Message      string       `json:"message,omitempty"`
+}
+
+// ResendVerificationRequest 重新发送验证邮件请求体
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+// TwoFactorChallengeResponse 需要两步验证时的登录响应
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required" example:"true"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in" example:"300"`
+}
+
+// VerifyTwoFactorRequest 两步验证登录请求体
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required" example:"123456"`
+}
+
+// RefreshRequest 刷新令牌请求体
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求体
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // SuccessResponse 通用成功响应
@@ -51,22 +93,34 @@ type ChangeUsernameRequest struct {
 	NewUsername string `json:"new_username" binding:"required,min=3,max=50" example:"newuser123"`
 }
 
+// ForgotPasswordRequest 忘记密码请求体
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+// ResetPasswordRequest 重置密码请求体
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6" example:"newpassword456"`
+}
+
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required" example:"oldpassword123"`
 	NewPassword string `json:"new_password" binding:"required,min=6" example:"newpassword456"`
 }
 
 type UserInfo struct {
-	ID        uint            `json:"id"`
-	Username  string          `json:"username"`
-	Email     string          `json:"email"`
-	Role      models.UserRole `json:"role"`
-	CreatedAt string          `json:"created_at"`
+	ID        uint              `json:"id"`
+	Username  string            `json:"username"`
+	Email     string            `json:"email"`
+	Role      models.UserRole   `json:"role"`
+	Status    models.UserStatus `json:"status"`
+	CreatedAt string            `json:"created_at"`
 }
 
 // Register godoc
 // @Summary      用户注册
-// @Description  新用户注册
+// @Description  新用户注册；启用邮箱验证时账户在验证前处于 pending 状态，可能不返回令牌
 // @Tags         认证
 // @Accept       json
 // @Produce      json
@@ -88,30 +142,84 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		return
 	}
 
-	token, err := utils.GenerateToken(user)
+	tokens, err := c.authService.IssueTokens(user, clientInfo(ctx))
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		ctx.JSON(http.StatusCreated, AuthResponse{
+			User:    user,
+			Message: "注册成功，请查收验证邮件完成邮箱验证后登录",
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
-	response := AuthResponse{
-		User:  user,
-		Token: token,
+	ctx.JSON(http.StatusCreated, newAuthResponse(user, tokens))
+}
+
+// VerifyEmail godoc
+// @Summary      验证邮箱
+// @Description  通过验证邮件中的链接完成邮箱验证并激活账户
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        token  query  string  true  "验证令牌"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Router       /auth/verify-email [get]
+func (c *AuthController) VerifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少验证令牌"})
+		return
+	}
+
+	user, err := c.verification.Verify(token)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	ctx.JSON(http.StatusCreated, response)
+	ctx.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功", "data": user})
+}
+
+// ResendVerification godoc
+// @Summary      重新发送验证邮件
+// @Description  为尚未验证的账户重新发送验证邮件，无论邮箱是否注册都返回相同结果；按 IP 和收件地址限制频率
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request  body  ResendVerificationRequest  true  "邮箱"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Router       /auth/verify-email/resend [post]
+func (c *AuthController) ResendVerification(ctx *gin.Context) {
+	var req ResendVerificationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.verification.Resend(req.Email)
+	ctx.JSON(http.StatusOK, gin.H{"message": "如果该邮箱对应的账户尚未验证，验证邮件已重新发送"})
 }
 
 // Login godoc
 // @Summary      用户登录
-// @Description  用户登录获取JWT令牌
+// @Description  用户登录获取JWT令牌，按 AUTH_BACKENDS 依次使用本地密码或 LDAP 校验；启用两步验证的账户返回挑战令牌，需调用 /auth/2fa/verify 完成登录
 // @Tags         认证
 // @Accept       json
 // @Produce      json
 // @Param        request  body  LoginRequest  true  "登录凭证"
 // @Success      200      {object}  AuthResponse
+// @Success      202      {object}  TwoFactorChallengeResponse
 // @Failure      400      {object}  ErrorResponse
 // @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
 // @Router       /auth/login [post]
 func (c *AuthController) Login(ctx *gin.Context) {
 	var req LoginRequest
@@ -120,18 +228,134 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	user, token, err := c.authService.Login(req.Username, req.Password)
+	result, err := c.authService.Login(req.Username, req.Password, clientInfo(ctx))
+	if err != nil {
+		respondLoginError(ctx, err)
+		return
+	}
+
+	if result.TwoFactorRequired {
+		ctx.JSON(http.StatusAccepted, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    result.ChallengeToken,
+			ExpiresIn:         int64(config.AppConfig.TwoFactorChallengeExpire.Seconds()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAuthResponse(result.User, result.Tokens))
+}
+
+// VerifyTwoFactor godoc
+// @Summary      两步验证登录
+// @Description  提交登录返回的挑战令牌和验证器中的验证码（或恢复码）完成登录
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request  body  VerifyTwoFactorRequest  true  "挑战令牌和验证码"
+// @Success      200      {object}  AuthResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /auth/2fa/verify [post]
+func (c *AuthController) VerifyTwoFactor(ctx *gin.Context) {
+	var req VerifyTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, tokens, err := c.authService.VerifyTwoFactor(req.ChallengeToken, req.Code, clientInfo(ctx))
 	if err != nil {
+		respondLoginError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// respondLoginError 将登录过程中的错误映射为对应的HTTP状态码
+func respondLoginError(ctx *gin.Context, err error) {
+	var locked *services.ErrAccountLocked
+	switch {
+	case errors.As(err, &locked):
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailNotVerified), errors.Is(err, services.ErrAccountSuspended):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAuthBackendUnavailable):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	}
+}
+
+// Refresh godoc
+// @Summary      刷新令牌
+// @Description  使用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌立即失效；重复使用已轮换的刷新令牌会撤销整个登录会话
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request  body  RefreshRequest  true  "刷新令牌"
+// @Success      200      {object}  AuthResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Router       /auth/refresh [post]
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var req RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response := AuthResponse{
-		User:  user,
-		Token: token,
+	user, tokens, err := c.authService.Refresh(req.RefreshToken, clientInfo(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
 	}
 
-	ctx.JSON(http.StatusOK, response)
+	ctx.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// clientInfo 读取请求方的 IP 和 User-Agent，用于记录登录会话
+func clientInfo(ctx *gin.Context) services.ClientInfo {
+	return services.ClientInfo{IP: ctx.ClientIP(), UserAgent: ctx.Request.UserAgent()}
+}
+
+// actorFrom 取得审计日志中的操作主体，未登录的请求只有 IP 和请求ID
+func actorFrom(ctx *gin.Context) services.Actor {
+	actor := services.Actor{
+		UserID:    ctx.GetUint("userID"),
+		Username:  ctx.GetString("username"),
+		IP:        ctx.ClientIP(),
+		RequestID: ctx.GetString("requestID"),
+	}
+	if role, ok := ctx.Get("userRole"); ok {
+		actor.Role = role.(models.UserRole)
+	}
+	if key, ok := ctx.Get("apiKey"); ok {
+		actor.APIKeyID = key.(*models.APIKey).ID
+		actor.Scopes = key.(*models.APIKey).Scopes
+	}
+	return actor
+}
+
+// auditErrorStatus 安全相关操作因审计日志无法写入而放弃时返回 503，其余错误返回 fallback
+func auditErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrAuditUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return fallback
+}
+
+func newAuthResponse(user *models.User, tokens *services.TokenPair) AuthResponse {
+	return AuthResponse{
+		User:         user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
 }
 
 // GetProfile godoc
@@ -172,6 +396,7 @@ func (c *AuthController) GetProfile(ctx *gin.Context) {
 // @Success      200  {object}  SuccessResponse
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
 // @Router       /users/username [put]
 func (c *AuthController) ChangeUsername(ctx *gin.Context) {
 	// 获取用户ID
@@ -187,8 +412,8 @@ func (c *AuthController) ChangeUsername(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.authService.ChangeUsername(userID.(uint), req.NewUsername); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.authService.ChangeUsername(actorFrom(ctx), userID.(uint), req.NewUsername); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -209,6 +434,7 @@ func (c *AuthController) ChangeUsername(ctx *gin.Context) {
 // @Success      200  {object}  SuccessResponse
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
 // @Router       /users/password [put]
 func (c *AuthController) ChangePassword(ctx *gin.Context) {
 	// 获取用户ID
@@ -224,62 +450,190 @@ func (c *AuthController) ChangePassword(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.authService.ChangePassword(userID.(uint), req.OldPassword, req.NewPassword); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.authService.ChangePassword(actorFrom(ctx), userID.(uint), req.OldPassword, req.NewPassword); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
 }
 
+// ForgotPassword godoc
+// @Summary      忘记密码
+// @Description  向邮箱发送一次性的密码重置链接，无论邮箱是否注册都返回相同结果；按 IP 和收件地址限制频率
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request  body  ForgotPasswordRequest  true  "邮箱"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Router       /auth/password/forgot [post]
+func (c *AuthController) ForgotPassword(ctx *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.passwordReset.RequestReset(req.Email)
+	ctx.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，密码重置邮件已发送"})
+}
+
+// ResetPassword godoc
+// @Summary      重置密码
+// @Description  使用邮件中的重置令牌设置新密码，令牌只能使用一次，成功后所有设备上的登录会话都会被撤销
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request  body  ResetPasswordRequest  true  "重置令牌和新密码"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /auth/password/reset [post]
+func (c *AuthController) ResetPassword(ctx *gin.Context) {
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.passwordReset.ResetPassword(actorFrom(ctx), req.Token, req.NewPassword); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "密码已重置，请使用新密码登录"})
+}
+
 // GetAllUsers godoc
-// @Summary      获取所有用户列表
-// @Description  管理员获取所有用户列表
+// @Summary      获取用户列表
+// @Description  管理员分页获取用户列表，可按用户名或邮箱关键词、角色和状态过滤
+// @Description  sort 可选 created_at（默认）、username、id
 // @Tags         用户管理
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {array}   UserInfo
-// @Failure      401  {object}  ErrorResponse
-// @Failure      403  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
+// @Param        query  query     UserListQuery  false  "过滤、分页和排序"
+// @Success      200    {object}  repositories.Page[UserInfo]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      401    {object}  ErrorResponse
+// @Failure      403    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
 // @Router       /admin/users [get]
 func (c *AuthController) GetAllUsers(ctx *gin.Context) {
-	users, err := c.authService.GetAllUsers()
+	var query UserListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := c.authService.ListUsers(query.filter(), query.spec())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
 	// 转换为UserInfo格式
-	var userInfos []UserInfo
-	for _, user := range users {
-		userInfos = append(userInfos, UserInfo{
+	userInfos := repositories.MapPage(users, func(user models.User) UserInfo {
+		return UserInfo{
 			ID:        user.ID,
 			Username:  user.Username,
 			Email:     user.Email,
 			Role:      user.Role,
+			Status:    user.Status,
 			CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
-	}
+		}
+	})
 
 	ctx.JSON(http.StatusOK, userInfos)
 }
 
 // Logout godoc
 // @Summary      用户退出登录
-// @Description  用户退出登录，前端需要删除本地存储的token
+// @Description  撤销当前访问令牌；请求体中携带刷新令牌时一并撤销
 // @Tags         认证
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        request  body  LogoutRequest  false  "刷新令牌"
 // @Success      200  {object}  SuccessResponse
 // @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
 // @Router       /auth/logout [post]
 func (c *AuthController) Logout(ctx *gin.Context) {
-	// JWT是无状态的，退出只需要前端删除token
-	// 这里只需返回成功信息
+	claimsValue, exists := ctx.Get("claims")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	// 请求体可选，解析失败时只撤销访问令牌
+	var req LogoutRequest
+	_ = ctx.ShouldBindJSON(&req)
+
+	if err := c.authService.Logout(claimsValue.(*utils.Claims), req.RefreshToken); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "退出登录成功",
 	})
 }
+
+// LogoutAll godoc
+// @Summary      退出所有设备
+// @Description  撤销当前用户在所有设备上的访问令牌和刷新令牌
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  SuccessResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /auth/logout-all [post]
+func (c *AuthController) LogoutAll(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	if err := c.authService.LogoutAll(actorFrom(ctx), userID.(uint)); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已退出所有设备"})
+}
+
+// RevokeUserSessions godoc
+// @Summary      撤销用户的全部会话
+// @Description  管理员强制指定用户在所有设备上退出登录（用于离职、安全事件处置）
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/revoke-sessions [post]
+func (c *AuthController) RevokeUserSessions(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.authService.LogoutAll(actorFrom(ctx), id); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已撤销该用户的全部会话"})
+}
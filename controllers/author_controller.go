@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthorController struct {
+	authorService services.AuthorService
+}
+
+func NewAuthorController(authorService services.AuthorService) *AuthorController {
+	return &AuthorController{authorService: authorService}
+}
+
+// AuthorRequest 创建作者请求
+type AuthorRequest struct {
+	Name         string   `json:"name" binding:"required,max=255" example:"J. K. Rowling"`
+	NameVariants []string `json:"name_variants" binding:"omitempty,max=20,dive,max=255" example:"J.K. Rowling,罗琳"`
+	BirthYear    *int     `json:"birth_year" example:"1965"`
+	DeathYear    *int     `json:"death_year"`
+	Bio          string   `json:"bio" binding:"max=10000"`
+}
+
+// UpdateAuthorRequest 更新作者请求，未提供的字段保持不变，年份传 0 表示清除
+type UpdateAuthorRequest struct {
+	Name         *string  `json:"name" binding:"omitempty,max=255"`
+	NameVariants []string `json:"name_variants" binding:"omitempty,max=20,dive,max=255"`
+	BirthYear    *int     `json:"birth_year"`
+	DeathYear    *int     `json:"death_year"`
+	Bio          *string  `json:"bio" binding:"omitempty,max=10000"`
+	NeedsReview  *bool    `json:"needs_review" example:"false"`
+}
+
+// GetAuthors godoc
+// @Summary      查询作者
+// @Description  按姓名或其他写法分页查找作者，不提供关键词时返回全部作者
+// @Description  needs_review=true 只返回升级时未能拆分、等待人工核对的作者
+// @Description  sort 可选 name（默认）、created_at、id
+// @Tags         作者
+// @Accept       json
+// @Produce      json
+// @Param        query  query     AuthorListQuery  false  "姓名关键词、核对状态、分页和排序"
+// @Success      200    {object}  repositories.Page[models.Author]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
+// @Router       /authors [get]
+func (c *AuthorController) GetAuthors(ctx *gin.Context) {
+	var query AuthorListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authors, err := c.authorService.SearchAuthors(query.filter(), query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, authors)
+}
+
+// GetAuthor godoc
+// @Summary      获取作者详情
+// @Description  根据ID获取作者详情
+// @Tags         作者
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "作者ID"
+// @Success      200  {object}  models.Author
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /authors/{id} [get]
+func (c *AuthorController) GetAuthor(ctx *gin.Context) {
+	id, ok := parseAuthorID(ctx)
+	if !ok {
+		return
+	}
+
+	author, err := c.authorService.GetAuthor(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, author)
+}
+
+// GetAuthorBooks godoc
+// @Summary      获取作者的图书
+// @Description  分页列出作者参与的图书，每本书的 contributors 为作者在书中的角色，排序参数与图书列表相同
+// @Tags         作者
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int        true   "作者ID"
+// @Param        query  query     ListQuery  false  "分页和排序"
+// @Success      200    {object}  repositories.Page[models.Book]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      404    {object}  ErrorResponse
+// @Router       /authors/{id}/books [get]
+func (c *AuthorController) GetAuthorBooks(ctx *gin.Context) {
+	id, ok := parseAuthorID(ctx)
+	if !ok {
+		return
+	}
+
+	var query ListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := c.authorService.GetAuthorBooks(id, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, books)
+}
+
+// CreateAuthor godoc
+// @Summary      创建作者
+// @Description  登记作者，同名的不同作者可以分别登记
+// @Tags         作者
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      AuthorRequest  true  "作者信息"
+// @Success      201      {object}  models.Author
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/authors [post]
+func (c *AuthorController) CreateAuthor(ctx *gin.Context) {
+	var req AuthorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	author := &models.Author{
+		Name:         req.Name,
+		NameVariants: req.NameVariants,
+		BirthYear:    req.BirthYear,
+		DeathYear:    req.DeathYear,
+		Bio:          req.Bio,
+	}
+	if err := c.authorService.CreateAuthor(actorFrom(ctx), author); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, author)
+}
+
+// UpdateAuthor godoc
+// @Summary      更新作者
+// @Description  更新作者信息，改名后关联图书的作者文本随之更新；核对完升级时未拆分的作者后传 needs_review=false
+// @Tags         作者
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int                  true  "作者ID"
+// @Param        request  body      UpdateAuthorRequest  true  "作者信息"
+// @Success      200      {object}  models.Author
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/authors/{id} [put]
+func (c *AuthorController) UpdateAuthor(ctx *gin.Context) {
+	id, ok := parseAuthorID(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateAuthorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	author, err := c.authorService.UpdateAuthor(actorFrom(ctx), id, services.AuthorUpdate{
+		Name:         req.Name,
+		NameVariants: req.NameVariants,
+		BirthYear:    req.BirthYear,
+		DeathYear:    req.DeathYear,
+		Bio:          req.Bio,
+		NeedsReview:  req.NeedsReview,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, author)
+}
+
+// DeleteAuthor godoc
+// @Summary      删除作者
+// @Description  删除没有关联图书的作者
+// @Tags         作者
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "作者ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/authors/{id} [delete]
+func (c *AuthorController) DeleteAuthor(ctx *gin.Context) {
+	id, ok := parseAuthorID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.authorService.DeleteAuthor(actorFrom(ctx), id); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "作者已删除"})
+}
+
+func parseAuthorID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的作者ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -3,8 +3,10 @@ package controllers
 import (
 	"book-management-system/models"
 	"book-management-system/services"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,16 +21,50 @@ func NewBookController(bookService services.BookService) *BookController {
 
 // CreateBookRequest 创建图书请求
 type CreateBookRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Author      string `json:"author" binding:"required"`
-	TotalCopies int    `json:"total_copies" binding:"required,min=1"`
+	Title           string   `json:"title" binding:"required,max=255" example:"深入理解计算机系统"`
+	Author          string   `json:"author" binding:"required_without=Contributors,max=255" example:"Randal E. Bryant; David R. O'Hallaron"`
+	ISBN            string   `json:"isbn" example:"978-7-111-54493-7"` // ISBN-10 或 ISBN-13，可带连字符
+	Publisher       string   `json:"publisher" binding:"max=255" example:"机械工业出版社"`
+	PublicationYear int      `json:"publication_year" example:"2016"`
+	Edition         string   `json:"edition" binding:"max=50" example:"第3版"`
+	Language        string   `json:"language" binding:"max=35" example:"zh-CN"`
+	PageCount       int      `json:"page_count" binding:"min=0" example:"737"`
+	Description     string   `json:"description" binding:"max=10000"`
+	Subjects        []string `json:"subjects" example:"计算机系统,操作系统"`
+	// 提供 contributors 时 author 由责任者生成，否则按逗号、顿号等拆分 author 关联著者
+	Contributors []ContributorRequest `json:"contributors" binding:"omitempty,max=50,dive"`
+	CategoryIDs  []uint               `json:"category_ids" binding:"omitempty,max=20" example:"3,12"`
+	Tags         []string             `json:"tags" binding:"omitempty,max=20,dive,max=50" example:"经典,必读"`
+	// 提供 copies 时按其中的条码登记副本，否则生成 total_copies 册自动条码的副本
+	TotalCopies int           `json:"total_copies" binding:"required_without=Copies,omitempty,min=1" example:"3"`
+	Copies      []CopyRequest `json:"copies" binding:"omitempty,max=200,dive"`
 }
 
-// UpdateBookRequest 更新图书请求
+// ContributorRequest 图书的一位责任者，提供 author_id 时关联已有作者，否则按 name 查找或新建
+type ContributorRequest struct {
+	AuthorID uint   `json:"author_id" example:"1"`
+	Name     string `json:"name" binding:"required_without=AuthorID,max=255" example:"Randal E. Bryant"`
+	Role     string `json:"role" binding:"omitempty,oneof=author editor translator illustrator" example:"author"`
+}
+
+// UpdateBookRequest 更新图书请求，未提供的字段保持不变，isbn 传空字符串表示清除
+// 馆藏数量由副本决定，通过副本接口增减
 type UpdateBookRequest struct {
-	Title       string `json:"title"`
-	Author      string `json:"author"`
-	TotalCopies int    `json:"total_copies" binding:"min=1"`
+	Title           *string  `json:"title" binding:"omitempty,max=255"`
+	Author          *string  `json:"author" binding:"omitempty,max=255"`
+	ISBN            *string  `json:"isbn" example:"7111544930"`
+	Publisher       *string  `json:"publisher" binding:"omitempty,max=255"`
+	PublicationYear *int     `json:"publication_year"`
+	Edition         *string  `json:"edition" binding:"omitempty,max=50"`
+	Language        *string  `json:"language" binding:"omitempty,max=35"`
+	PageCount       *int     `json:"page_count" binding:"omitempty,min=0"`
+	Description     *string  `json:"description" binding:"omitempty,max=10000"`
+	Subjects        []string `json:"subjects"`
+	// 提供时整体替换责任者并重新生成 author
+	Contributors []ContributorRequest `json:"contributors" binding:"omitempty,max=50,dive"`
+	// 提供时整体替换，传空数组表示清空
+	CategoryIDs []uint   `json:"category_ids" binding:"omitempty,max=20"`
+	Tags        []string `json:"tags" binding:"omitempty,max=20,dive,max=50"`
 }
 
 // BorrowRequest 借书请求
@@ -36,6 +72,20 @@ type BorrowRequest struct {
 	BookID uint `json:"book_id" binding:"required"`
 }
 
+// CirculationRequest 流通台代读者借书请求，提供 barcode 时借出该册，否则借出该图书任意一册在架副本
+type CirculationRequest struct {
+	UserID  uint   `json:"user_id" binding:"required"`
+	BookID  uint   `json:"book_id" binding:"required_without=Barcode"`
+	Barcode string `json:"barcode" binding:"max=64" example:"BK000001-001"`
+}
+
+// CheckinRequest 流通台还书请求，扫码还书只需 barcode
+type CheckinRequest struct {
+	Barcode string `json:"barcode" binding:"max=64" example:"BK000001-001"`
+	UserID  uint   `json:"user_id" binding:"required_without=Barcode"`
+	BookID  uint   `json:"book_id" binding:"required_without=Barcode"`
+}
+
 // DeleteBookRequest 删除图书请求
 type DeleteBookRequest struct {
 	Confirm bool `json:"confirm" example:"true"`
@@ -59,23 +109,50 @@ type DeleteBookResponse struct {
 // @Failure      400      {object}  ErrorResponse
 // @Failure      401      {object}  ErrorResponse
 // @Failure      403      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse  "ISBN 已存在"
 // @Router       /admin/books [post]
 func (c *BookController) CreateBook(ctx *gin.Context) {
 	var req CreateBookRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error1": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	copies := make([]models.BookCopy, 0, len(req.Copies))
+	for _, copyReq := range req.Copies {
+		bookCopy, err := copyReq.toModel()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		copies = append(copies, bookCopy)
+	}
+
 	book := &models.Book{
-		Title:       req.Title,
-		Author:      req.Author,
-		TotalCopies: req.TotalCopies,
-		Available:   req.TotalCopies,
+		Title:           req.Title,
+		Author:          req.Author,
+		Publisher:       req.Publisher,
+		PublicationYear: req.PublicationYear,
+		Edition:         req.Edition,
+		Language:        req.Language,
+		PageCount:       req.PageCount,
+		Description:     req.Description,
+		Subjects:        req.Subjects,
+		TotalCopies:     req.TotalCopies,
+		Copies:          copies,
+	}
+	for _, id := range req.CategoryIDs {
+		book.Categories = append(book.Categories, models.Category{ID: id})
+	}
+	for _, name := range req.Tags {
+		book.Tags = append(book.Tags, models.Tag{Name: name})
+	}
+	if req.ISBN != "" {
+		book.ISBN = &req.ISBN
 	}
 
-	if err := c.bookService.CreateBook(book); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error2": err.Error()})
+	if err := c.bookService.CreateBook(actorFrom(ctx), book, contributorInputs(req.Contributors)); err != nil {
+		ctx.JSON(bookErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -83,18 +160,27 @@ func (c *BookController) CreateBook(ctx *gin.Context) {
 }
 
 // GetAllBooks godoc
-// @Summary      获取所有图书
-// @Description  获取所有图书列表
+// @Summary      获取图书列表
+// @Description  分页获取图书列表，可按关键词、作者、出版社、语言和是否可借过滤
+// @Description  sort 可选 created_at（默认）、title、author、publication_year、available、id
 // @Tags         图书
 // @Accept       json
 // @Produce      json
-// @Success      200  {array}  models.Book
-// @Failure      500  {object}  ErrorResponse
+// @Param        query  query     BookListQuery  false  "过滤、分页和排序"
+// @Success      200    {object}  repositories.Page[models.Book]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
 // @Router       /books [get]
 func (c *BookController) GetAllBooks(ctx *gin.Context) {
-	books, err := c.bookService.GetAllBooks()
+	var query BookListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := c.bookService.ListBooks(query.filter(), query.spec())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -137,10 +223,11 @@ func (c *BookController) GetBookByID(ctx *gin.Context) {
 // @Security     BearerAuth
 // @Param        id   path      int               true  "图书ID"
 // @Param        request  body  UpdateBookRequest  true  "图书信息"
-// @Success      200  {object}  SuccessResponse
+// @Success      200  {object}  models.Book
 // @Failure      400  {object}  ErrorResponse
 // @Failure      401  {object}  ErrorResponse
 // @Failure      403  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "ISBN 已被其他图书使用"
 // @Router       /admin/books/{id} [put]
 func (c *BookController) UpdateBook(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
@@ -155,14 +242,24 @@ func (c *BookController) UpdateBook(ctx *gin.Context) {
 		return
 	}
 
-	book := &models.Book{
-		Title:       req.Title,
-		Author:      req.Author,
-		TotalCopies: req.TotalCopies,
+	update := services.BookUpdate{
+		Title:           req.Title,
+		Author:          req.Author,
+		ISBN:            req.ISBN,
+		Publisher:       req.Publisher,
+		PublicationYear: req.PublicationYear,
+		Edition:         req.Edition,
+		Language:        req.Language,
+		PageCount:       req.PageCount,
+		Description:     req.Description,
+		Subjects:        req.Subjects,
+		Contributors:    contributorInputs(req.Contributors),
+		CategoryIDs:     req.CategoryIDs,
+		Tags:            req.Tags,
 	}
 
-	if err := c.bookService.UpdateBook(uint(id), book); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.bookService.UpdateBook(actorFrom(ctx), uint(id), update); err != nil {
+		ctx.JSON(bookErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -177,6 +274,26 @@ func (c *BookController) UpdateBook(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, updatedBook)
 }
 
+// contributorInputs 转换请求中的责任者，未提供时返回 nil
+func contributorInputs(requests []ContributorRequest) []services.ContributorInput {
+	if requests == nil {
+		return nil
+	}
+	inputs := make([]services.ContributorInput, 0, len(requests))
+	for _, r := range requests {
+		inputs = append(inputs, services.ContributorInput{AuthorID: r.AuthorID, Name: r.Name, Role: models.ContributorRole(r.Role)})
+	}
+	return inputs
+}
+
+// bookErrorStatus ISBN 或条码冲突返回 409，其余创建、更新失败均视为请求参数错误
+func bookErrorStatus(err error) int {
+	if errors.Is(err, services.ErrISBNExists) || errors.Is(err, services.ErrBarcodeExists) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
 // DeleteBook godoc
 // @Summary      删除图书
 // @Description  管理员删除图书
@@ -205,7 +322,7 @@ func (c *BookController) DeleteBook(ctx *gin.Context) {
 	}
 
 	// 删除图书
-	if err := c.bookService.DeleteBook(uint(id)); err != nil {
+	if err := c.bookService.DeleteBook(actorFrom(ctx), uint(id)); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}) // JSON大写
 		return
 	}
@@ -219,25 +336,29 @@ func (c *BookController) DeleteBook(ctx *gin.Context) {
 
 // SearchBooks godoc
 // @Summary      搜索图书
-// @Description  根据关键词搜索图书
+// @Description  根据关键词搜索图书，与图书列表相同但 q 必填，其余过滤、分页和排序参数同样可用
 // @Tags         图书
 // @Accept       json
 // @Produce      json
-// @Param        q    query     string  true  "搜索关键词"
-// @Success      200  {array}   models.Book
-// @Failure      400  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
+// @Param        query  query     BookListQuery  true  "搜索关键词、过滤、分页和排序"
+// @Success      200    {object}  repositories.Page[models.Book]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
 // @Router       /books/search [get]
 func (c *BookController) SearchBooks(ctx *gin.Context) {
-	query := ctx.Query("q")
-	if query == "" {
+	var query BookListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(query.Query) == "" {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词不能为空"})
 		return
 	}
 
-	books, err := c.bookService.SearchBooks(query)
+	books, err := c.bookService.ListBooks(query.filter(), query.spec())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -273,7 +394,7 @@ func (c *BookController) BorrowBook(ctx *gin.Context) {
 	}
 
 	// 执行借书操作
-	if err := c.bookService.BorrowBook(userID.(uint), req.BookID); err != nil {
+	if err := c.bookService.BorrowBook(actorFrom(ctx), userID.(uint), req.BookID); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -308,7 +429,7 @@ func (c *BookController) ReturnBook(ctx *gin.Context) {
 
 	userID, _ := ctx.Get("userID")
 
-	if err := c.bookService.ReturnBook(userID.(uint), req.BookID); err != nil {
+	if err := c.bookService.ReturnBook(actorFrom(ctx), userID.(uint), req.BookID); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -316,6 +437,101 @@ func (c *BookController) ReturnBook(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "还书成功"})
 }
 
+// CheckoutForUser godoc
+// @Summary      代读者借书
+// @Description  流通台馆员为指定读者办理借书，扫码借书时提供副本条码
+// @Tags         借阅管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CirculationRequest  true  "读者和图书或副本条码"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/circulation/checkout [post]
+func (c *BookController) CheckoutForUser(ctx *gin.Context) {
+	var req CirculationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Barcode != "" {
+		record, err := c.bookService.CheckoutCopy(actorFrom(ctx), req.UserID, req.Barcode)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":  "借书成功",
+			"user_id":  record.UserID,
+			"book_id":  record.BookID,
+			"barcode":  record.Copy.Barcode,
+			"due_date": record.DueDate,
+		})
+		return
+	}
+
+	if err := c.bookService.BorrowBook(actorFrom(ctx), req.UserID, req.BookID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "借书成功",
+		"user_id": req.UserID,
+		"book_id": req.BookID,
+	})
+}
+
+// CheckinForUser godoc
+// @Summary      代读者还书
+// @Description  流通台馆员办理还书，扫码还书时只需副本条码
+// @Tags         借阅管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CheckinRequest  true  "副本条码或读者和图书"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/circulation/checkin [post]
+func (c *BookController) CheckinForUser(ctx *gin.Context) {
+	var req CheckinRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Barcode != "" {
+		record, err := c.bookService.CheckinCopy(actorFrom(ctx), req.Barcode)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "还书成功",
+			"user_id": record.UserID,
+			"book_id": record.BookID,
+			"barcode": record.Copy.Barcode,
+		})
+		return
+	}
+
+	if err := c.bookService.ReturnBook(actorFrom(ctx), req.UserID, req.BookID); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "还书成功",
+		"user_id": req.UserID,
+		"book_id": req.BookID,
+	})
+}
+
 // GetMyBorrowedBooks godoc
 // @Summary      获取已借图书
 // @Description  获取当前用户已借的图书列表
@@ -341,21 +557,35 @@ func (c *BookController) GetMyBorrowedBooks(ctx *gin.Context) {
 
 // GetMyBorrowRecords godoc
 // @Summary      获取借阅记录
-// @Description  获取当前用户的借阅记录
+// @Description  分页获取当前用户的借阅记录，过滤和排序参数与借阅记录管理相同，user_id 固定为当前用户
 // @Tags         借阅
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {array}  models.BorrowRecord
-// @Failure      401  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
+// @Param        query  query     BorrowRecordListQuery  false  "过滤、分页和排序"
+// @Success      200    {object}  repositories.Page[models.BorrowRecord]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      401    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
 // @Router       /books/my-records [get]
 func (c *BookController) GetMyBorrowRecords(ctx *gin.Context) {
 	userID, _ := ctx.Get("userID")
 
-	records, err := c.bookService.GetBorrowRecords(userID.(uint))
+	var query BorrowRecordListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := query.filter()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.UserID = userID.(uint)
+
+	records, err := c.bookService.ListBorrowRecords(filter, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -364,20 +594,34 @@ func (c *BookController) GetMyBorrowRecords(ctx *gin.Context) {
 
 // GetAllBorrowRecords godoc
 // @Summary      获取所有借阅记录
-// @Description  管理员获取所有用户的借阅记录
+// @Description  管理员分页获取借阅记录，可按读者、图书、是否未还、是否逾期和借出时间过滤
+// @Description  sort 可选 borrowed_at（默认）、due_date、id
 // @Tags         借阅管理
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {array}  models.BorrowRecord
-// @Failure      401  {object}  ErrorResponse
-// @Failure      403  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
+// @Param        query  query     BorrowRecordListQuery  false  "过滤、分页和排序"
+// @Success      200    {object}  repositories.Page[models.BorrowRecord]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      401    {object}  ErrorResponse
+// @Failure      403    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
 // @Router       /admin/borrow-records [get]
 func (c *BookController) GetAllBorrowRecords(ctx *gin.Context) {
-	records, err := c.bookService.GetAllBorrowRecords()
+	var query BorrowRecordListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := query.filter()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	records, err := c.bookService.ListBorrowRecords(filter, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/services"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BookCopyController struct {
+	copyService services.BookCopyService
+}
+
+func NewBookCopyController(copyService services.BookCopyService) *BookCopyController {
+	return &BookCopyController{copyService: copyService}
+}
+
+// CopyRequest 登记一册副本，未提供条码时自动生成
+type CopyRequest struct {
+	Barcode    string   `json:"barcode" binding:"max=64" example:"C0012345"`
+	Status     string   `json:"status" binding:"omitempty,oneof=available in_repair lost withdrawn" example:"available"`
+	Condition  string   `json:"condition" binding:"omitempty,oneof=new good fair poor damaged" example:"new"`
+	AcquiredOn string   `json:"acquired_on" binding:"omitempty,datetime=2006-01-02" example:"2024-03-01"`
+	Price      *float64 `json:"price" binding:"omitempty,min=0" example:"139.00"`
+	Note       string   `json:"note" binding:"max=255"`
+}
+
+// AddCopiesRequest 为图书添加副本，提供 copies 时按其登记，否则生成 count 册自动条码的副本
+type AddCopiesRequest struct {
+	Count  int           `json:"count" binding:"required_without=Copies,omitempty,min=1,max=200" example:"2"`
+	Copies []CopyRequest `json:"copies" binding:"omitempty,max=200,dive"`
+}
+
+// UpdateCopyRequest 更新副本请求，未提供的字段保持不变
+type UpdateCopyRequest struct {
+	Status     *string  `json:"status" binding:"omitempty,oneof=available in_repair lost withdrawn" example:"in_repair"`
+	Condition  *string  `json:"condition" binding:"omitempty,oneof=new good fair poor damaged" example:"poor"`
+	AcquiredOn *string  `json:"acquired_on" binding:"omitempty,datetime=2006-01-02"`
+	Price      *float64 `json:"price" binding:"omitempty,min=0"`
+	Note       *string  `json:"note" binding:"omitempty,max=255"`
+}
+
+// GetCopies godoc
+// @Summary      获取图书的副本
+// @Description  分页列出图书的副本及其条码、状态和品相
+// @Description  sort 可选 id（默认，按登记顺序）、barcode、status
+// @Tags         馆藏副本
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      int        true   "图书ID"
+// @Param        query  query     ListQuery  false  "分页和排序"
+// @Success      200  {object}  repositories.Page[models.BookCopy]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /admin/books/{id}/copies [get]
+func (c *BookCopyController) GetCopies(ctx *gin.Context) {
+	bookID, ok := parseBookID(ctx)
+	if !ok {
+		return
+	}
+
+	var query ListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	copies, err := c.copyService.GetCopies(bookID, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, copies)
+}
+
+// AddCopies godoc
+// @Summary      添加副本
+// @Description  为图书登记新入藏的副本，未提供条码的副本自动生成条码
+// @Tags         馆藏副本
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int               true  "图书ID"
+// @Param        request  body  AddCopiesRequest  true  "副本信息"
+// @Success      201  {array}   models.BookCopy
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "条码已被使用"
+// @Router       /admin/books/{id}/copies [post]
+func (c *BookCopyController) AddCopies(ctx *gin.Context) {
+	bookID, ok := parseBookID(ctx)
+	if !ok {
+		return
+	}
+
+	var req AddCopiesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	copies := make([]models.BookCopy, req.Count)
+	if len(req.Copies) > 0 {
+		copies = make([]models.BookCopy, 0, len(req.Copies))
+		for _, copyReq := range req.Copies {
+			bookCopy, err := copyReq.toModel()
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			copies = append(copies, bookCopy)
+		}
+	}
+
+	created, err := c.copyService.AddCopies(actorFrom(ctx), bookID, copies)
+	if err != nil {
+		ctx.JSON(bookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, created)
+}
+
+// GetCopy godoc
+// @Summary      按条码查询副本
+// @Description  流通台扫码查询副本的状态和所属图书
+// @Tags         馆藏副本
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        barcode  path      string  true  "条码"
+// @Success      200      {object}  models.BookCopy
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Router       /admin/copies/{barcode} [get]
+func (c *BookCopyController) GetCopy(ctx *gin.Context) {
+	bookCopy, err := c.copyService.GetCopyByBarcode(ctx.Param("barcode"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, bookCopy)
+}
+
+// UpdateCopy godoc
+// @Summary      更新副本
+// @Description  修改副本的状态（送修、丢失、注销、重新上架）、品相、入藏日期、价格和备注；借出中的副本需先归还
+// @Tags         馆藏副本
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        barcode  path      string             true  "条码"
+// @Param        request  body      UpdateCopyRequest  true  "副本信息"
+// @Success      200      {object}  models.BookCopy
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/copies/{barcode} [put]
+func (c *BookCopyController) UpdateCopy(ctx *gin.Context) {
+	var req UpdateCopyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := services.BookCopyUpdate{Price: req.Price, Note: req.Note}
+	if req.Status != nil {
+		status := models.CopyStatus(*req.Status)
+		update.Status = &status
+	}
+	if req.Condition != nil {
+		condition := models.CopyCondition(*req.Condition)
+		update.Condition = &condition
+	}
+	if req.AcquiredOn != nil {
+		acquiredOn, err := parseDate(*req.AcquiredOn)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		update.AcquiredOn = acquiredOn
+	}
+
+	bookCopy, err := c.copyService.UpdateCopy(actorFrom(ctx), ctx.Param("barcode"), update)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, bookCopy)
+}
+
+// DeleteCopy godoc
+// @Summary      删除副本
+// @Description  删除误登记且从未借出过的副本，有借阅记录的副本请改为注销状态
+// @Tags         馆藏副本
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        barcode  path      string  true  "条码"
+// @Success      200      {object}  SuccessResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/copies/{barcode} [delete]
+func (c *BookCopyController) DeleteCopy(ctx *gin.Context) {
+	if err := c.copyService.DeleteCopy(actorFrom(ctx), ctx.Param("barcode")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "副本已删除"})
+}
+
+func (r CopyRequest) toModel() (models.BookCopy, error) {
+	bookCopy := models.BookCopy{
+		Barcode:   r.Barcode,
+		Status:    models.CopyStatus(r.Status),
+		Condition: models.CopyCondition(r.Condition),
+		Price:     r.Price,
+		Note:      r.Note,
+	}
+	if r.AcquiredOn != "" {
+		acquiredOn, err := parseDate(r.AcquiredOn)
+		if err != nil {
+			return bookCopy, err
+		}
+		bookCopy.AcquiredOn = acquiredOn
+	}
+	return bookCopy, nil
+}
+
+func parseDate(value string) (*time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("日期格式应为 YYYY-MM-DD: %s", value)
+	}
+	return &date, nil
+}
+
+func parseBookID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的图书ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryController struct {
+	categoryService services.CategoryService
+}
+
+func NewCategoryController(categoryService services.CategoryService) *CategoryController {
+	return &CategoryController{categoryService: categoryService}
+}
+
+// CategoryRequest 创建分类请求，不提供 parent_id 时创建顶级分类
+type CategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=100" example:"科幻"`
+	ParentID    *uint  `json:"parent_id" example:"1"`
+	Description string `json:"description" binding:"max=2000"`
+	Position    int    `json:"position" example:"0"`
+}
+
+// UpdateCategoryRequest 更新分类请求，未提供的字段保持不变
+type UpdateCategoryRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+	Position    *int    `json:"position"`
+}
+
+// MoveCategoryRequest 移动分类请求，parent_id 为空或 0 时移为顶级分类
+type MoveCategoryRequest struct {
+	ParentID *uint `json:"parent_id" example:"1"`
+}
+
+// MergeCategoryRequest 合并分类请求
+type MergeCategoryRequest struct {
+	TargetID uint `json:"target_id" binding:"required" example:"2"`
+}
+
+// GetCategories godoc
+// @Summary      获取分类树
+// @Description  获取完整的分类树，每个分类附带直接归入的图书数
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   services.CategoryNode
+// @Failure      500  {object}  ErrorResponse
+// @Router       /categories [get]
+func (c *CategoryController) GetCategories(ctx *gin.Context) {
+	tree, err := c.categoryService.GetTree()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tree)
+}
+
+// GetCategory godoc
+// @Summary      获取分类详情
+// @Description  获取分类及其各级上级分类和直接下级分类
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "分类ID"
+// @Success      200  {object}  services.CategoryDetail
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /categories/{id} [get]
+func (c *CategoryController) GetCategory(ctx *gin.Context) {
+	id, ok := parseCategoryID(ctx)
+	if !ok {
+		return
+	}
+
+	category, err := c.categoryService.GetCategory(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// GetCategoryBooks godoc
+// @Summary      获取分类中的图书
+// @Description  分页列出分类中的图书，默认包含全部下级分类的图书，排序参数与图书列表相同
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Param        id                   path      int        true   "分类ID"
+// @Param        include_descendants  query     bool       false  "是否包含下级分类的图书，默认 true"
+// @Param        query                query     ListQuery  false  "分页和排序"
+// @Success      200  {object}  repositories.Page[models.Book]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /categories/{id}/books [get]
+func (c *CategoryController) GetCategoryBooks(ctx *gin.Context) {
+	id, ok := parseCategoryID(ctx)
+	if !ok {
+		return
+	}
+	includeDescendants, err := strconv.ParseBool(ctx.DefaultQuery("include_descendants", "true"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "include_descendants 必须是 true 或 false"})
+		return
+	}
+
+	var query ListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := c.categoryService.GetCategoryBooks(id, includeDescendants, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, books)
+}
+
+// CreateCategory godoc
+// @Summary      创建分类
+// @Description  在指定上级分类下创建分类，同级分类不能重名
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      CategoryRequest  true  "分类信息"
+// @Success      201      {object}  models.Category
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/categories [post]
+func (c *CategoryController) CreateCategory(ctx *gin.Context) {
+	var req CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := &models.Category{
+		Name:        req.Name,
+		ParentID:    optionalCategoryID(req.ParentID),
+		Description: req.Description,
+		Position:    req.Position,
+	}
+	if err := c.categoryService.CreateCategory(actorFrom(ctx), category); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory godoc
+// @Summary      更新分类
+// @Description  修改分类名称、说明和同级排列顺序
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int                    true  "分类ID"
+// @Param        request  body      UpdateCategoryRequest  true  "分类信息"
+// @Success      200      {object}  models.Category
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/categories/{id} [put]
+func (c *CategoryController) UpdateCategory(ctx *gin.Context) {
+	id, ok := parseCategoryID(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateCategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := c.categoryService.UpdateCategory(actorFrom(ctx), id, services.CategoryUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		Position:    req.Position,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// MoveCategory godoc
+// @Summary      移动分类
+// @Description  把分类连同全部下级分类移到另一个上级分类之下，或移为顶级分类
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int                  true  "分类ID"
+// @Param        request  body      MoveCategoryRequest  true  "新的上级分类"
+// @Success      200      {object}  models.Category
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/categories/{id}/move [post]
+func (c *CategoryController) MoveCategory(ctx *gin.Context) {
+	id, ok := parseCategoryID(ctx)
+	if !ok {
+		return
+	}
+
+	var req MoveCategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := c.categoryService.MoveCategory(actorFrom(ctx), id, optionalCategoryID(req.ParentID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// MergeCategory godoc
+// @Summary      合并分类
+// @Description  把分类的图书和下级分类并入目标分类，然后删除该分类
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int                   true  "被合并的分类ID"
+// @Param        request  body      MergeCategoryRequest  true  "目标分类"
+// @Success      200      {object}  models.Category
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/categories/{id}/merge [post]
+func (c *CategoryController) MergeCategory(ctx *gin.Context) {
+	id, ok := parseCategoryID(ctx)
+	if !ok {
+		return
+	}
+
+	var req MergeCategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, err := c.categoryService.MergeCategory(actorFrom(ctx), id, req.TargetID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, target)
+}
+
+// DeleteCategory godoc
+// @Summary      删除分类
+// @Description  删除没有下级分类和图书的分类
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "分类ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/categories/{id} [delete]
+func (c *CategoryController) DeleteCategory(ctx *gin.Context) {
+	id, ok := parseCategoryID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.categoryService.DeleteCategory(actorFrom(ctx), id); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "分类已删除"})
+}
+
+func parseCategoryID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的分类ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// optionalCategoryID 上级分类ID为 0 时视为未提供
+func optionalCategoryID(id *uint) *uint {
+	if id == nil || *id == 0 {
+		return nil
+	}
+	return id
+}
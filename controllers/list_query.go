@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ListQuery 列表接口共用的分页和排序参数
+// 提供 cursor 时按游标翻页并忽略 page，cursor 取自上一页响应的 next_cursor
+type ListQuery struct {
+	Page     int    `form:"page" example:"1"`
+	PageSize int    `form:"page_size" example:"20"`
+	Cursor   string `form:"cursor"`
+	Sort     string `form:"sort" example:"created_at"`
+	Order    string `form:"order" enums:"asc,desc"`
+}
+
+func (q *ListQuery) spec() repositories.QuerySpec {
+	return repositories.QuerySpec{
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Cursor:   q.Cursor,
+		Sort:     q.Sort,
+		Order:    q.Order,
+	}
+}
+
+// KeywordListQuery 按关键词查找的列表参数
+type KeywordListQuery struct {
+	ListQuery
+	Query string `form:"q"`
+}
+
+// AuthorListQuery 作者列表参数
+type AuthorListQuery struct {
+	ListQuery
+	Query       string `form:"q" example:"Rowling"`
+	NeedsReview *bool  `form:"needs_review"`
+}
+
+func (q *AuthorListQuery) filter() repositories.AuthorFilter {
+	return repositories.AuthorFilter{Query: q.Query, NeedsReview: q.NeedsReview}
+}
+
+// BookListQuery 图书列表参数
+type BookListQuery struct {
+	ListQuery
+	Query     string `form:"q" example:"三体"`
+	Author    string `form:"author" example:"刘慈欣"`
+	Publisher string `form:"publisher"`
+	Language  string `form:"language" example:"zh"`
+	Available *bool  `form:"available"`
+}
+
+func (q *BookListQuery) filter() repositories.BookFilter {
+	return repositories.BookFilter{
+		Query:     q.Query,
+		Author:    q.Author,
+		Publisher: q.Publisher,
+		Language:  q.Language,
+		Available: q.Available,
+	}
+}
+
+// BorrowRecordListQuery 借阅记录列表参数
+// borrowed_after/borrowed_before 支持 RFC3339 时间或 YYYY-MM-DD 日期，日期形式的 borrowed_before 包含当天
+type BorrowRecordListQuery struct {
+	ListQuery
+	UserID         uint   `form:"user_id"`
+	BookID         uint   `form:"book_id"`
+	Active         *bool  `form:"active"`
+	Overdue        bool   `form:"overdue"`
+	BorrowedAfter  string `form:"borrowed_after" example:"2026-01-01"`
+	BorrowedBefore string `form:"borrowed_before" example:"2026-03-31"`
+}
+
+func (q *BorrowRecordListQuery) filter() (repositories.BorrowRecordFilter, error) {
+	filter := repositories.BorrowRecordFilter{
+		UserID:  q.UserID,
+		BookID:  q.BookID,
+		Active:  q.Active,
+		Overdue: q.Overdue,
+	}
+
+	if q.BorrowedAfter != "" {
+		after, _, err := parseQueryTime(q.BorrowedAfter)
+		if err != nil {
+			return filter, errors.New("无效的 borrowed_after，请使用 RFC3339 或 YYYY-MM-DD 格式")
+		}
+		filter.BorrowedAfter = &after
+	}
+	if q.BorrowedBefore != "" {
+		before, dateOnly, err := parseQueryTime(q.BorrowedBefore)
+		if err != nil {
+			return filter, errors.New("无效的 borrowed_before，请使用 RFC3339 或 YYYY-MM-DD 格式")
+		}
+		if dateOnly {
+			before = before.AddDate(0, 0, 1)
+		}
+		filter.BorrowedBefore = &before
+	}
+
+	return filter, nil
+}
+
+// UserListQuery 用户列表参数
+type UserListQuery struct {
+	ListQuery
+	Query  string            `form:"q"`
+	Role   models.UserRole   `form:"role" enums:"admin,librarian,user"`
+	Status models.UserStatus `form:"status" enums:"pending,active,suspended"`
+}
+
+func (q *UserListQuery) filter() repositories.UserFilter {
+	return repositories.UserFilter{Query: q.Query, Role: q.Role, Status: q.Status}
+}
+
+// listErrorStatus 分页、排序或游标参数有误时返回 400，其余错误返回 fallback
+func listErrorStatus(err error, fallback int) int {
+	if errors.Is(err, repositories.ErrInvalidQuery) {
+		return http.StatusBadRequest
+	}
+	return fallback
+}
+
+// parseQueryTime 解析 RFC3339 时间或 YYYY-MM-DD 日期，日期按服务器本地时区解释
+func parseQueryTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	return t, true, err
+}
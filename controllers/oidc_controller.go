@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"book-management-system/config"
+	"book-management-system/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oidcStateCookie = "oidc_state"
+
+type OIDCController struct {
+	oidcService services.OIDCService
+}
+
+func NewOIDCController(oidcService services.OIDCService) *OIDCController {
+	return &OIDCController{oidcService: oidcService}
+}
+
+// Login godoc
+// @Summary      单点登录
+// @Description  跳转到身份提供方进行登录（OIDC 授权码 + PKCE），登录完成后回调 /auth/oidc/callback
+// @Tags         认证
+// @Produce      json
+// @Success      302
+// @Failure      404  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
+// @Router       /auth/oidc/login [get]
+func (c *OIDCController) Login(ctx *gin.Context) {
+	if !c.oidcService.Enabled() {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	authorization, err := c.oidcService.BeginLogin(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 回调请求由身份提供方跨站跳转发起，Cookie 需要使用 Lax 才能被携带
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, authorization.StateCookie, int(authorization.ExpiresIn.Seconds()),
+		"/api/auth/oidc", "", !config.AppConfig.IsDevelopment(), true)
+	ctx.Redirect(http.StatusFound, authorization.URL)
+}
+
+// Callback godoc
+// @Summary      单点登录回调
+// @Description  身份提供方登录完成后的回调，按邮箱关联或自动创建账户并签发系统令牌；账户启用两步验证时返回挑战令牌
+// @Tags         认证
+// @Produce      json
+// @Param        code   query  string  true  "授权码"
+// @Param        state  query  string  true  "登录请求标识"
+// @Success      200  {object}  AuthResponse
+// @Success      202  {object}  TwoFactorChallengeResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /auth/oidc/callback [get]
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	if !c.oidcService.Enabled() {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	// state Cookie 只能使用一次
+	stateCookie, _ := ctx.Cookie(oidcStateCookie)
+	ctx.SetCookie(oidcStateCookie, "", -1, "/api/auth/oidc", "", !config.AppConfig.IsDevelopment(), true)
+
+	if idpError := ctx.Query("error"); idpError != "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "身份提供方拒绝了登录请求: " + idpError, "description": ctx.Query("error_description")})
+		return
+	}
+
+	code := ctx.Query("code")
+	if code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少授权码"})
+		return
+	}
+
+	result, err := c.oidcService.CompleteLogin(ctx.Request.Context(), stateCookie, ctx.Query("state"), code, clientInfo(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOIDCEmailRequired), errors.Is(err, services.ErrOIDCNotProvisioned):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			respondLoginError(ctx, err)
+		}
+		return
+	}
+
+	if result.TwoFactorRequired {
+		ctx.JSON(http.StatusAccepted, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    result.ChallengeToken,
+			ExpiresIn:         int64(config.AppConfig.TwoFactorChallengeExpire.Seconds()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAuthResponse(result.User, result.Tokens))
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct {
+	roleService services.RoleService
+}
+
+func NewRoleController(roleService services.RoleService) *RoleController {
+	return &RoleController{roleService: roleService}
+}
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	Name        models.UserRole     `json:"name" binding:"required" example:"cataloger"`
+	Description string              `json:"description" example:"编目员"`
+	Permissions []models.Permission `json:"permissions" example:"book:create,book:update"`
+}
+
+// UpdateRoleRequest 更新角色请求
+type UpdateRoleRequest struct {
+	Description string              `json:"description" example:"编目员"`
+	Permissions []models.Permission `json:"permissions" example:"book:create,book:update"`
+}
+
+// AssignRoleRequest 分配角色请求
+type AssignRoleRequest struct {
+	Role models.UserRole `json:"role" binding:"required" example:"librarian"`
+}
+
+// GetAllRoles godoc
+// @Summary      获取角色列表
+// @Description  获取所有角色及其权限
+// @Tags         角色管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Role
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/roles [get]
+func (c *RoleController) GetAllRoles(ctx *gin.Context) {
+	roles, err := c.roleService.GetAllRoles()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, roles)
+}
+
+// GetPermissions godoc
+// @Summary      获取权限列表
+// @Description  获取系统中定义的全部权限标识
+// @Tags         角色管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   string
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/permissions [get]
+func (c *RoleController) GetPermissions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, models.AllPermissions)
+}
+
+// CreateRole godoc
+// @Summary      创建角色
+// @Description  创建新的命名权限集合
+// @Tags         角色管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateRoleRequest  true  "角色信息"
+// @Success      201  {object}  models.Role
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/roles [post]
+func (c *RoleController) CreateRole(ctx *gin.Context) {
+	var req CreateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := c.roleService.CreateRole(actorFrom(ctx), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole godoc
+// @Summary      更新角色
+// @Description  修改角色的描述和权限集合
+// @Tags         角色管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        name     path  string             true  "角色名"
+// @Param        request  body  UpdateRoleRequest  true  "角色信息"
+// @Success      200  {object}  models.Role
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/roles/{name} [put]
+func (c *RoleController) UpdateRole(ctx *gin.Context) {
+	var req UpdateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := c.roleService.UpdateRole(actorFrom(ctx), models.UserRole(ctx.Param("name")), req.Description, req.Permissions)
+	if err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, role)
+}
+
+// DeleteRole godoc
+// @Summary      删除角色
+// @Description  删除自定义角色，内置角色和仍有用户使用的角色不能删除
+// @Tags         角色管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        name  path  string  true  "角色名"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/roles/{name} [delete]
+func (c *RoleController) DeleteRole(ctx *gin.Context) {
+	if err := c.roleService.DeleteRole(actorFrom(ctx), models.UserRole(ctx.Param("name"))); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "角色删除成功"})
+}
+
+// AssignRole godoc
+// @Summary      分配用户角色
+// @Description  修改用户角色，用户现有的令牌会被撤销以使新角色立即生效
+// @Tags         角色管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                true  "用户ID"
+// @Param        request  body  AssignRoleRequest  true  "角色"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/role [put]
+func (c *RoleController) AssignRole(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.roleService.AssignRole(actorFrom(ctx), uint(id), req.Role); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "角色分配成功", "role": req.Role})
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"book-management-system/services"
+	"book-management-system/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	sessionService services.SessionService
+}
+
+func NewSessionController(sessionService services.SessionService) *SessionController {
+	return &SessionController{sessionService: sessionService}
+}
+
+// GetMySessions godoc
+// @Summary      获取登录会话
+// @Description  分页列出当前用户在各设备上仍然有效的登录会话，current 标记发起请求的会话
+// @Description  sort 可选 last_seen_at（默认，最近活跃的在前）、created_at、id
+// @Tags         会话
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        query  query     ListQuery  false  "分页和排序"
+// @Success      200  {object}  repositories.Page[models.Session]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/sessions [get]
+func (c *SessionController) GetMySessions(ctx *gin.Context) {
+	c.respondSessions(ctx, ctx.GetUint("userID"))
+}
+
+// RevokeMySession godoc
+// @Summary      退出指定设备
+// @Description  退出当前用户的指定会话，该设备上的访问令牌和刷新令牌立即失效
+// @Tags         会话
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "会话ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /users/sessions/{id} [delete]
+func (c *SessionController) RevokeMySession(ctx *gin.Context) {
+	c.revokeSession(ctx, ctx.GetUint("userID"), ctx.Param("id"))
+}
+
+// GetUserSessions godoc
+// @Summary      获取用户的登录会话
+// @Description  管理员查看指定用户仍然有效的登录会话
+// @Tags         会话
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Param        query  query     ListQuery  false  "分页和排序"
+// @Success      200  {object}  repositories.Page[models.Session]
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/users/{id}/sessions [get]
+func (c *SessionController) GetUserSessions(ctx *gin.Context) {
+	userID, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+	c.respondSessions(ctx, userID)
+}
+
+// RevokeUserSession godoc
+// @Summary      退出用户的指定会话
+// @Description  管理员让指定用户的某个设备退出登录
+// @Tags         会话
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path  int  true  "用户ID"
+// @Param        sessionId  path  int  true  "会话ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/sessions/{sessionId} [delete]
+func (c *SessionController) RevokeUserSession(ctx *gin.Context) {
+	userID, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+	c.revokeSession(ctx, userID, ctx.Param("sessionId"))
+}
+
+func (c *SessionController) respondSessions(ctx *gin.Context, userID uint) {
+	var query ListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, err := c.sessionService.List(userID, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	currentID := currentSessionID(ctx)
+	for i := range sessions.Items {
+		sessions.Items[i].Current = currentID != 0 && sessions.Items[i].ID == currentID
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func (c *SessionController) revokeSession(ctx *gin.Context, userID uint, rawSessionID string) {
+	sessionID, err := strconv.ParseUint(rawSessionID, 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	if err := c.sessionService.Revoke(actorFrom(ctx), userID, uint(sessionID)); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "会话已退出"})
+}
+
+// currentSessionID 返回发起请求的访问令牌所属的会话
+func currentSessionID(ctx *gin.Context) uint {
+	value, exists := ctx.Get("claims")
+	if !exists {
+		return 0
+	}
+	claims, ok := value.(*utils.Claims)
+	if !ok {
+		return 0
+	}
+	return claims.SessionID
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"book-management-system/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagController struct {
+	tagService services.TagService
+}
+
+func NewTagController(tagService services.TagService) *TagController {
+	return &TagController{tagService: tagService}
+}
+
+// RenameTagRequest 标签改名请求，新名称与已有标签相同时合并到该标签
+type RenameTagRequest struct {
+	Name string `json:"name" binding:"required,max=50" example:"科幻小说"`
+}
+
+// GetTags godoc
+// @Summary      查询标签
+// @Description  按名称分页查找标签并附带图书数，不提供关键词时返回全部标签
+// @Description  sort 可选 book_count（默认，常用标签在前）、name、id
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Param        query  query     KeywordListQuery  false  "标签关键词、分页和排序"
+// @Success      200    {object}  repositories.Page[models.TagCount]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
+// @Router       /tags [get]
+func (c *TagController) GetTags(ctx *gin.Context) {
+	var query KeywordListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := c.tagService.SearchTags(query.Query, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tags)
+}
+
+// GetTagBooks godoc
+// @Summary      获取标签下的图书
+// @Description  分页列出带有该标签的图书，排序参数与图书列表相同
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int        true   "标签ID"
+// @Param        query  query     ListQuery  false  "分页和排序"
+// @Success      200    {object}  repositories.Page[models.Book]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      404    {object}  ErrorResponse
+// @Router       /tags/{id}/books [get]
+func (c *TagController) GetTagBooks(ctx *gin.Context) {
+	id, ok := parseTagID(ctx)
+	if !ok {
+		return
+	}
+
+	var query ListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := c.tagService.GetTagBooks(id, query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, books)
+}
+
+// RenameTag godoc
+// @Summary      标签改名
+// @Description  修改标签名称，新名称与已有标签相同（不区分大小写）时把该标签合并过去
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int               true  "标签ID"
+// @Param        request  body      RenameTagRequest  true  "新名称"
+// @Success      200      {object}  models.Tag
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Router       /admin/tags/{id} [put]
+func (c *TagController) RenameTag(ctx *gin.Context) {
+	id, ok := parseTagID(ctx)
+	if !ok {
+		return
+	}
+
+	var req RenameTagRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := c.tagService.RenameTag(actorFrom(ctx), id, req.Name)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag godoc
+// @Summary      删除标签
+// @Description  删除标签，图书上的该标签一并移除
+// @Tags         分类与标签
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "标签ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Router       /admin/tags/{id} [delete]
+func (c *TagController) DeleteTag(ctx *gin.Context) {
+	id, ok := parseTagID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.tagService.DeleteTag(actorFrom(ctx), id); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "标签已删除"})
+}
+
+func parseTagID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的标签ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"book-management-system/services"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorController struct {
+	twoFactorService services.TwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService services.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{twoFactorService: twoFactorService}
+}
+
+// TwoFactorCodeRequest 携带验证码的请求体
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// DisableTwoFactorRequest 停用两步验证请求体
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+// RegenerateRecoveryCodesRequest 重新生成恢复码请求体
+type RegenerateRecoveryCodesRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+// RecoveryCodesResponse 恢复码响应，恢复码只显示这一次
+type RecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// GetStatus godoc
+// @Summary      获取两步验证状态
+// @Description  查看当前用户是否启用两步验证及剩余的恢复码数量
+// @Tags         两步验证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  services.TwoFactorStatus
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/2fa [get]
+func (c *TwoFactorController) GetStatus(ctx *gin.Context) {
+	status, err := c.twoFactorService.Status(ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+// Enroll godoc
+// @Summary      绑定验证器
+// @Description  生成TOTP密钥和otpauth URI，使用验证器扫码后调用确认接口启用两步验证
+// @Tags         两步验证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  services.TOTPEnrollment
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/2fa/enroll [post]
+func (c *TwoFactorController) Enroll(ctx *gin.Context) {
+	enrollment, err := c.twoFactorService.BeginEnrollment(ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm godoc
+// @Summary      确认启用两步验证
+// @Description  提交验证器生成的验证码启用两步验证，返回一组一次性恢复码
+// @Tags         两步验证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  TwoFactorCodeRequest  true  "验证码"
+// @Success      200  {object}  RecoveryCodesResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /users/2fa/confirm [post]
+func (c *TwoFactorController) Confirm(ctx *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := c.twoFactorService.ConfirmEnrollment(actorFrom(ctx), req.Code)
+	if err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RecoveryCodesResponse{
+		Message:       "两步验证已启用，请妥善保存恢复码，刷新令牌或重新登录后生效",
+		RecoveryCodes: codes,
+	})
+}
+
+// Disable godoc
+// @Summary      停用两步验证
+// @Description  需要同时提供密码和验证码（或恢复码），连续失败会与登录一样锁定账户
+// @Tags         两步验证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  DisableTwoFactorRequest  true  "密码和验证码"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /users/2fa/disable [post]
+func (c *TwoFactorController) Disable(ctx *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.twoFactorService.Disable(actorFrom(ctx), req.Password, req.Code); err != nil {
+		respondTwoFactorError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "两步验证已停用"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      重新生成恢复码
+// @Description  需要同时提供密码和验证码（或恢复码），原有恢复码全部作废；连续失败会与登录一样锁定账户
+// @Tags         两步验证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  RegenerateRecoveryCodesRequest  true  "密码和验证码"
+// @Success      200  {object}  RecoveryCodesResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /users/2fa/recovery-codes [post]
+func (c *TwoFactorController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req RegenerateRecoveryCodesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := c.twoFactorService.RegenerateRecoveryCodes(actorFrom(ctx), req.Password, req.Code)
+	if err != nil {
+		respondTwoFactorError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RecoveryCodesResponse{
+		Message:       "恢复码已重新生成，原有恢复码已作废",
+		RecoveryCodes: codes,
+	})
+}
+
+// ResetUserTwoFactor godoc
+// @Summary      重置用户的两步验证
+// @Description  管理员为丢失验证器和恢复码的用户清除两步验证，并撤销其全部会话
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/2fa/reset [post]
+func (c *TwoFactorController) ResetUserTwoFactor(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.twoFactorService.Reset(actorFrom(ctx), id); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已重置该用户的两步验证"})
+}
+
+// respondTwoFactorError 密码或验证码连续错误导致账户锁定时返回 429，其余错误按审计可用性返回 503 或 400
+func respondTwoFactorError(ctx *gin.Context, err error) {
+	var locked *services.ErrAccountLocked
+	if errors.As(err, &locked) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"book-management-system/models"
+	"book-management-system/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserController struct {
+	userService services.UserService
+}
+
+func NewUserController(userService services.UserService) *UserController {
+	return &UserController{userService: userService}
+}
+
+// CreateUserRequest 管理员创建用户请求
+type CreateUserRequest struct {
+	Username string          `json:"username" binding:"required,min=3,max=50" example:"librarian01"`
+	Password string          `json:"password" binding:"required,min=6" example:"password123"`
+	Email    string          `json:"email" binding:"required,email" example:"librarian01@example.com"`
+	Role     models.UserRole `json:"role" example:"librarian"`
+}
+
+// CreateUser godoc
+// @Summary      创建用户
+// @Description  管理员创建用户并指定角色，指定默认读者角色以外的角色还需要 role:manage 权限
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateUserRequest  true  "用户信息"
+// @Success      201  {object}  models.User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users [post]
+func (c *UserController) CreateUser(ctx *gin.Context) {
+	var req CreateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := c.userService.CreateUser(actorFrom(ctx), req.Username, req.Password, req.Email, req.Role)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrRoleAuthority) {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(auditErrorStatus(err, status), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, user)
+}
+
+// SuspendUser godoc
+// @Summary      停用用户
+// @Description  停用账户并撤销其全部会话，停用期间持有的令牌也会被拒绝
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/suspend [post]
+func (c *UserController) SuspendUser(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.userService.SuspendUser(actorFrom(ctx), id); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "账户已停用"})
+}
+
+// ActivateUser godoc
+// @Summary      启用用户
+// @Description  重新启用已停用的账户
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/activate [post]
+func (c *UserController) ActivateUser(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.userService.ActivateUser(actorFrom(ctx), id); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "账户已启用"})
+}
+
+// DeleteUser godoc
+// @Summary      删除用户
+// @Description  软删除用户，有未归还图书的用户和最后一个管理员不能删除
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id} [delete]
+func (c *UserController) DeleteUser(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.userService.DeleteUser(actorFrom(ctx), id); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "用户删除成功"})
+}
+
+// RestoreUser godoc
+// @Summary      恢复用户
+// @Description  恢复已软删除的用户
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  models.User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/restore [post]
+func (c *UserController) RestoreUser(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.userService.RestoreUser(actorFrom(ctx), id)
+	if err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// UnlockUser godoc
+// @Summary      解除账户锁定
+// @Description  清零用户的登录失败次数并解除因暴力破解防护造成的临时锁定
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "用户ID"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/unlock [post]
+func (c *UserController) UnlockUser(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.userService.UnlockUser(actorFrom(ctx), id); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "账户已解除锁定"})
+}
+
+// AdminResetPasswordRequest 管理员重置密码请求体
+type AdminResetPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required,min=6" example:"newpassword456"`
+}
+
+// ResetUserPassword godoc
+// @Summary      重置用户密码
+// @Description  管理员直接为用户设置新密码，撤销其全部会话和 API 密钥并解除登录锁定，LDAP 账户不支持
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                        true  "用户ID"
+// @Param        request  body  AdminResetPasswordRequest  true  "新密码"
+// @Success      200  {object}  SuccessResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/users/{id}/password/reset [post]
+func (c *UserController) ResetUserPassword(ctx *gin.Context) {
+	id, ok := parseUserID(ctx)
+	if !ok {
+		return
+	}
+
+	var req AdminResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.userService.ResetPassword(actorFrom(ctx), id, req.NewPassword); err != nil {
+		ctx.JSON(auditErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "密码已重置，现有会话和 API 密钥已撤销"})
+}
+
+// GetDeletedUsers godoc
+// @Summary      获取已删除用户
+// @Description  管理员分页查看已软删除、可恢复的用户，过滤参数与用户列表相同
+// @Description  sort 可选 deleted_at（默认）、username、id
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        query  query     UserListQuery  false  "过滤、分页和排序"
+// @Success      200    {object}  repositories.Page[models.User]
+// @Failure      400    {object}  ErrorResponse
+// @Failure      401    {object}  ErrorResponse
+// @Failure      403    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
+// @Router       /admin/users/deleted [get]
+func (c *UserController) GetDeletedUsers(ctx *gin.Context) {
+	var query UserListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := c.userService.GetDeletedUsers(query.filter(), query.spec())
+	if err != nil {
+		ctx.JSON(listErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, users)
+}
+
+// parseUserID 解析路径中的用户ID，失败时直接写入400响应
+func parseUserID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"book-management-system/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WellKnownController struct {
+	tokenService utils.TokenService
+}
+
+func NewWellKnownController(tokenService utils.TokenService) *WellKnownController {
+	return &WellKnownController{tokenService: tokenService}
+}
+
+// JWKS godoc
+// @Summary      令牌校验公钥
+// @Description  以 JWKS 格式公开当前所有有效的令牌校验公钥，其他服务可据此按 kid 校验访问令牌；使用 HS256 签名时为空
+// @Tags         认证
+// @Produce      json
+// @Success      200  {object}  utils.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (c *WellKnownController) JWKS(ctx *gin.Context) {
+	// 允许校验方短时间缓存，轮换时新公钥应提前加入校验列表
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.tokenService.JWKS())
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"book-management-system/config"
 	"book-management-system/routers"
+	"book-management-system/utils"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 )
 
 // @title          图书管理系统 API
@@ -26,27 +29,93 @@ import (
 // @name Authorization
 // @description 输入"Bearer {token}"，token在登录后获得
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description 个人API密钥，也可以通过 Authorization: ApiKey {key} 传递
+
 // @schemes http
 
 func main() {
+	// 第一个非选项参数为子命令，缺省时启动服务器
+	command := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	if command == "help" {
+		printUsage()
+		return
+	}
+
 	// 加载配置
 	config.LoadConfig()
+	if err := config.AppConfig.Validate(); err != nil {
+		log.Fatalf("配置错误: %v", err)
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe()
+	case "migrate":
+		err = runMigrate()
+	case "create-admin":
+		err = runCreateAdmin(args)
+	case "reset-password":
+		err = runResetPassword(args)
+	case "list-users":
+		err = runListUsers(args)
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", command)
+		printUsage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s 执行失败: %v", command, err)
+	}
+}
 
-	// 调试：打印配置信息
-	fmt.Printf("JWT Secret长度: %d\n", len(config.AppConfig.JWTSecret))
-	fmt.Printf("JWT Expire: %v\n", config.AppConfig.JWTExpire)
+func runServe() error {
+	// 加载令牌签名密钥
+	tokenService, err := utils.NewTokenServiceFromConfig(config.AppConfig)
+	if err != nil {
+		return fmt.Errorf("加载签名密钥失败: %w", err)
+	}
+	signing := tokenService.SigningKey()
+	log.Printf("令牌签名算法: %s，kid: %s，访问令牌有效期: %v", signing.Method.Alg(), signing.KID, config.AppConfig.JWTExpire)
 
 	// 连接数据库
 	if err := config.ConnectDatabase(); err != nil {
-		log.Fatal("数据库连接失败:", err)
+		return fmt.Errorf("数据库连接失败: %w", err)
 	}
 
 	// 设置路由
-	router := routers.SetupRouter()
+	router := routers.SetupRouter(tokenService)
 
 	// 启动服务器
 	log.Printf("服务器启动在端口 %s", config.AppConfig.ServerPort)
 	if err := router.Run(":" + config.AppConfig.ServerPort); err != nil {
-		log.Fatal("服务器启动失败:", err)
+		return fmt.Errorf("服务器启动失败: %w", err)
 	}
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, `用法: %s <命令> [参数]
+
+命令:
+  serve            启动HTTP服务器（默认）
+  migrate          迁移数据库表结构并初始化内置角色
+  create-admin     创建管理员账户
+                     -username 用户名  -email 邮箱（密码从 ADMIN_PASSWORD 或标准输入读取）
+  reset-password   重置用户密码并撤销其全部会话和 API 密钥
+                     -username 用户名（新密码从标准输入读取）
+  list-users       列出用户
+                     -role 按角色过滤
+  help             显示本帮助
+`, os.Args[0])
 }
@@ -1,35 +1,100 @@
 package middlewares
 
 import (
-	"book-management-system/utils"
-	"fmt"
+	"book-management-system/models"
+	"book-management-system/services"
 	"net/http"
-	// "strings"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware 认证请求，支持以下方式：
+//   - Authorization: Bearer <访问令牌>（RFC 6750）
+//   - Authorization: ApiKey <API密钥>
+//   - X-API-Key: <API密钥>
+func AuthMiddleware(authService services.AuthService, apiKeyService services.APIKeyService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		authHeader := ctx.GetHeader("Authorization")
-		fmt.Println("Authorization Header:", authHeader)
-		if authHeader == "" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "需要登录"})
+		scheme, credential, ok := credentials(ctx)
+		if !ok {
+			ctx.Header("WWW-Authenticate", `Bearer realm="api"`)
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "需要登录，请在 Authorization 头中使用 Bearer 令牌或 API 密钥"})
 			ctx.Abort()
 			return
 		}
 
-		// tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		// fmt.Println("Extracted Token:", tokenString)
-		claims, err := utils.ValidateToken(authHeader)
+		if scheme == "apikey" {
+			user, key, err := apiKeyService.Authenticate(credential)
+			if err != nil {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				ctx.Abort()
+				return
+			}
+
+			ctx.Set("userID", user.ID)
+			ctx.Set("username", user.Username)
+			ctx.Set("userRole", user.Role)
+			ctx.Set("apiKey", key)
+			// 密钥只能由已登录的用户创建，管理员的两步验证要求以账户是否启用为准
+			ctx.Set("mfa", user.TOTPEnabled)
+			ctx.Set("emailUnverified", user.Status == models.UserStatusPending)
+			ctx.Next()
+			return
+		}
+
+		user, claims, err := authService.Authenticate(credential, services.ClientInfo{IP: ctx.ClientIP(), UserAgent: ctx.Request.UserAgent()})
 		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "令牌无效" + err.Error()})
+			ctx.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			ctx.Abort()
 			return
 		}
 
 		ctx.Set("userID", claims.UserID)
-		ctx.Set("userRole", claims.Role)
+		ctx.Set("username", user.Username)
+		// 以数据库中的当前角色为准，令牌中的角色可能已被管理员或用户组同步修改
+		ctx.Set("userRole", user.Role)
+		ctx.Set("claims", claims)
+		ctx.Set("mfa", claims.MFA)
+		// 未验证邮箱的用户在 restrict 策略下可以登录，但不授予任何权限
+		ctx.Set("emailUnverified", user.Status == models.UserStatusPending)
 		ctx.Next()
 	}
 }
+
+// RequireSession 只允许使用访问令牌的请求，API 密钥不能用于修改密码、管理密钥等账户安全操作
+func RequireSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, usingAPIKey := ctx.Get("apiKey"); usingAPIKey {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "该操作不能使用API密钥，请登录后重试"})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// credentials 从请求头中取出认证方式（bearer 或 apikey）和凭据，认证方案名不区分大小写
+func credentials(ctx *gin.Context) (string, string, bool) {
+	if key := strings.TrimSpace(ctx.GetHeader("X-API-Key")); key != "" {
+		return "apikey", key, true
+	}
+
+	scheme, credential, found := strings.Cut(strings.TrimSpace(ctx.GetHeader("Authorization")), " ")
+	if !found {
+		return "", "", false
+	}
+
+	credential = strings.TrimSpace(credential)
+	if credential == "" || strings.ContainsAny(credential, " \t") {
+		return "", "", false
+	}
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		return "bearer", credential, true
+	case strings.EqualFold(scheme, "ApiKey"):
+		return "apikey", credential, true
+	}
+	return "", "", false
+}
@@ -1,19 +1,186 @@
 package middlewares
 
-import "github.com/gin-gonic/gin"
+import (
+	"book-management-system/config"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	"github.com/gin-gonic/gin"
+)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// CORSRule 为某个路径前缀下的路由组指定跨域策略
+type CORSRule struct {
+	PathPrefix string
+	Policy     config.CORSPolicy
+}
+
+// corsPolicy 预先处理好的跨域策略
+type corsPolicy struct {
+	anyOrigin       bool
+	origins         map[string]bool
+	wildcards       [][2]string // 子域名通配的源拆分为通配符前后两部分
+	methods         map[string]bool
+	headers         map[string]bool
+	allowMethods    string
+	allowHeaders    string
+	exposeHeaders   string
+	credentials     bool
+	maxAge          string
+	pathPrefix      string
+	pathPrefixSlash string
+}
+
+// CORS 按请求路径选择最长匹配的路由组策略，未匹配时使用默认策略
+// 预检请求在这里直接应答，因此必须注册在引擎上，而不是路由组上
+func CORS(defaultPolicy config.CORSPolicy, rules ...CORSRule) gin.HandlerFunc {
+	fallback := compileCORSPolicy(defaultPolicy, "")
+	policies := make([]*corsPolicy, 0, len(rules))
+	for _, rule := range rules {
+		policies = append(policies, compileCORSPolicy(rule.Policy, strings.TrimSuffix(rule.PathPrefix, "/")))
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return len(policies[i].pathPrefix) > len(policies[j].pathPrefix)
+	})
+
+	return func(ctx *gin.Context) {
+		policy := fallback
+		for _, p := range policies {
+			if p.matchesPath(ctx.Request.URL.Path) {
+				policy = p
+				break
+			}
+		}
+		policy.handle(ctx)
+	}
+}
+
+func compileCORSPolicy(policy config.CORSPolicy, pathPrefix string) *corsPolicy {
+	p := &corsPolicy{
+		origins:         make(map[string]bool),
+		methods:         make(map[string]bool),
+		headers:         make(map[string]bool),
+		credentials:     policy.AllowCredentials,
+		exposeHeaders:   strings.Join(policy.ExposedHeaders, ", "),
+		maxAge:          strconv.Itoa(int(policy.MaxAge.Seconds())),
+		pathPrefix:      pathPrefix,
+		pathPrefixSlash: pathPrefix + "/",
+	}
+
+	for _, origin := range policy.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			before, after, _ := strings.Cut(origin, "*")
+			p.wildcards = append(p.wildcards, [2]string{before, after})
+		default:
+			p.origins[origin] = true
+		}
+	}
+
+	methods := make([]string, 0, len(policy.AllowedMethods))
+	for _, method := range policy.AllowedMethods {
+		method = strings.ToUpper(method)
+		p.methods[method] = true
+		methods = append(methods, method)
+	}
+	p.allowMethods = strings.Join(methods, ", ")
+
+	for _, header := range policy.AllowedHeaders {
+		p.headers[strings.ToLower(header)] = true
+	}
+	p.allowHeaders = strings.Join(policy.AllowedHeaders, ", ")
+
+	return p
+}
+
+func (p *corsPolicy) matchesPath(path string) bool {
+	return path == p.pathPrefix || strings.HasPrefix(path, p.pathPrefixSlash)
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		// 通配部分只能是一级或多级子域名，不能包含端口或路径
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			sub := origin[len(w[0]) : len(origin)-len(w[1])]
+			if !strings.ContainsAny(sub, "/:@") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsHeaders 检查预检请求中声明的请求头是否都在允许范围内
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" && !p.headers[header] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *corsPolicy) handle(ctx *gin.Context) {
+	origin := ctx.GetHeader("Origin")
+	preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+
+	if origin == "" {
+		ctx.Next()
+		return
+	}
+
+	header := ctx.Writer.Header()
+	header.Add("Vary", "Origin")
+
+	// 不允许的源不返回任何跨域响应头，由浏览器拦截
+	if !p.allowsOrigin(origin) {
+		if preflight {
+			ctx.AbortWithStatus(http.StatusForbidden)
 			return
 		}
+		ctx.Next()
+		return
+	}
+
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		if !p.methods[strings.ToUpper(ctx.GetHeader("Access-Control-Request-Method"))] ||
+			!p.allowsHeaders(ctx.GetHeader("Access-Control-Request-Headers")) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+
+	if p.anyOrigin && !p.credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if preflight {
+		header.Set("Access-Control-Allow-Methods", p.allowMethods)
+		header.Set("Access-Control-Allow-Headers", p.allowHeaders)
+		header.Set("Access-Control-Max-Age", p.maxAge)
+		ctx.AbortWithStatus(http.StatusNoContent)
+		return
+	}
 
-		c.Next()
+	if p.exposeHeaders != "" {
+		header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
 	}
+	ctx.Next()
 }
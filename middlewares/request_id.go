@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"book-management-system/utils"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// 只接受网关或客户端传入的简单标识，避免把任意内容写入日志和审计记录
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// RequestID 为每个请求分配请求ID，沿用上游传入的合法 X-Request-ID，并在响应头中返回
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			// 随机数生成失败时不带请求ID继续处理，不因此拒绝请求
+			id, _ = utils.GenerateRandomToken(16)
+		}
+
+		ctx.Set("requestID", id)
+		if id != "" {
+			ctx.Header(RequestIDHeader, id)
+		}
+		ctx.Next()
+	}
+}
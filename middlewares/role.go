@@ -1,13 +1,18 @@
 package middlewares
 
 import (
+	"book-management-system/config"
 	"book-management-system/models"
+	"book-management-system/services"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AdminOnly() gin.HandlerFunc {
+// RequirePermission 要求当前用户的角色拥有全部指定权限
+func RequirePermission(roleService services.RoleService, permissions ...models.Permission) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		roleValue, exists := ctx.Get("userRole")
 		if !exists {
@@ -16,6 +21,12 @@ func AdminOnly() gin.HandlerFunc {
 			return
 		}
 
+		if ctx.GetBool("emailUnverified") && config.AppConfig.UnverifiedLoginPolicy == "restrict" {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "请先验证邮箱"})
+			ctx.Abort()
+			return
+		}
+
 		role, ok := roleValue.(models.UserRole)
 		if !ok {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "角色类型错误"})
@@ -23,9 +34,103 @@ func AdminOnly() gin.HandlerFunc {
 			return
 		}
 
-		if role != models.RoleAdmin {
-			//告知权限不足并告知当前身份
-			ctx.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+		if !ctx.GetBool("mfa") {
+			required, err := roleService.RequiresTwoFactor(role)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
+				ctx.Abort()
+				return
+			}
+			if required {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": "拥有管理权限的账户必须启用两步验证后才能执行该操作"})
+				ctx.Abort()
+				return
+			}
+		}
+
+		allowed, err := roleService.HasPermissions(role, permissions...)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限失败"})
+			ctx.Abort()
+			return
+		}
+
+		if !allowed {
+			//告知权限不足及缺少的权限
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "权限不足", "required": permissions})
+			ctx.Abort()
+			return
+		}
+
+		// 使用 API 密钥时，权限为角色权限与密钥作用范围的交集
+		if !scopeAllows(ctx, permissions) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "API密钥的权限范围不足", "required": permissions})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireScope 只检查 API 密钥的作用范围，用于任何登录用户都能访问的本人数据接口；访问令牌不受影响
+func RequireScope(permissions ...models.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !scopeAllows(ctx, permissions) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "API密钥的权限范围不足", "required": permissions})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// scopeAllows 未使用 API 密钥，或密钥的作用范围包含全部指定权限
+func scopeAllows(ctx *gin.Context, permissions []models.Permission) bool {
+	key, usingAPIKey := ctx.Get("apiKey")
+	if !usingAPIKey {
+		return true
+	}
+	for _, required := range permissions {
+		if !key.(*models.APIKey).HasScope(required) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireRoleAuthority 用于操作路径参数 id 所指用户的管理接口，要求当前用户能够授予该用户的角色，
+// 规则见 RoleService.AuthorizeRole；已软删除的用户按删除前的角色判断
+func RequireRoleAuthority(roleService services.RoleService, userService services.UserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			ctx.Abort()
+			return
+		}
+
+		role, err := userService.GetUserRole(uint(userID))
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+
+		actor := services.Actor{UserID: ctx.GetUint("userID")}
+		if value, ok := ctx.Get("userRole"); ok {
+			actor.Role, _ = value.(models.UserRole)
+		}
+		if key, ok := ctx.Get("apiKey"); ok {
+			actor.Scopes = key.(*models.APIKey).Scopes
+		}
+
+		if err := roleService.AuthorizeRole(actor, role); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrRoleAuthority) {
+				status = http.StatusForbidden
+			}
+			ctx.JSON(status, gin.H{"error": err.Error()})
 			ctx.Abort()
 			return
 		}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"book-management-system/services"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ThrottleByIP 限制单个 IP 对指定动作（如 login、register）的请求频率
+func ThrottleByIP(guard services.LoginGuard, action string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		allowed, retryAfter, err := guard.AllowIP(action, ctx.ClientIP())
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "请求频率检查失败"})
+			ctx.Abort()
+			return
+		}
+
+		if !allowed {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
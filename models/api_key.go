@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// APIKey 用户为脚本和集成创建的个人 API 密钥
+// 密钥明文只在创建时返回一次，数据库只保存其SHA-256摘要；Prefix 用于在列表中识别密钥
+// 密钥的权限为 Scopes 与所属用户当前角色权限的交集
+type APIKey struct {
+	ID         uint         `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uint         `gorm:"not null;index" json:"user_id"`
+	Name       string       `gorm:"size:100;not null" json:"name"`
+	Prefix     string       `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	KeyHash    string       `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     []Permission `gorm:"type:text;serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	RevokedAt  *time.Time   `gorm:"index" json:"revoked_at"`
+}
+
+// HasScope 检查密钥是否被授予指定权限
+func (k *APIKey) HasScope(p Permission) bool {
+	for _, scope := range k.Scopes {
+		if scope == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditAction 审计事件的操作类型，格式为 对象.动作
+type AuditAction string
+
+const (
+	AuditBookCreate = AuditAction("book.create")
+	AuditBookUpdate = AuditAction("book.update")
+	AuditBookDelete = AuditAction("book.delete")
+
+	AuditCopyCreate = AuditAction("copy.create")
+	AuditCopyUpdate = AuditAction("copy.update")
+	AuditCopyDelete = AuditAction("copy.delete")
+
+	AuditAuthorCreate = AuditAction("author.create")
+	AuditAuthorUpdate = AuditAction("author.update")
+	AuditAuthorDelete = AuditAction("author.delete")
+
+	AuditCategoryCreate = AuditAction("category.create")
+	AuditCategoryUpdate = AuditAction("category.update")
+	AuditCategoryMove   = AuditAction("category.move")
+	AuditCategoryMerge  = AuditAction("category.merge")
+	AuditCategoryDelete = AuditAction("category.delete")
+
+	AuditTagUpdate = AuditAction("tag.update")
+	AuditTagMerge  = AuditAction("tag.merge")
+	AuditTagDelete = AuditAction("tag.delete")
+
+	AuditCirculationBorrow = AuditAction("circulation.borrow")
+	AuditCirculationReturn = AuditAction("circulation.return")
+
+	AuditUserCreate           = AuditAction("user.create")
+	AuditUserSuspend          = AuditAction("user.suspend")
+	AuditUserActivate         = AuditAction("user.activate")
+	AuditUserDelete           = AuditAction("user.delete")
+	AuditUserRestore          = AuditAction("user.restore")
+	AuditUserUnlock           = AuditAction("user.unlock")
+	AuditUserRoleAssign       = AuditAction("user.role_assign")
+	AuditUserUsernameChange   = AuditAction("user.username_change")
+	AuditUserPasswordChange   = AuditAction("user.password_change")
+	AuditUserPasswordReset    = AuditAction("user.password_reset")
+	AuditUserTwoFactorReset   = AuditAction("user.2fa_reset")
+	AuditUserSessionsRevoke   = AuditAction("user.sessions_revoke")
+	AuditUserSessionRevoke    = AuditAction("user.session_revoke")
+	AuditUserTwoFactorEnable  = AuditAction("user.2fa_enable")
+	AuditUserTwoFactorDisable = AuditAction("user.2fa_disable")
+
+	AuditUserRecoveryCodesRegenerate = AuditAction("user.recovery_codes_regenerate")
+
+	AuditAPIKeyCreate = AuditAction("api_key.create")
+	AuditAPIKeyRevoke = AuditAction("api_key.revoke")
+
+	AuditRoleCreate = AuditAction("role.create")
+	AuditRoleUpdate = AuditAction("role.update")
+	AuditRoleDelete = AuditAction("role.delete")
+)
+
+// 审计事件的操作对象类型
+const (
+	AuditTargetBook         = "book"
+	AuditTargetBookCopy     = "book_copy"
+	AuditTargetAuthor       = "author"
+	AuditTargetCategory     = "category"
+	AuditTargetTag          = "tag"
+	AuditTargetBorrowRecord = "borrow_record"
+	AuditTargetUser         = "user"
+	AuditTargetRole         = "role"
+	AuditTargetAPIKey       = "api_key"
+)
+
+var ErrAuditEventImmutable = errors.New("审计记录只能追加，不能修改或删除")
+
+// AuditChange 单个字段修改前后的值，新建时 Before 为空，删除时 After 为空
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEvent 审计日志，只追加不修改
+type AuditEvent struct {
+	ID         uint                   `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time              `gorm:"index" json:"created_at"`
+	ActorID    *uint                  `gorm:"index" json:"actor_id"` // 未登录的操作（如通过邮件重置密码前）和命令行操作为空
+	ActorName  string                 `gorm:"size:50" json:"actor_name"`
+	APIKeyID   *uint                  `gorm:"column:api_key_id" json:"api_key_id,omitempty"`
+	Action     AuditAction            `gorm:"size:50;not null;index" json:"action"`
+	TargetType string                 `gorm:"size:30;not null;index:idx_audit_target" json:"target_type"`
+	TargetID   string                 `gorm:"size:64;index:idx_audit_target" json:"target_id"`
+	Changes    map[string]AuditChange `gorm:"type:text;serializer:json" json:"changes,omitempty"`
+	IP         string                 `gorm:"column:ip;size:45" json:"ip"`
+	RequestID  string                 `gorm:"size:64;index" json:"request_id"`
+}
+
+// BeforeUpdate 拒绝通过 ORM 修改审计记录
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// BeforeDelete 拒绝通过 ORM 删除审计记录
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ContributorRole 责任者在图书中的角色
+type ContributorRole string
+
+const (
+	ContributorAuthor      ContributorRole = "author"      // 著
+	ContributorEditor      ContributorRole = "editor"      // 编
+	ContributorTranslator  ContributorRole = "translator"  // 译
+	ContributorIllustrator ContributorRole = "illustrator" // 绘
+)
+
+// IsValid 检查角色是否已定义
+func (r ContributorRole) IsValid() bool {
+	switch r {
+	case ContributorAuthor, ContributorEditor, ContributorTranslator, ContributorIllustrator:
+		return true
+	}
+	return false
+}
+
+// Author 责任者规范记录，同一个人的不同写法记录在 NameVariants 中
+type Author struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Name         string    `gorm:"size:255;not null" json:"name"`
+	NameKey      string    `gorm:"size:255;not null;index" json:"-"` // 见 AuthorNameKey，同名的不同作者可以共存
+	NameVariants []string  `gorm:"type:text;serializer:json" json:"name_variants"`
+	VariantKeys  string    `gorm:"type:text" json:"-"` // 其他写法的 AuthorNameKey，形如 |key1|key2|，便于按写法查找
+	BirthYear    *int      `json:"birth_year"`
+	DeathYear    *int      `json:"death_year"`
+	Bio          string    `gorm:"type:text" json:"bio"`
+	NeedsReview  bool      `gorm:"not null;default:false;index" json:"needs_review"` // 升级时无法判断是几位作者而整体保留的作者字符串，待人工拆分或确认
+}
+
+// BookAuthor 图书与责任者的关联，同一人可以在一本书中承担多个角色
+type BookAuthor struct {
+	BookID   uint            `gorm:"primaryKey" json:"book_id"`
+	AuthorID uint            `gorm:"primaryKey;index" json:"author_id"`
+	Role     ContributorRole `gorm:"primaryKey;size:20" json:"role"`
+	Position int             `gorm:"not null;default:0" json:"position"` // 在书目中的排列顺序
+	Author   *Author         `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Book     *Book           `gorm:"foreignKey:BookID" json:"book,omitempty"`
+}
+
+// AuthorNameKey 忽略大小写、空白和标点后的姓名，用于识别 "J.K. Rowling" 和 "J. K. Rowling" 这样的写法差异
+func AuthorNameKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var (
+	// authorSeparator 只会用来分隔多位作者、不会出现在单个姓名中的符号
+	authorSeparator = regexp.MustCompile(`\s*[、;；/]\s*`)
+	// ambiguousAuthorSeparator 既可能分隔多位作者，也可能是单个姓名的一部分，
+	// 如 "Rowling, J.K." 中的逗号和 "Simon and Garfunkel" 中的 and
+	ambiguousAuthorSeparator = regexp.MustCompile(`[,，&]|\s(?i:and)\s`)
+)
+
+// SplitAuthorNames 按顿号、分号或斜杠把 "鲁迅、周作人" 这样的作者字符串拆成姓名列表
+// 含有逗号、& 或 and 的部分无法判断是几位作者，不做拆分并返回 ambiguous
+func SplitAuthorNames(author string) (names []string, ambiguous bool) {
+	for _, name := range authorSeparator.Split(strings.TrimSpace(author), -1) {
+		if name = strings.TrimSpace(name); AuthorNameKey(name) != "" {
+			names = append(names, name)
+			ambiguous = ambiguous || ambiguousAuthorSeparator.MatchString(name)
+		}
+	}
+	return names, ambiguous
+}
+
+// ContributorDisplay 由责任者生成图书的作者显示文本，没有著者时使用全部责任者
+func ContributorDisplay(contributors []BookAuthor) string {
+	var authors, all []string
+	for _, c := range contributors {
+		if c.Author == nil {
+			continue
+		}
+		all = append(all, c.Author.Name)
+		if c.Role == ContributorAuthor {
+			authors = append(authors, c.Author.Name)
+		}
+	}
+	if len(authors) == 0 {
+		authors = all
+	}
+	return strings.Join(authors, ", ")
+}
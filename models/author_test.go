@@ -0,0 +1,34 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitAuthorNames(t *testing.T) {
+	tests := []struct {
+		author        string
+		wantNames     []string
+		wantAmbiguous bool
+	}{
+		{author: "刘慈欣", wantNames: []string{"刘慈欣"}},
+		{author: "鲁迅、周作人", wantNames: []string{"鲁迅", "周作人"}},
+		{author: "Kernighan; Ritchie / Pike", wantNames: []string{"Kernighan", "Ritchie", "Pike"}},
+		{author: " 、 ; ", wantNames: nil},
+		{author: "Rowling, J.K.", wantNames: []string{"Rowling, J.K."}, wantAmbiguous: true},
+		{author: "Simon and Garfunkel", wantNames: []string{"Simon and Garfunkel"}, wantAmbiguous: true},
+		{author: "Simon & Garfunkel", wantNames: []string{"Simon & Garfunkel"}, wantAmbiguous: true},
+		{author: "鲁迅，周作人", wantNames: []string{"鲁迅，周作人"}, wantAmbiguous: true},
+		{author: "Tolkien; Rowling, J.K.", wantNames: []string{"Tolkien", "Rowling, J.K."}, wantAmbiguous: true},
+		{author: "Alexander Anderson", wantNames: []string{"Alexander Anderson"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.author, func(t *testing.T) {
+			names, ambiguous := SplitAuthorNames(tt.author)
+			if !reflect.DeepEqual(names, tt.wantNames) || ambiguous != tt.wantAmbiguous {
+				t.Errorf("SplitAuthorNames(%q) = %q, %v，期望 %q, %v", tt.author, names, ambiguous, tt.wantNames, tt.wantAmbiguous)
+			}
+		})
+	}
+}
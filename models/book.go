@@ -6,23 +6,37 @@ import (
 )
 
 type Book struct {
-	ID          uint      `gorm:"primarykey" json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Title       string    `gorm:"not null;index" json:"title"`
-	Author      string    `gorm:"not null" json:"author"`
-	TotalCopies int       `gorm:"not null;default:1" json:"total_copies"`
-	Available   int       `gorm:"not null" json:"available"`
-	BorrowedBy  []User    `gorm:"many2many:user_borrowed_books;" json:"-"`
+	ID              uint         `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	Title           string       `gorm:"not null;index" json:"title"`
+	Author          string       `gorm:"not null" json:"author"`                      // 作者显示文本，关联了责任者时由 ContributorDisplay 生成
+	ISBN            *string      `gorm:"column:isbn;size:13;uniqueIndex" json:"isbn"` // 统一保存为 ISBN-13，未登记时为空
+	Publisher       string       `gorm:"size:255" json:"publisher"`
+	PublicationYear int          `json:"publication_year,omitempty"`
+	Edition         string       `gorm:"size:50" json:"edition"`
+	Language        string       `gorm:"size:35" json:"language"` // BCP 47 语言标签，如 zh-CN、en
+	PageCount       int          `json:"page_count,omitempty"`
+	Description     string       `gorm:"type:text" json:"description"`
+	Subjects        []string     `gorm:"type:text;serializer:json" json:"subjects"`
+	TotalCopies     int          `gorm:"not null;default:1" json:"total_copies"` // 由副本状态计算，见 RefreshBookCopyCounts
+	Available       int          `gorm:"not null" json:"available"`
+	Copies          []BookCopy   `gorm:"foreignKey:BookID" json:"copies,omitempty"`
+	Contributors    []BookAuthor `gorm:"foreignKey:BookID" json:"contributors,omitempty"`
+	Categories      []Category   `gorm:"many2many:book_categories" json:"categories,omitempty"`
+	Tags            []Tag        `gorm:"many2many:book_tags" json:"tags,omitempty"`
+	BorrowedBy      []User       `gorm:"many2many:user_borrowed_books;" json:"-"`
 }
 
 type BorrowRecord struct {
 	ID         uint       `gorm:"primarykey" json:"id"`
 	UserID     uint       `gorm:"not null;index" json:"user_id"`
 	BookID     uint       `gorm:"not null;index" json:"book_id"`
+	CopyID     *uint      `gorm:"index" json:"copy_id"` // 升级前已归还的记录没有对应副本
 	BorrowedAt time.Time  `gorm:"not null" json:"borrowed_at"`
 	ReturnedAt *time.Time `json:"returned_at"`
 	DueDate    time.Time  `gorm:"not null" json:""`
 	Book       Book       `gorm:"foreignKey:BookID" json:"book"`
+	Copy       *BookCopy  `gorm:"foreignKey:CopyID" json:"copy,omitempty"`
 	User       User       `gorm:"foreignKey:UserID" json:"user"`
 }
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CopyStatus 馆藏副本的流通状态
+type CopyStatus string
+
+const (
+	CopyStatusAvailable CopyStatus = "available" // 在架可借
+	CopyStatusOnLoan    CopyStatus = "on_loan"   // 已借出，只能通过借还操作进出该状态
+	CopyStatusInRepair  CopyStatus = "in_repair" // 修补中
+	CopyStatusLost      CopyStatus = "lost"      // 丢失
+	CopyStatusWithdrawn CopyStatus = "withdrawn" // 已剔旧注销
+)
+
+// IsValid 检查状态是否已定义
+func (s CopyStatus) IsValid() bool {
+	switch s {
+	case CopyStatusAvailable, CopyStatusOnLoan, CopyStatusInRepair, CopyStatusLost, CopyStatusWithdrawn:
+		return true
+	}
+	return false
+}
+
+// CopyCondition 副本的品相
+type CopyCondition string
+
+const (
+	CopyConditionNew     CopyCondition = "new"
+	CopyConditionGood    CopyCondition = "good"
+	CopyConditionFair    CopyCondition = "fair"
+	CopyConditionPoor    CopyCondition = "poor"
+	CopyConditionDamaged CopyCondition = "damaged"
+)
+
+// IsValid 检查品相是否已定义
+func (c CopyCondition) IsValid() bool {
+	switch c {
+	case CopyConditionNew, CopyConditionGood, CopyConditionFair, CopyConditionPoor, CopyConditionDamaged:
+		return true
+	}
+	return false
+}
+
+// BookCopy 一册实体馆藏，流通台通过条码识别
+type BookCopy struct {
+	ID         uint          `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	BookID     uint          `gorm:"not null;index" json:"book_id"`
+	Barcode    string        `gorm:"size:64;not null;uniqueIndex" json:"barcode"`
+	Status     CopyStatus    `gorm:"size:20;not null;default:available;index" json:"status"`
+	Condition  CopyCondition `gorm:"size:20;not null;default:good" json:"condition"`
+	AcquiredOn *time.Time    `gorm:"type:date" json:"acquired_on"`
+	Price      *float64      `gorm:"type:decimal(10,2)" json:"price"` // 入藏价格，单位元
+	Note       string        `gorm:"size:255" json:"note"`
+}
+
+// GeneratedBarcode 未指定条码的副本按图书ID和序号生成条码
+func GeneratedBarcode(bookID uint, seq int) string {
+	return fmt.Sprintf("BK%06d-%03d", bookID, seq)
+}
+
+// RefreshBookCopyCounts 按副本状态重新计算图书的馆藏数和可借数
+// 丢失和注销的副本不计入馆藏数；调用方应与修改副本状态放在同一个事务中
+func RefreshBookCopyCounts(tx *gorm.DB, bookID uint) error {
+	var counts struct {
+		Total     int
+		Available int
+	}
+	if err := tx.Model(&BookCopy{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS available", CopyStatusAvailable).
+		Where("book_id = ? AND status NOT IN ?", bookID, []CopyStatus{CopyStatusLost, CopyStatusWithdrawn}).
+		Scan(&counts).Error; err != nil {
+		return fmt.Errorf("统计馆藏副本失败: %w", err)
+	}
+
+	if err := tx.Model(&Book{}).Where("id = ?", bookID).
+		UpdateColumns(map[string]any{"total_copies": counts.Total, "available": counts.Available}).Error; err != nil {
+		return fmt.Errorf("更新图书库存失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Category 图书分类，按物化路径组织成树
+type Category struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	ParentID    *uint     `gorm:"index" json:"parent_id"`              // 顶级分类为空
+	Path        string    `gorm:"size:255;not null;index" json:"path"` // 从根到本分类的ID，形如 /1/5/12/，见 CategoryPath
+	Depth       int       `gorm:"not null;default:0" json:"depth"`     // 顶级分类为 0
+	Position    int       `gorm:"not null;default:0" json:"position"`  // 同级分类的排列顺序
+	Description string    `gorm:"type:text" json:"description"`
+}
+
+// BookCategory 图书与分类的关联，一本书可以归入多个分类
+type BookCategory struct {
+	BookID     uint `gorm:"primaryKey"`
+	CategoryID uint `gorm:"primaryKey;index"`
+}
+
+// Tag 自由标签，按 TagKey 去重，不区分大小写
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `gorm:"size:50;not null" json:"name"`
+	NameKey   string    `gorm:"size:50;not null;uniqueIndex" json:"-"`
+}
+
+// TagCount 标签及其关联的图书数
+type TagCount struct {
+	Tag
+	BookCount int64 `json:"book_count"`
+}
+
+// BookTag 图书与标签的关联
+type BookTag struct {
+	BookID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey;index"`
+}
+
+// CategoryPath 分类的物化路径，顶级分类的 parentPath 为空
+func CategoryPath(parentPath string, id uint) string {
+	if parentPath == "" {
+		parentPath = "/"
+	}
+	return parentPath + strconv.FormatUint(uint64(id), 10) + "/"
+}
+
+// AncestorIDs 从根到父分类的ID，顶级分类返回空
+func (c *Category) AncestorIDs() []uint {
+	parts := strings.Split(strings.Trim(c.Path, "/"), "/")
+	ids := make([]uint, 0, len(parts))
+	for _, part := range parts[:len(parts)-1] {
+		if id, err := strconv.ParseUint(part, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// IsAncestorOf 检查 other 是否位于本分类之下（含本分类自身）
+func (c *Category) IsAncestorOf(other *Category) bool {
+	return strings.HasPrefix(other.Path, c.Path)
+}
+
+// TagKey 忽略大小写并合并连续空白后的标签名，用于识别重复标签
+func TagKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt 登录防护计数器
+// Key 为 "account:<用户名>" 时记录连续失败次数和锁定截止时间；
+// 为 "ip:<动作>:<IP>" 时记录当前时间窗口内的请求次数
+type LoginAttempt struct {
+	Key         string     `gorm:"column:attempt_key;primarykey;size:191" json:"key"`
+	Count       int        `gorm:"not null;default:0" json:"count"`
+	WindowStart time.Time  `json:"window_start"`
+	LockedUntil *time.Time `json:"locked_until"`
+	UpdatedAt   time.Time  `gorm:"index" json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PasswordResetToken 找回密码时发放的一次性重置令牌，数据库中只保存哈希值
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证恢复码，丢失验证器时可代替验证码使用一次
+// 数据库只保存恢复码的SHA-256摘要
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RefreshToken 服务端保存的刷新令牌
+// 同一次登录派生出的所有刷新令牌共享 FamilyID，每次刷新都会轮换出新令牌；
+// 已使用过的令牌再次出现时视为被盗用，整个令牌族会被撤销
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"size:64;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ParentID  *uint      `json:"parent_id"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `gorm:"index" json:"revoked_at"`
+}
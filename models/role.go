@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// Permission 权限标识，格式为 资源:操作
+type Permission string
+
+const (
+	PermissionBookCreate           Permission = "book:create"
+	PermissionBookUpdate           Permission = "book:update"
+	PermissionBookDelete           Permission = "book:delete"
+	PermissionCopyManage           Permission = "copy:manage"
+	PermissionBorrowSelf           Permission = "borrow:self"
+	PermissionBorrowCheckoutOthers Permission = "borrow:checkout_for_others"
+	PermissionBorrowReadAll        Permission = "borrow:read_all"
+	PermissionUserRead             Permission = "user:read"
+	PermissionUserManage           Permission = "user:manage"
+	PermissionRoleManage           Permission = "role:manage"
+	PermissionAuditRead            Permission = "audit:read"
+	PermissionProfileRead          Permission = "profile:read"
+)
+
+// AllPermissions 系统中定义的全部权限
+var AllPermissions = []Permission{
+	PermissionBookCreate,
+	PermissionBookUpdate,
+	PermissionBookDelete,
+	PermissionCopyManage,
+	PermissionBorrowSelf,
+	PermissionBorrowCheckoutOthers,
+	PermissionBorrowReadAll,
+	PermissionUserRead,
+	PermissionUserManage,
+	PermissionRoleManage,
+	PermissionAuditRead,
+	PermissionProfileRead,
+}
+
+// ImplicitPermissions 每个角色都隐含拥有的权限，只用于限定 API 密钥能否访问账户本人的数据
+var ImplicitPermissions = []Permission{
+	PermissionProfileRead,
+}
+
+// AdminPermissions 管理类权限，角色拥有其中任一权限即视为管理账户，
+// 开启 ADMIN_2FA_REQUIRED 时这类账户必须启用两步验证
+var AdminPermissions = []Permission{
+	PermissionUserManage,
+	PermissionRoleManage,
+	PermissionAuditRead,
+}
+
+// IsValidPermission 检查权限标识是否已定义
+func IsValidPermission(p Permission) bool {
+	for _, known := range AllPermissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Role 角色即一组命名的权限集合
+type Role struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Name        UserRole     `gorm:"size:50;uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	Permissions []Permission `gorm:"type:text;serializer:json" json:"permissions"`
+	BuiltIn     bool         `gorm:"not null;default:false" json:"built_in"`
+}
+
+// HasPermission 检查角色是否拥有指定权限
+func (r *Role) HasPermission(p Permission) bool {
+	for _, granted := range r.Permissions {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRoles 内置角色，启动时如不存在会自动创建
+func DefaultRoles() []Role {
+	return []Role{
+		{
+			Name:        RoleAdmin,
+			Description: "系统管理员，拥有全部权限",
+			Permissions: append([]Permission(nil), AllPermissions...),
+			BuiltIn:     true,
+		},
+		{
+			Name:        RoleLibrarian,
+			Description: "流通台馆员，可为读者办理借还、维护馆藏副本，不能删除图书或管理用户",
+			Permissions: []Permission{
+				PermissionCopyManage,
+				PermissionBorrowSelf,
+				PermissionBorrowCheckoutOthers,
+				PermissionBorrowReadAll,
+				PermissionUserRead,
+			},
+			BuiltIn: true,
+		},
+		{
+			Name:        RoleUser,
+			Description: "普通读者",
+			Permissions: []Permission{PermissionBorrowSelf},
+			BuiltIn:     true,
+		},
+	}
+}
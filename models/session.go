@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session 一次登录产生的会话，对应一个刷新令牌族
+// 访问令牌通过 sid 声明关联会话，会话被撤销后其访问令牌和刷新令牌立即失效
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"-"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID   string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`
+	Device     string     `gorm:"size:100" json:"device"` // 从 User-Agent 解析出的设备描述
+	IP         string     `gorm:"column:ip;size:64" json:"ip"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"` // 刷新令牌到期时间，每次刷新后顺延
+	RevokedAt  *time.Time `gorm:"index" json:"-"`
+	Current    bool       `gorm:"-" json:"current"` // 是否为发起请求的会话
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RevokedToken 被单独撤销的访问令牌，过期后即可清理
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey;size:64" json:"jti"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserTokenRevocation 用户级别的撤销时间点，在此之前签发的所有访问令牌均失效
+type UserTokenRevocation struct {
+	UserID        uint      `gorm:"primarykey;autoIncrement:false" json:"user_id"`
+	RevokedBefore time.Time `gorm:"not null;precision:6" json:"revoked_before"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -9,18 +9,41 @@ import (
 type UserRole string
 
 const (
-	RoleAdmin UserRole = "admin"
-	RoleUser  UserRole = "user"
+	RoleAdmin     UserRole = "admin"
+	RoleLibrarian UserRole = "librarian"
+	RoleUser      UserRole = "user"
+)
+
+type UserStatus string
+
+const (
+	UserStatusPending   UserStatus = "pending" // 等待邮箱验证
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+)
+
+// 账户来源
+const (
+	AuthSourceLocal = "local"
+	AuthSourceOIDC  = "oidc"
+	AuthSourceLDAP  = "ldap"
 )
 
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Username  string         `gorm:"uniqueIndex;not null" json:"username"`
-	Password  string         `gorm:"not null" json:"-"`
-	Email     string         `gorm:"uniqueIndex" json:"email"`
-	Role      UserRole       `gorm:"type:enum('admin','user');default:'user'" json:"role"`
-	Borrowed  []Book         `gorm:"many2many:user_borrowed_books;" json:"borrowed_books,omitempty"`
+	ID              uint           `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	Username        string         `gorm:"uniqueIndex;not null" json:"username"`
+	Password        string         `gorm:"not null" json:"-"`
+	Email           string         `gorm:"uniqueIndex" json:"email"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	Role            UserRole       `gorm:"size:50;index;default:'user'" json:"role"`
+	Status          UserStatus     `gorm:"size:20;not null;default:'active'" json:"status"`
+	TOTPSecret      string         `gorm:"column:totp_secret;size:64" json:"-"`
+	TOTPEnabled     bool           `gorm:"column:totp_enabled;not null;default:false" json:"totp_enabled"`
+	TOTPLastStep    int64          `gorm:"column:totp_last_step;not null;default:0" json:"-"`   // 最近一次使用的验证码时间步，用于防止重放
+	AuthSource      string         `gorm:"size:20;not null;default:'local'" json:"auth_source"` // 账户创建来源
+	OIDCSubject     *string        `gorm:"column:oidc_subject;size:255;uniqueIndex" json:"-"`   // 关联的身份提供方用户标识（sub）
+	Borrowed        []Book         `gorm:"many2many:user_borrowed_books;" json:"borrowed_books,omitempty"`
 }
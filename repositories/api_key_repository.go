@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	FindByPrefix(prefix string) (*models.APIKey, error)
+	FindByID(id uint) (*models.APIKey, error)
+	FindByUser(userID uint, spec QuerySpec) (*Page[models.APIKey], error)
+	Revoke(id, userID uint) error
+	TouchLastUsed(id uint, usedAt time.Time) error
+}
+
+// apiKeySorts API密钥列表允许的排序字段
+var apiKeySorts = sortOptions[models.APIKey]{
+	fields: map[string]sortField[models.APIKey]{
+		"created_at": {column: "api_keys.created_at", desc: true, value: func(k *models.APIKey) any { return k.CreatedAt }},
+		"name":       {column: "api_keys.name", value: func(k *models.APIKey) any { return k.Name }},
+		"id":         {column: "api_keys.id", value: func(k *models.APIKey) any { return k.ID }},
+	},
+	defaultSort: "created_at",
+	idColumn:    "api_keys.id",
+	id:          func(k *models.APIKey) uint { return k.ID },
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepository{db: config.DB}
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	if key.UserID == 0 || key.Prefix == "" || key.KeyHash == "" {
+		return fmt.Errorf("API密钥信息不完整")
+	}
+
+	if err := r.db.Create(key).Error; err != nil {
+		return fmt.Errorf("保存API密钥失败: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) FindByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("prefix = ?", prefix).First(&key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("API密钥不存在")
+		}
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("API密钥不存在")
+		}
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+	return &key, nil
+}
+
+// FindByUser 分页返回用户的密钥（包括已撤销的），默认按创建时间倒序
+func (r *apiKeyRepository) FindByUser(userID uint, spec QuerySpec) (*Page[models.APIKey], error) {
+	page, err := paginate(r.db.Model(&models.APIKey{}).Where("user_id = ?", userID), spec, apiKeySorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+	return page, nil
+}
+
+// Revoke 撤销属于该用户的密钥，只能撤销自己的密钥
+func (r *apiKeyRepository) Revoke(id, userID uint) error {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("撤销API密钥失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("API密钥不存在或已撤销")
+	}
+	return nil
+}
+
+// TouchLastUsed 只更新最近使用时间，不修改 updated_at
+func (r *apiKeyRepository) TouchLastUsed(id uint, usedAt time.Time) error {
+	if err := r.db.Model(&models.APIKey{}).Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error; err != nil {
+		return fmt.Errorf("更新API密钥使用时间失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditFilter 审计日志查询条件，零值字段表示不过滤
+type AuditFilter struct {
+	ActorID    *uint
+	Action     string // 支持以 .* 结尾的前缀匹配，如 book.*
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+}
+
+// auditSorts 审计日志允许的排序字段，默认最新的在前
+var auditSorts = sortOptions[models.AuditEvent]{
+	fields: map[string]sortField[models.AuditEvent]{
+		"created_at": {column: "audit_events.created_at", desc: true, value: func(e *models.AuditEvent) any { return e.CreatedAt }},
+		"id":         {column: "audit_events.id", desc: true, value: func(e *models.AuditEvent) any { return e.ID }},
+	},
+	defaultSort: "created_at",
+	idColumn:    "audit_events.id",
+	id:          func(e *models.AuditEvent) uint { return e.ID },
+}
+
+// AuditRepository 审计日志只提供写入和查询，不提供修改和删除
+type AuditRepository interface {
+	Create(event *models.AuditEvent) error
+	Find(filter AuditFilter, spec QuerySpec) (*Page[models.AuditEvent], error)
+	FindInBatches(filter AuditFilter, batchSize int, fn func(events []models.AuditEvent) error) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository() AuditRepository {
+	return &auditRepository{db: config.DB}
+}
+
+func (r *auditRepository) Create(event *models.AuditEvent) error {
+	if err := r.db.Create(event).Error; err != nil {
+		return fmt.Errorf("保存审计记录失败: %w", err)
+	}
+	return nil
+}
+
+// Find 按过滤条件分页查询，默认按时间倒序；审计表只增不减，翻到深处时应使用游标
+func (r *auditRepository) Find(filter AuditFilter, spec QuerySpec) (*Page[models.AuditEvent], error) {
+	page, err := paginate(r.filtered(filter), spec, auditSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计记录失败: %w", err)
+	}
+	return page, nil
+}
+
+// FindInBatches 按写入顺序分批读取全部符合条件的记录，用于导出
+func (r *auditRepository) FindInBatches(filter AuditFilter, batchSize int, fn func(events []models.AuditEvent) error) error {
+	var events []models.AuditEvent
+	result := r.filtered(filter).FindInBatches(&events, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(events)
+	})
+	if result.Error != nil {
+		return fmt.Errorf("导出审计记录失败: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *auditRepository) filtered(filter AuditFilter) *gorm.DB {
+	query := r.db.Model(&models.AuditEvent{})
+
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if prefix, ok := strings.CutSuffix(filter.Action, "*"); ok {
+		query = query.Where("action LIKE ?", prefix+"%")
+	} else if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type AuthorRepository interface {
+	Create(author *models.Author) error
+	FindByID(id uint) (*models.Author, error)
+	FindByNameKey(key string) (*models.Author, error)
+	Search(filter AuthorFilter, spec QuerySpec) (*Page[models.Author], error)
+	Update(author *models.Author) error
+	Delete(id uint) error
+	CountBooks(authorID uint) (int64, error)
+	FindBooks(authorID uint, spec QuerySpec) (*Page[models.Book], error)
+}
+
+// AuthorFilter 作者列表的过滤条件，零值字段表示不过滤
+type AuthorFilter struct {
+	Query       string // 姓名或其他写法包含关键词
+	NeedsReview *bool
+}
+
+// authorSorts 作者列表允许的排序字段
+var authorSorts = sortOptions[models.Author]{
+	fields: map[string]sortField[models.Author]{
+		"name":       {column: "authors.name", value: func(a *models.Author) any { return a.Name }},
+		"created_at": {column: "authors.created_at", desc: true, value: func(a *models.Author) any { return a.CreatedAt }},
+		"id":         {column: "authors.id", value: func(a *models.Author) any { return a.ID }},
+	},
+	defaultSort: "name",
+	idColumn:    "authors.id",
+	id:          func(a *models.Author) uint { return a.ID },
+}
+
+type authorRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthorRepository() AuthorRepository {
+	return &authorRepository{db: config.DB}
+}
+
+func (r *authorRepository) Create(author *models.Author) error {
+	return r.db.Create(author).Error
+}
+
+func (r *authorRepository) FindByID(id uint) (*models.Author, error) {
+	var author models.Author
+	if err := r.db.First(&author, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("作者不存在")
+		}
+		return nil, fmt.Errorf("查询作者失败: %w", err)
+	}
+	return &author, nil
+}
+
+// FindByNameKey 按规范化姓名查找作者，正式姓名优先于其他写法，同名时取最早登记的一位
+func (r *authorRepository) FindByNameKey(key string) (*models.Author, error) {
+	var author models.Author
+	err := r.db.Where("name_key = ?", key).Order("id").First(&author).Error
+	if err == gorm.ErrRecordNotFound {
+		err = r.db.Where("variant_keys LIKE ?", "%|"+key+"|%").Order("id").First(&author).Error
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("作者不存在")
+		}
+		return nil, fmt.Errorf("查询作者失败: %w", err)
+	}
+	return &author, nil
+}
+
+// Search 按姓名或其他写法模糊查找作者，关键词为空时返回全部作者，默认按姓名排列
+func (r *authorRepository) Search(filter AuthorFilter, spec QuerySpec) (*Page[models.Author], error) {
+	db := r.db.Model(&models.Author{})
+	if query := filter.Query; query != "" {
+		pattern := "%" + query + "%"
+		match := r.db.Where("name LIKE ? OR name_variants LIKE ?", pattern, pattern)
+		if key := models.AuthorNameKey(query); key != "" {
+			match = match.Or("name_key LIKE ? OR variant_keys LIKE ?", "%"+key+"%", "%"+key+"%")
+		}
+		db = db.Where(match)
+	}
+	if filter.NeedsReview != nil {
+		db = db.Where("needs_review = ?", *filter.NeedsReview)
+	}
+	page, err := paginate(db, spec, authorSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询作者失败: %w", err)
+	}
+	return page, nil
+}
+
+// Update 保存作者信息，改名后同步关联图书的作者显示文本
+func (r *authorRepository) Update(author *models.Author) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Author
+		if err := tx.First(&existing, author.ID).Error; err != nil {
+			return fmt.Errorf("作者不存在")
+		}
+
+		if err := tx.Select("*").Omit("id", "created_at").Updates(author).Error; err != nil {
+			return fmt.Errorf("更新作者失败: %w", err)
+		}
+		if existing.Name == author.Name {
+			return nil
+		}
+
+		var bookIDs []uint
+		if err := tx.Model(&models.BookAuthor{}).Where("author_id = ?", author.ID).
+			Distinct().Pluck("book_id", &bookIDs).Error; err != nil {
+			return fmt.Errorf("查询关联图书失败: %w", err)
+		}
+		for _, bookID := range bookIDs {
+			if err := refreshBookAuthorDisplay(tx, bookID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *authorRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Author{}, id).Error
+}
+
+func (r *authorRepository) CountBooks(authorID uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.BookAuthor{}).Where("author_id = ?", authorID).
+		Distinct("book_id").Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("查询关联图书失败: %w", err)
+	}
+	return count, nil
+}
+
+// FindBooks 作者参与的图书，每本书的 Contributors 只含该作者在书中的角色，排序方式与图书列表相同
+func (r *authorRepository) FindBooks(authorID uint, spec QuerySpec) (*Page[models.Book], error) {
+	query := r.db.Model(&models.Book{}).
+		Where("books.id IN (?)", r.db.Model(&models.BookAuthor{}).Select("book_id").Where("author_id = ?", authorID)).
+		Preload("Contributors", func(db *gorm.DB) *gorm.DB {
+			return db.Where("author_id = ?", authorID).Order("position")
+		})
+	page, err := paginate(query, spec, bookSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询作者的图书失败: %w", err)
+	}
+	return page, nil
+}
+
+// refreshBookAuthorDisplay 按当前关联的责任者重新生成图书的作者显示文本
+func refreshBookAuthorDisplay(tx *gorm.DB, bookID uint) error {
+	var contributors []models.BookAuthor
+	if err := tx.Preload("Author").Where("book_id = ?", bookID).
+		Order("position").Find(&contributors).Error; err != nil {
+		return fmt.Errorf("查询图书责任者失败: %w", err)
+	}
+	display := models.ContributorDisplay(contributors)
+	if display == "" {
+		return nil
+	}
+	if err := tx.Model(&models.Book{}).Where("id = ?", bookID).UpdateColumn("author", display).Error; err != nil {
+		return fmt.Errorf("更新图书作者失败: %w", err)
+	}
+	return nil
+}
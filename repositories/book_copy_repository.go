@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type BookCopyRepository interface {
+	Create(copies []models.BookCopy) error
+	FindByBarcode(barcode string) (*models.BookCopy, error)
+	FindByBook(bookID uint, spec QuerySpec) (*Page[models.BookCopy], error)
+	Update(bookCopy *models.BookCopy) error
+	Delete(bookCopy *models.BookCopy) error
+	HasBorrowRecords(copyID uint) (bool, error)
+}
+
+// bookCopySorts 副本列表允许的排序字段，默认按入藏登记的顺序
+var bookCopySorts = sortOptions[models.BookCopy]{
+	fields: map[string]sortField[models.BookCopy]{
+		"id":      {column: "book_copies.id", value: func(c *models.BookCopy) any { return c.ID }},
+		"barcode": {column: "book_copies.barcode", value: func(c *models.BookCopy) any { return c.Barcode }},
+		"status":  {column: "book_copies.status", value: func(c *models.BookCopy) any { return c.Status }},
+	},
+	defaultSort: "id",
+	idColumn:    "book_copies.id",
+	id:          func(c *models.BookCopy) uint { return c.ID },
+}
+
+type bookCopyRepository struct {
+	db *gorm.DB
+}
+
+func NewBookCopyRepository() BookCopyRepository {
+	return &bookCopyRepository{db: config.DB}
+}
+
+// Create 为同一种图书添加副本，未指定条码的副本自动生成条码
+func (r *bookCopyRepository) Create(copies []models.BookCopy) error {
+	if len(copies) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return createCopies(tx, copies[0].BookID, copies)
+	})
+}
+
+func (r *bookCopyRepository) FindByBarcode(barcode string) (*models.BookCopy, error) {
+	var bookCopy models.BookCopy
+	if err := r.db.Where("barcode = ?", barcode).First(&bookCopy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("副本不存在")
+		}
+		return nil, fmt.Errorf("查询副本失败: %w", err)
+	}
+	return &bookCopy, nil
+}
+
+func (r *bookCopyRepository) FindByBook(bookID uint, spec QuerySpec) (*Page[models.BookCopy], error) {
+	page, err := paginate(r.db.Model(&models.BookCopy{}).Where("book_id = ?", bookID), spec, bookCopySorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询副本失败: %w", err)
+	}
+	return page, nil
+}
+
+// Update 保存副本信息并重新计算图书库存，借出状态只能由借还操作修改
+func (r *bookCopyRepository) Update(bookCopy *models.BookCopy) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// 以当前状态不是借出为条件更新，避免覆盖同时办理的借书
+		result := tx.Model(bookCopy).
+			Where("status <> ?", models.CopyStatusOnLoan).
+			Select("status", "condition", "acquired_on", "price", "note").
+			Updates(bookCopy)
+		if result.Error != nil {
+			return fmt.Errorf("更新副本失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("副本已借出，请先办理归还")
+		}
+		return models.RefreshBookCopyCounts(tx, bookCopy.BookID)
+	})
+}
+
+func (r *bookCopyRepository) Delete(bookCopy *models.BookCopy) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.BookCopy{}, bookCopy.ID).Error; err != nil {
+			return fmt.Errorf("删除副本失败: %w", err)
+		}
+		return models.RefreshBookCopyCounts(tx, bookCopy.BookID)
+	})
+}
+
+// HasBorrowRecords 副本是否有过借阅记录，有记录的副本只能注销不能删除
+func (r *bookCopyRepository) HasBorrowRecords(copyID uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.BorrowRecord{}).Where("copy_id = ?", copyID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询借阅记录失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// createCopies 在事务中写入副本、补齐自动生成的条码并重新计算图书库存
+func createCopies(tx *gorm.DB, bookID uint, copies []models.BookCopy) error {
+	// 同一批中手工指定的条码尚未写入数据库，生成条码时要一并避开
+	reserved := make(map[string]bool)
+	for i := range copies {
+		if copies[i].Barcode != "" {
+			reserved[copies[i].Barcode] = true
+		}
+	}
+
+	seq := 0
+	for i := range copies {
+		copies[i].BookID = bookID
+		if copies[i].Barcode != "" {
+			continue
+		}
+		barcode, next, err := nextGeneratedBarcode(tx, bookID, seq, reserved)
+		if err != nil {
+			return err
+		}
+		copies[i].Barcode, seq = barcode, next
+	}
+
+	if err := tx.Create(&copies).Error; err != nil {
+		return fmt.Errorf("创建副本失败: %w", err)
+	}
+	return models.RefreshBookCopyCounts(tx, bookID)
+}
+
+// nextGeneratedBarcode 从 after 之后找第一个未被占用、也不在 reserved 中的自动条码序号
+func nextGeneratedBarcode(tx *gorm.DB, bookID uint, after int, reserved map[string]bool) (string, int, error) {
+	if after == 0 {
+		var count int64
+		if err := tx.Model(&models.BookCopy{}).Where("book_id = ?", bookID).Count(&count).Error; err != nil {
+			return "", 0, fmt.Errorf("统计副本失败: %w", err)
+		}
+		after = int(count)
+	}
+
+	for seq := after + 1; ; seq++ {
+		barcode := models.GeneratedBarcode(bookID, seq)
+		if reserved[barcode] {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.BookCopy{}).Where("barcode = ?", barcode).Count(&count).Error; err != nil {
+			return "", 0, fmt.Errorf("检查条码失败: %w", err)
+		}
+		if count == 0 {
+			return barcode, seq, nil
+		}
+	}
+}
@@ -3,9 +3,11 @@ package repositories
 import (
 	"book-management-system/config"
 	"book-management-system/models"
+	"book-management-system/utils"
 	"fmt"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BookRepository interface {
@@ -13,11 +15,37 @@ type BookRepository interface {
 	FindByID(id uint) (*models.Book, error)
 	Update(book *models.Book) error
 	Delete(id uint) error
-	FindAll() ([]models.Book, error)
-	FindAvailable() ([]models.Book, error)
-	Search(query string) ([]models.Book, error)
+	Find(filter BookFilter, spec QuerySpec) (*Page[models.Book], error)
 	CheckAvailability(bookID uint) (bool, error)
-	ExistsByTitleAndAuthor(title, author string) (bool, error)
+	FindByISBN(isbn string) (*models.Book, error)
+}
+
+// BookFilter 图书列表的过滤条件，零值字段表示不过滤
+type BookFilter struct {
+	Query        string // 书名、作者或出版社包含关键词，关键词是合法的 ISBN 时同时按 ISBN 精确匹配
+	Author       string // 作者包含
+	Publisher    string // 出版社包含
+	Language     string
+	Available    *bool  // true 只含可借的图书，false 只含全部借出的图书
+	AuthorID     uint   // 以任意角色关联了该作者
+	CategoryID   uint   // 直接归入该分类
+	CategoryPath string // 归入该路径下的任一分类，即该分类及其全部下级分类
+	TagID        uint
+}
+
+// bookSorts 图书列表允许的排序字段
+var bookSorts = sortOptions[models.Book]{
+	fields: map[string]sortField[models.Book]{
+		"created_at":       {column: "books.created_at", desc: true, value: func(b *models.Book) any { return b.CreatedAt }},
+		"title":            {column: "books.title", value: func(b *models.Book) any { return b.Title }},
+		"author":           {column: "books.author", value: func(b *models.Book) any { return b.Author }},
+		"publication_year": {column: "books.publication_year", desc: true, value: func(b *models.Book) any { return b.PublicationYear }},
+		"available":        {column: "books.available", desc: true, value: func(b *models.Book) any { return b.Available }},
+		"id":               {column: "books.id", value: func(b *models.Book) any { return b.ID }},
+	},
+	defaultSort: "created_at",
+	idColumn:    "books.id",
+	id:          func(b *models.Book) uint { return b.ID },
 }
 
 type bookRepository struct {
@@ -35,15 +63,28 @@ func (r *bookRepository) Create(book *models.Book) error {
 	if book.Author == "" {
 		return fmt.Errorf("作者不能为空")
 	}
-	if book.TotalCopies <= 0 {
-		return fmt.Errorf("总库存必须大于0")
+	if len(book.Copies) == 0 {
+		return fmt.Errorf("至少需要一册副本")
 	}
 
-	if book.Available == 0 {
-		book.Available = book.TotalCopies
-	}
-
-	return r.db.Create(book).Error
+	// 图书、副本、责任者、分类和标签一起写入，库存数由副本状态计算
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		copies := book.Copies
+		book.TotalCopies, book.Available = 0, 0
+		if err := tx.Omit("Copies", "Contributors", "Categories", "Tags").Create(book).Error; err != nil {
+			return err
+		}
+		if err := createCopies(tx, book.ID, copies); err != nil {
+			return err
+		}
+		if err := replaceContributors(tx, book.ID, book.Contributors); err != nil {
+			return err
+		}
+		if err := replaceBookLabels(tx, book); err != nil {
+			return err
+		}
+		return tx.Select("total_copies", "available").First(book, book.ID).Error
+	})
 }
 
 func (r *bookRepository) FindByID(id uint) (*models.Book, error) {
@@ -52,7 +93,16 @@ func (r *bookRepository) FindByID(id uint) (*models.Book, error) {
 	}
 
 	var book models.Book
-	err := r.db.First(&book, id).Error
+	err := r.db.Preload("Contributors", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Contributors.Author").
+		Preload("Categories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("path")
+		}).
+		Preload("Tags", func(db *gorm.DB) *gorm.DB {
+			return db.Order("name")
+		}).
+		First(&book, id).Error
 
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -70,17 +120,18 @@ func (r *bookRepository) Update(book *models.Book) error {
 		return fmt.Errorf("图书不存在")
 	}
 
-	if book.TotalCopies != existing.TotalCopies {
-		diff := book.TotalCopies - existing.TotalCopies
-		book.Available = existing.Available + diff
-		if book.Available < 0 {
-			book.Available = 0
+	// 库存数只随副本变化，这里不覆盖；责任者、分类和标签整体替换为 book 中的值
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(book).Select("*").
+			Omit("id", "created_at", "total_copies", "available", "Copies", "Contributors", "Categories", "Tags", "BorrowedBy").
+			Updates(book).Error; err != nil {
+			return err
 		}
-	} else {
-		book.Available = existing.Available
-	}
-
-	return r.db.Save(book).Error
+		if err := replaceContributors(tx, book.ID, book.Contributors); err != nil {
+			return err
+		}
+		return replaceBookLabels(tx, book)
+	})
 }
 
 func (r *bookRepository) Delete(id uint) error {
@@ -89,60 +140,83 @@ func (r *bookRepository) Delete(id uint) error {
 		return fmt.Errorf("图书不存在")
 	}
 
-	if book.Available != book.TotalCopies {
-		return fmt.Errorf("图书有未归还记录，无法删除")
-	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var onLoan int64
+		if err := tx.Model(&models.BookCopy{}).
+			Where("book_id = ? AND status = ?", id, models.CopyStatusOnLoan).
+			Count(&onLoan).Error; err != nil {
+			return fmt.Errorf("查询副本失败: %w", err)
+		}
+		if onLoan > 0 {
+			return fmt.Errorf("图书有未归还记录，无法删除")
+		}
 
-	return r.db.Delete(&models.Book{}, id).Error
+		if err := tx.Where("book_id = ?", id).Delete(&models.BookCopy{}).Error; err != nil {
+			return fmt.Errorf("删除副本失败: %w", err)
+		}
+		if err := tx.Where("book_id = ?", id).Delete(&models.BookAuthor{}).Error; err != nil {
+			return fmt.Errorf("删除图书责任者失败: %w", err)
+		}
+		if err := replaceBookLabels(tx, &models.Book{ID: id}); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Book{}, id).Error
+	})
 }
 
-func (r *bookRepository) FindAll() ([]models.Book, error) {
-	var books []models.Book
+// Find 按过滤条件分页查询图书，默认按入库时间倒序
+func (r *bookRepository) Find(filter BookFilter, spec QuerySpec) (*Page[models.Book], error) {
+	query := r.db.Model(&models.Book{})
 
-	if err := r.db.Model(&models.Book{}).
-		Select("id, title, author, total_copies, available, created_at, updated_at").
-		Order("created_at DESC").
-		Find(&books).Error; err != nil {
-		return nil, fmt.Errorf("查询图书列表失败: %w", err)
+	if filter.Query != "" {
+		pattern := "%" + filter.Query + "%"
+		match := r.db.Where("books.title LIKE ? OR books.author LIKE ? OR books.publisher LIKE ?", pattern, pattern, pattern)
+		// 关键词是合法的 ISBN 时同时按 ISBN 精确匹配，ISBN-10 和 ISBN-13 均可
+		if isbn, err := utils.NormalizeISBN(filter.Query); err == nil {
+			match = match.Or("books.isbn = ?", isbn)
+		}
+		query = query.Where(match)
 	}
-
-	return books, nil
-}
-
-func (r *bookRepository) FindAvailable() ([]models.Book, error) {
-	var books []models.Book
-
-	if err := r.db.Model(&models.Book{}).
-		Select("id, title, author, total_copies, available, created_at").
-		Where("available > 0").
-		Order("created_at DESC").
-		Find(&books).Error; err != nil {
-		return nil, fmt.Errorf("查询可借图书失败: %w", err)
+	if filter.Author != "" {
+		query = query.Where("books.author LIKE ?", "%"+filter.Author+"%")
 	}
-
-	return books, nil
-}
-
-func (r *bookRepository) Search(query string) ([]models.Book, error) {
-	var books []models.Book
-
-	if query == "" {
-		return r.FindAll()
+	if filter.Publisher != "" {
+		query = query.Where("books.publisher LIKE ?", "%"+filter.Publisher+"%")
 	}
-	searchPattern := "%" + query + "%"
-
-	searchQuery := r.db.Model(&models.Book{}).
-		Where("title LIKE ? OR author LIKE ?",
-			searchPattern, searchPattern) // 传入3个参数
-
-	if err := searchQuery.
-		Select("id, title, author, total_copies, available, created_at").
-		Order("created_at DESC").
-		Find(&books).Error; err != nil {
-		return nil, fmt.Errorf("搜索图书失败: %w", err)
+	if filter.Language != "" {
+		query = query.Where("books.language = ?", filter.Language)
+	}
+	if filter.Available != nil {
+		if *filter.Available {
+			query = query.Where("books.available > 0")
+		} else {
+			query = query.Where("books.available = 0")
+		}
+	}
+	if filter.AuthorID != 0 {
+		query = query.Where("books.id IN (?)",
+			r.db.Model(&models.BookAuthor{}).Select("book_id").Where("author_id = ?", filter.AuthorID))
+	}
+	if filter.CategoryID != 0 {
+		query = query.Where("books.id IN (?)",
+			r.db.Model(&models.BookCategory{}).Select("book_id").Where("category_id = ?", filter.CategoryID))
+	}
+	if filter.CategoryPath != "" {
+		query = query.Where("books.id IN (?)",
+			r.db.Model(&models.BookCategory{}).Select("book_categories.book_id").
+				Joins("JOIN categories ON categories.id = book_categories.category_id").
+				Where("categories.path LIKE ?", filter.CategoryPath+"%"))
+	}
+	if filter.TagID != 0 {
+		query = query.Where("books.id IN (?)",
+			r.db.Model(&models.BookTag{}).Select("book_id").Where("tag_id = ?", filter.TagID))
 	}
 
-	return books, nil
+	page, err := paginate(query, spec, bookSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询图书列表失败: %w", err)
+	}
+	return page, nil
 }
 
 func (r *bookRepository) CheckAvailability(bookID uint) (bool, error) {
@@ -154,19 +228,103 @@ func (r *bookRepository) CheckAvailability(bookID uint) (bool, error) {
 	return book.Available > 0, nil
 }
 
-func (r *bookRepository) ExistsByTitleAndAuthor(title, author string) (bool, error) {
-	if title == "" || author == "" {
-		return false, fmt.Errorf("书名和作者不能为空")
+// FindByISBN 按规范化后的 ISBN-13 查找图书
+func (r *bookRepository) FindByISBN(isbn string) (*models.Book, error) {
+	var book models.Book
+	if err := r.db.Where("isbn = ?", isbn).First(&book).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("图书不存在")
+		}
+		return nil, fmt.Errorf("查询图书失败: %w", err)
 	}
+	return &book, nil
+}
 
-	var count int64
-	err := r.db.Model(&models.Book{}).
-		Where("title = ? AND author = ?", title, author).
-		Count(&count).Error
+// replaceContributors 用 contributors 替换图书原有的责任者关联，位置按切片顺序重新编号
+// AuthorID 为 0 的责任者先在同一事务中创建其 Author，多个关联共用的 Author 只创建一次
+func replaceContributors(tx *gorm.DB, bookID uint, contributors []models.BookAuthor) error {
+	if err := tx.Where("book_id = ?", bookID).Delete(&models.BookAuthor{}).Error; err != nil {
+		return fmt.Errorf("删除图书责任者失败: %w", err)
+	}
+	if len(contributors) == 0 {
+		return nil
+	}
 
-	if err != nil {
-		return false, fmt.Errorf("查询图书存在性失败: %w", err)
+	for i := range contributors {
+		c := &contributors[i]
+		if c.AuthorID != 0 || c.Author == nil {
+			continue
+		}
+		if c.Author.ID == 0 {
+			if err := tx.Create(c.Author).Error; err != nil {
+				return fmt.Errorf("创建作者失败: %w", err)
+			}
+		}
+		c.AuthorID = c.Author.ID
 	}
 
-	return count > 0, nil
+	links := make([]models.BookAuthor, len(contributors))
+	for i, c := range contributors {
+		links[i] = models.BookAuthor{BookID: bookID, AuthorID: c.AuthorID, Role: c.Role, Position: i}
+	}
+	if err := tx.Create(&links).Error; err != nil {
+		return fmt.Errorf("保存图书责任者失败: %w", err)
+	}
+	for i := range contributors {
+		contributors[i].BookID, contributors[i].Position = bookID, i
+	}
+	return nil
+}
+
+// replaceBookLabels 用 book.Categories 和 book.Tags 替换图书原有的分类和标签，两者均为空时即删除全部关联
+// ID 为 0 的标签先在同一事务中创建
+func replaceBookLabels(tx *gorm.DB, book *models.Book) error {
+	if err := tx.Where("book_id = ?", book.ID).Delete(&models.BookCategory{}).Error; err != nil {
+		return fmt.Errorf("删除图书分类失败: %w", err)
+	}
+	if len(book.Categories) > 0 {
+		links := make([]models.BookCategory, len(book.Categories))
+		for i, category := range book.Categories {
+			links[i] = models.BookCategory{BookID: book.ID, CategoryID: category.ID}
+		}
+		if err := tx.Create(&links).Error; err != nil {
+			return fmt.Errorf("保存图书分类失败: %w", err)
+		}
+	}
+
+	if err := tx.Where("book_id = ?", book.ID).Delete(&models.BookTag{}).Error; err != nil {
+		return fmt.Errorf("删除图书标签失败: %w", err)
+	}
+	if len(book.Tags) > 0 {
+		for i := range book.Tags {
+			if book.Tags[i].ID == 0 {
+				if err := createTag(tx, &book.Tags[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		links := make([]models.BookTag, len(book.Tags))
+		for i, tag := range book.Tags {
+			links[i] = models.BookTag{BookID: book.ID, TagID: tag.ID}
+		}
+		if err := tx.Create(&links).Error; err != nil {
+			return fmt.Errorf("保存图书标签失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// createTag 创建标签，并发创建同名标签时改用对方创建的标签
+func createTag(tx *gorm.DB, tag *models.Tag) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tag).Error; err != nil {
+		return fmt.Errorf("创建标签失败: %w", err)
+	}
+	// 冲突时插入被忽略，按名称重新读取以拿到实际的标签ID
+	var saved models.Tag
+	if err := tx.Where("name_key = ?", tag.NameKey).First(&saved).Error; err != nil {
+		return fmt.Errorf("创建标签失败: %w", err)
+	}
+	*tag = saved
+	return nil
 }
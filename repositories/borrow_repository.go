@@ -13,11 +13,36 @@ type BorrowRepository interface {
 	Borrow(record *models.BorrowRecord) error
 	Return(recordID uint) error
 	FindActiveByUserAndBook(userID, bookID uint) (*models.BorrowRecord, error)
+	FindActiveByCopy(copyID uint) (*models.BorrowRecord, error)
 	FindActiveByUser(userID uint) ([]models.BorrowRecord, error)
-	FindAll() ([]models.BorrowRecord, error)
-	FindByUser(userID uint) ([]models.BorrowRecord, error)
+	Find(filter BorrowRecordFilter, spec QuerySpec) (*Page[models.BorrowRecord], error)
 }
 
+// BorrowRecordFilter 借阅记录列表的过滤条件，零值字段表示不过滤
+type BorrowRecordFilter struct {
+	UserID         uint
+	BookID         uint
+	Active         *bool // true 只含未归还的记录，false 只含已归还的记录
+	Overdue        bool  // 只含已过应还日期仍未归还的记录
+	BorrowedAfter  *time.Time
+	BorrowedBefore *time.Time
+}
+
+// borrowRecordSorts 借阅记录列表允许的排序字段
+var borrowRecordSorts = sortOptions[models.BorrowRecord]{
+	fields: map[string]sortField[models.BorrowRecord]{
+		"borrowed_at": {column: "borrow_records.borrowed_at", desc: true, value: func(r *models.BorrowRecord) any { return r.BorrowedAt }},
+		"due_date":    {column: "borrow_records.due_date", value: func(r *models.BorrowRecord) any { return r.DueDate }},
+		"id":          {column: "borrow_records.id", value: func(r *models.BorrowRecord) any { return r.ID }},
+	},
+	defaultSort: "borrowed_at",
+	idColumn:    "borrow_records.id",
+	id:          func(r *models.BorrowRecord) uint { return r.ID },
+}
+
+// borrowCopyCandidates 自动选择副本时一次取出的在架副本数，前面的副本被并发借走时依次尝试后面的
+const borrowCopyCandidates = 5
+
 type borrowRepository struct {
 	db *gorm.DB
 }
@@ -26,102 +51,137 @@ func NewBorrowRepository() BorrowRepository {
 	return &borrowRepository{db: config.DB}
 }
 
+// Borrow 创建借阅记录并把副本标记为借出
+// record.CopyID 为空时自动选择该图书的一册在架副本
 func (r *borrowRepository) Borrow(record *models.BorrowRecord) error {
 	var user models.User
 	if err := r.db.First(&user, record.UserID).Error; err != nil {
 		return fmt.Errorf("用户不存在")
 	}
 
-	var book models.Book
-	if err := r.db.First(&book, record.BookID).Error; err != nil {
-		return fmt.Errorf("图书不存在")
-	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var candidates []models.BookCopy
+		if record.CopyID != nil {
+			var bookCopy models.BookCopy
+			if err := tx.First(&bookCopy, *record.CopyID).Error; err != nil {
+				return fmt.Errorf("副本不存在")
+			}
+			if bookCopy.Status != models.CopyStatusAvailable {
+				return fmt.Errorf("副本 %s 当前不可借（%s）", bookCopy.Barcode, bookCopy.Status)
+			}
+			record.BookID = bookCopy.BookID
+			candidates = append(candidates, bookCopy)
+		} else {
+			var book models.Book
+			if err := tx.First(&book, record.BookID).Error; err != nil {
+				return fmt.Errorf("图书不存在")
+			}
+			if err := tx.Where("book_id = ? AND status = ?", record.BookID, models.CopyStatusAvailable).
+				Order("id").Limit(borrowCopyCandidates).
+				Find(&candidates).Error; err != nil {
+				return fmt.Errorf("查询副本失败: %w", err)
+			}
+		}
 
-	if book.Available <= 0 {
-		return fmt.Errorf("图书已全部借出")
-	}
+		var existingBorrow int64
+		if err := tx.Model(&models.BorrowRecord{}).
+			Where("user_id = ? AND book_id = ? AND returned_at IS NULL",
+				record.UserID, record.BookID).
+			Count(&existingBorrow).Error; err != nil {
+			return fmt.Errorf("检查借阅记录失败: %w", err)
+		}
 
-	var existingBorrow int64
-	if err := r.db.Model(&models.BorrowRecord{}).
-		Where("user_id = ? AND book_id = ? AND returned_at IS NULL",
-			record.UserID, record.BookID).
-		Count(&existingBorrow).Error; err != nil {
-		return fmt.Errorf("检查借阅记录失败: %w", err)
-	}
+		if existingBorrow > 0 {
+			return fmt.Errorf("您已借阅此书且尚未归还")
+		}
 
-	if existingBorrow > 0 {
-		return fmt.Errorf("您已借阅此书且尚未归还")
-	}
+		// 以副本仍在架为条件标记借出，并发借同一册时只有一个请求成功
+		var claimed *models.BookCopy
+		for i := range candidates {
+			result := tx.Model(&models.BookCopy{}).
+				Where("id = ? AND status = ?", candidates[i].ID, models.CopyStatusAvailable).
+				Update("status", models.CopyStatusOnLoan)
+			if result.Error != nil {
+				return fmt.Errorf("更新副本状态失败: %w", result.Error)
+			}
+			if result.RowsAffected == 1 {
+				claimed = &candidates[i]
+				break
+			}
+		}
+		if claimed == nil {
+			return fmt.Errorf("图书已全部借出")
+		}
+		claimed.Status = models.CopyStatusOnLoan
 
-	if record.BorrowedAt.IsZero() {
-		record.BorrowedAt = time.Now()
-	}
-	if record.DueDate.IsZero() {
-		record.DueDate = record.BorrowedAt.Add(14 * 24 * time.Hour)
-	}
+		record.CopyID = &claimed.ID
+		record.Copy = claimed
+		if record.BorrowedAt.IsZero() {
+			record.BorrowedAt = time.Now()
+		}
+		if record.DueDate.IsZero() {
+			record.DueDate = record.BorrowedAt.Add(14 * 24 * time.Hour)
+		}
 
-	if err := r.db.Create(record).Error; err != nil {
-		return fmt.Errorf("创建借阅记录失败: %w", err)
-	}
+		if err := tx.Omit("Copy", "Book", "User").Create(record).Error; err != nil {
+			return fmt.Errorf("创建借阅记录失败: %w", err)
+		}
 
-	book.Available -= 1
-	return r.db.Save(&book).Error
+		return models.RefreshBookCopyCounts(tx, record.BookID)
+	})
 }
 
-// func (r *borrowRepository) Return(recordID uint) error {
-// 	var record models.BorrowRecord
-// 	if err := r.db.Preload("Book").
-// 		First(&record, recordID).Error; err != nil {
-// 		return fmt.Errorf("借阅记录不存在")
-// 	}
+// Return 归还借阅记录对应的副本，副本恢复为在架
+func (r *borrowRepository) Return(recordID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var record models.BorrowRecord
+		if err := tx.First(&record, recordID).Error; err != nil {
+			return fmt.Errorf("借阅记录不存在")
+		}
 
-// 	if record.ReturnedAt != nil {
-// 		return fmt.Errorf("图书已归还")
-// 	}
+		if record.ReturnedAt != nil {
+			return fmt.Errorf("图书已归还")
+		}
 
-// 	now := time.Now()
-// 	record.ReturnedAt = &now
+		// 以未归还为条件更新，重复扫码归还不会重复处理
+		now := time.Now()
+		result := tx.Model(&models.BorrowRecord{}).
+			Where("id = ? AND returned_at IS NULL", record.ID).
+			Update("returned_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("更新归还时间失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("图书已归还")
+		}
 
-// 	if err := r.db.Save(&record).Error; err != nil {
-// 		return fmt.Errorf("更新归还时间失败: %w", err)
-// 	}
+		if record.CopyID != nil {
+			if err := tx.Model(&models.BookCopy{}).
+				Where("id = ? AND status = ?", *record.CopyID, models.CopyStatusOnLoan).
+				Update("status", models.CopyStatusAvailable).Error; err != nil {
+				return fmt.Errorf("更新副本状态失败: %w", err)
+			}
+		}
 
-// 	if record.Book != nil {
-// 		record.Book.Available += 1
-// 		return r.db.Save(&record.Book).Error
-// 	}
+		return models.RefreshBookCopyCounts(tx, record.BookID)
+	})
+}
 
-//		return nil
-//	}
-func (r *borrowRepository) Return(recordID uint) error {
-	// 1. 获取借阅记录
+// FindActiveByCopy 查找副本当前未归还的借阅记录
+func (r *borrowRepository) FindActiveByCopy(copyID uint) (*models.BorrowRecord, error) {
 	var record models.BorrowRecord
-	if err := r.db.Preload("Book").
-		First(&record, recordID).Error; err != nil {
-		return fmt.Errorf("借阅记录不存在")
-	}
-
-	// 2. 检查是否已归还
-	if record.ReturnedAt != nil {
-		return fmt.Errorf("图书已归还")
-	}
-
-	// 3. 更新归还时间
-	now := time.Now()
-	record.ReturnedAt = &now
-
-	if err := r.db.Save(&record).Error; err != nil {
-		return fmt.Errorf("更新归还时间失败: %w", err)
-	}
+	err := r.db.Preload("Copy").
+		Where("copy_id = ? AND returned_at IS NULL", copyID).
+		First(&record).Error
 
-	// 4. 增加图书可用数量
-	// 修复：检查 Book 的 ID 是否有效
-	if record.Book.ID > 0 {
-		record.Book.Available += 1
-		return r.db.Save(&record.Book).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("该副本没有未归还的借阅记录")
+		}
+		return nil, fmt.Errorf("查询借阅记录失败: %w", err)
 	}
 
-	return nil
+	return &record, nil
 }
 
 func (r *borrowRepository) FindActiveByUserAndBook(userID, bookID uint) (*models.BorrowRecord, error) {
@@ -130,8 +190,9 @@ func (r *borrowRepository) FindActiveByUserAndBook(userID, bookID uint) (*models
 	}
 
 	var record models.BorrowRecord
-	err := r.db.Where("user_id = ? AND book_id = ? AND returned_at IS NULL",
-		userID, bookID).
+	err := r.db.Preload("Copy").
+		Where("user_id = ? AND book_id = ? AND returned_at IS NULL",
+			userID, bookID).
 		First(&record).Error
 
 	if err != nil {
@@ -150,7 +211,7 @@ func (r *borrowRepository) FindActiveByUser(userID uint) ([]models.BorrowRecord,
 	}
 
 	var records []models.BorrowRecord
-	err := r.db.Preload("Book").
+	err := r.db.Preload("Book").Preload("Copy").
 		Where("user_id = ? AND returned_at IS NULL", userID).
 		Order("borrowed_at DESC").
 		Find(&records).Error
@@ -162,33 +223,36 @@ func (r *borrowRepository) FindActiveByUser(userID uint) ([]models.BorrowRecord,
 	return records, nil
 }
 
-func (r *borrowRepository) FindAll() ([]models.BorrowRecord, error) {
-	var records []models.BorrowRecord
-	err := r.db.Preload("User").Preload("Book").
-		Order("borrowed_at DESC").
-		Find(&records).Error
+// Find 按过滤条件分页查询借阅记录，默认按借出时间倒序
+func (r *borrowRepository) Find(filter BorrowRecordFilter, spec QuerySpec) (*Page[models.BorrowRecord], error) {
+	query := r.db.Model(&models.BorrowRecord{}).Preload("User").Preload("Book").Preload("Copy")
 
-	if err != nil {
-		return nil, fmt.Errorf("查询借阅记录失败: %w", err)
+	if filter.UserID != 0 {
+		query = query.Where("borrow_records.user_id = ?", filter.UserID)
 	}
-
-	return records, nil
-}
-
-func (r *borrowRepository) FindByUser(userID uint) ([]models.BorrowRecord, error) {
-	if userID == 0 {
-		return nil, fmt.Errorf("无效的用户ID")
+	if filter.BookID != 0 {
+		query = query.Where("borrow_records.book_id = ?", filter.BookID)
+	}
+	if filter.Active != nil {
+		if *filter.Active {
+			query = query.Where("borrow_records.returned_at IS NULL")
+		} else {
+			query = query.Where("borrow_records.returned_at IS NOT NULL")
+		}
+	}
+	if filter.Overdue {
+		query = query.Where("borrow_records.returned_at IS NULL AND borrow_records.due_date < ?", time.Now())
+	}
+	if filter.BorrowedAfter != nil {
+		query = query.Where("borrow_records.borrowed_at >= ?", *filter.BorrowedAfter)
+	}
+	if filter.BorrowedBefore != nil {
+		query = query.Where("borrow_records.borrowed_at < ?", *filter.BorrowedBefore)
 	}
 
-	var records []models.BorrowRecord
-	err := r.db.Preload("Book").
-		Where("user_id = ?", userID).
-		Order("borrowed_at DESC").
-		Find(&records).Error
-
+	page, err := paginate(query, spec, borrowRecordSorts)
 	if err != nil {
 		return nil, fmt.Errorf("查询借阅记录失败: %w", err)
 	}
-
-	return records, nil
+	return page, nil
 }
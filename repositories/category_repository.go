@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type CategoryRepository interface {
+	Create(category *models.Category) error
+	FindByID(id uint) (*models.Category, error)
+	FindByIDs(ids []uint) ([]models.Category, error)
+	FindAll() ([]models.Category, error)
+	FindChildren(id uint) ([]models.Category, error)
+	FindSiblingByName(parentID *uint, name string) (*models.Category, error)
+	Update(category *models.Category) error
+	Move(category *models.Category, parent *models.Category) error
+	Merge(source, target *models.Category) error
+	Delete(id uint) error
+	CountChildren(id uint) (int64, error)
+	CountBooks(id uint) (int64, error)
+	CountBooksByCategory() (map[uint]int64, error)
+	SubtreeHeight(category *models.Category) (int, error)
+	FindBooks(category *models.Category, includeDescendants bool, spec QuerySpec) (*Page[models.Book], error)
+}
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository() CategoryRepository {
+	return &categoryRepository{db: config.DB}
+}
+
+// Create 创建分类，路径和层级按上级分类计算
+func (r *categoryRepository) Create(category *models.Category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		parentPath := ""
+		category.Depth = 0
+		if category.ParentID != nil {
+			var parent models.Category
+			if err := tx.First(&parent, *category.ParentID).Error; err != nil {
+				return fmt.Errorf("上级分类不存在")
+			}
+			parentPath, category.Depth = parent.Path, parent.Depth+1
+		}
+
+		// 路径包含自身ID，插入后再补上
+		category.Path = ""
+		if err := tx.Create(category).Error; err != nil {
+			return err
+		}
+		category.Path = models.CategoryPath(parentPath, category.ID)
+		return tx.Model(category).UpdateColumn("path", category.Path).Error
+	})
+}
+
+func (r *categoryRepository) FindByID(id uint) (*models.Category, error) {
+	var category models.Category
+	if err := r.db.First(&category, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("分类不存在")
+		}
+		return nil, fmt.Errorf("查询分类失败: %w", err)
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) FindByIDs(ids []uint) ([]models.Category, error) {
+	if len(ids) == 0 {
+		return []models.Category{}, nil
+	}
+	var categories []models.Category
+	if err := r.db.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("查询分类失败: %w", err)
+	}
+	return categories, nil
+}
+
+// FindAll 全部分类，按层级和同级顺序排列
+func (r *categoryRepository) FindAll() ([]models.Category, error) {
+	var categories []models.Category
+	if err := r.db.Order("depth").Order("position").Order("name").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("查询分类失败: %w", err)
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) FindChildren(id uint) ([]models.Category, error) {
+	var categories []models.Category
+	if err := r.db.Where("parent_id = ?", id).Order("position").Order("name").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("查询下级分类失败: %w", err)
+	}
+	return categories, nil
+}
+
+// FindSiblingByName 在同一上级分类下按名称查找，parentID 为空时查找顶级分类
+func (r *categoryRepository) FindSiblingByName(parentID *uint, name string) (*models.Category, error) {
+	var category models.Category
+	db := r.db.Where("LOWER(name) = ?", strings.ToLower(name))
+	if parentID == nil {
+		db = db.Where("parent_id IS NULL")
+	} else {
+		db = db.Where("parent_id = ?", *parentID)
+	}
+	if err := db.First(&category).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("分类不存在")
+		}
+		return nil, fmt.Errorf("查询分类失败: %w", err)
+	}
+	return &category, nil
+}
+
+// Update 保存分类的名称、说明和排列顺序，位置变化通过 Move 完成
+func (r *categoryRepository) Update(category *models.Category) error {
+	return r.db.Model(category).Select("name", "description", "position").Updates(category).Error
+}
+
+// Move 把分类连同下级分类移到 parent 之下，parent 为 nil 时移为顶级分类
+func (r *categoryRepository) Move(category *models.Category, parent *models.Category) error {
+	parentPath, depth := "", 0
+	var parentID *uint
+	if parent != nil {
+		parentPath, depth, parentID = parent.Path, parent.Depth+1, &parent.ID
+	}
+	newPath := models.CategoryPath(parentPath, category.ID)
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := rebaseCategories(tx, category.Path, newPath, depth-category.Depth, 0); err != nil {
+			return err
+		}
+		if err := tx.Model(category).Update("parent_id", parentID).Error; err != nil {
+			return fmt.Errorf("移动分类失败: %w", err)
+		}
+		category.ParentID, category.Path, category.Depth = parentID, newPath, depth
+		return nil
+	})
+}
+
+// Merge 把 source 的图书和下级分类并入 target，然后删除 source
+func (r *categoryRepository) Merge(source, target *models.Category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// target 已有的图书不重复关联
+		var bookIDs []uint
+		if err := tx.Model(&models.BookCategory{}).
+			Where("category_id = ? AND book_id NOT IN (?)", source.ID,
+				tx.Model(&models.BookCategory{}).Select("book_id").Where("category_id = ?", target.ID)).
+			Pluck("book_id", &bookIDs).Error; err != nil {
+			return fmt.Errorf("查询分类图书失败: %w", err)
+		}
+		if len(bookIDs) > 0 {
+			links := make([]models.BookCategory, len(bookIDs))
+			for i, bookID := range bookIDs {
+				links[i] = models.BookCategory{BookID: bookID, CategoryID: target.ID}
+			}
+			if err := tx.CreateInBatches(&links, 500).Error; err != nil {
+				return fmt.Errorf("合并分类图书失败: %w", err)
+			}
+		}
+		if err := tx.Where("category_id = ?", source.ID).Delete(&models.BookCategory{}).Error; err != nil {
+			return fmt.Errorf("删除分类图书失败: %w", err)
+		}
+
+		// 下级分类整体挂到 target 之下
+		if err := rebaseCategories(tx, source.Path, target.Path, target.Depth-source.Depth, source.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", source.ID).
+			Update("parent_id", target.ID).Error; err != nil {
+			return fmt.Errorf("移动下级分类失败: %w", err)
+		}
+
+		return tx.Delete(&models.Category{}, source.ID).Error
+	})
+}
+
+func (r *categoryRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Category{}, id).Error
+}
+
+func (r *categoryRepository) CountChildren(id uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.Category{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("查询下级分类失败: %w", err)
+	}
+	return count, nil
+}
+
+func (r *categoryRepository) CountBooks(id uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.BookCategory{}).Where("category_id = ?", id).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("查询分类图书失败: %w", err)
+	}
+	return count, nil
+}
+
+// CountBooksByCategory 各分类直接关联的图书数，没有图书的分类不在结果中
+func (r *categoryRepository) CountBooksByCategory() (map[uint]int64, error) {
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	if err := r.db.Model(&models.BookCategory{}).
+		Select("category_id, COUNT(*) AS count").
+		Group("category_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计分类图书失败: %w", err)
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts, nil
+}
+
+// SubtreeHeight 分类之下最深一级与分类自身的层级差，没有下级分类时为 0
+func (r *categoryRepository) SubtreeHeight(category *models.Category) (int, error) {
+	var maxDepth int
+	if err := r.db.Model(&models.Category{}).
+		Where("path LIKE ?", category.Path+"%").
+		Select("COALESCE(MAX(depth), 0)").
+		Scan(&maxDepth).Error; err != nil {
+		return 0, fmt.Errorf("查询下级分类失败: %w", err)
+	}
+	return maxDepth - category.Depth, nil
+}
+
+// FindBooks 分类中的图书，includeDescendants 为 true 时包含全部下级分类的图书，排序方式与图书列表相同
+func (r *categoryRepository) FindBooks(category *models.Category, includeDescendants bool, spec QuerySpec) (*Page[models.Book], error) {
+	linked := r.db.Model(&models.BookCategory{}).Select("book_categories.book_id")
+	if includeDescendants {
+		linked = linked.Joins("JOIN categories ON categories.id = book_categories.category_id").
+			Where("categories.path LIKE ?", category.Path+"%")
+	} else {
+		linked = linked.Where("book_categories.category_id = ?", category.ID)
+	}
+
+	page, err := paginate(r.db.Model(&models.Book{}).Where("books.id IN (?)", linked), spec, bookSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询分类图书失败: %w", err)
+	}
+	return page, nil
+}
+
+// rebaseCategories 把路径以 oldPrefix 开头的分类改为以 newPrefix 开头，层级同时调整 depthDelta
+// excludeID 不为 0 时跳过该分类
+func rebaseCategories(tx *gorm.DB, oldPrefix, newPrefix string, depthDelta int, excludeID uint) error {
+	var categories []models.Category
+	if err := tx.Where("path LIKE ?", oldPrefix+"%").Find(&categories).Error; err != nil {
+		return fmt.Errorf("查询下级分类失败: %w", err)
+	}
+	for _, c := range categories {
+		if c.ID == excludeID {
+			continue
+		}
+		if err := tx.Model(&models.Category{}).Where("id = ?", c.ID).UpdateColumns(map[string]any{
+			"path":  newPrefix + strings.TrimPrefix(c.Path, oldPrefix),
+			"depth": c.Depth + depthDelta,
+		}).Error; err != nil {
+			return fmt.Errorf("更新分类路径失败: %w", err)
+		}
+	}
+	return nil
+}
@@ -8,13 +8,14 @@ import (
 
 type BookRepositoryWithBorrow interface {
 	BookRepository
-	BorrowBook(userID, bookID uint) error
+	BorrowBook(userID, bookID uint) (*models.BorrowRecord, error)
+	BorrowCopy(userID, copyID uint) (*models.BorrowRecord, error)
 	ReturnBook(userID, bookID uint) error
+	ReturnBorrowRecord(recordID uint) error
 	GetBorrowedBooks(userID uint) ([]models.Book, error)
-	GetBorrowRecords(userID uint) ([]models.BorrowRecord, error)
-	GetAllBorrowRecords() ([]models.BorrowRecord, error)
+	FindBorrowRecords(filter BorrowRecordFilter, spec QuerySpec) (*Page[models.BorrowRecord], error)
 	GetActiveBorrowRecord(userID, bookID uint) (*models.BorrowRecord, error)
-	ExistsByTitleAndAuthor(title, author string) (bool, error)
+	GetActiveBorrowRecordByCopy(copyID uint) (*models.BorrowRecord, error)
 }
 
 type combinedBookRepository struct {
@@ -45,30 +46,39 @@ func (r *combinedBookRepository) Delete(id uint) error {
 	return r.bookRepo.Delete(id)
 }
 
-func (r *combinedBookRepository) FindAll() ([]models.Book, error) {
-	return r.bookRepo.FindAll()
-}
-
-func (r *combinedBookRepository) FindAvailable() ([]models.Book, error) {
-	return r.bookRepo.FindAvailable()
-}
-
-func (r *combinedBookRepository) Search(query string) ([]models.Book, error) {
-	return r.bookRepo.Search(query)
+func (r *combinedBookRepository) Find(filter BookFilter, spec QuerySpec) (*Page[models.Book], error) {
+	return r.bookRepo.Find(filter, spec)
 }
 
 func (r *combinedBookRepository) CheckAvailability(bookID uint) (bool, error) {
 	return r.bookRepo.CheckAvailability(bookID)
 }
 
-func (r *combinedBookRepository) BorrowBook(userID, bookID uint) error {
+func (r *combinedBookRepository) BorrowBook(userID, bookID uint) (*models.BorrowRecord, error) {
 	record := &models.BorrowRecord{
 		UserID:     userID,
 		BookID:     bookID,
 		BorrowedAt: time.Now(),
 		DueDate:    time.Now().Add(14 * 24 * time.Hour),
 	}
-	return r.borrowRepo.Borrow(record)
+	if err := r.borrowRepo.Borrow(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// BorrowCopy 借出指定的副本，图书ID取自副本
+func (r *combinedBookRepository) BorrowCopy(userID, copyID uint) (*models.BorrowRecord, error) {
+	record := &models.BorrowRecord{
+		UserID:     userID,
+		CopyID:     &copyID,
+		BorrowedAt: time.Now(),
+		DueDate:    time.Now().Add(14 * 24 * time.Hour),
+	}
+	if err := r.borrowRepo.Borrow(record); err != nil {
+		return nil, err
+	}
+	return record, nil
 }
 
 func (r *combinedBookRepository) ReturnBook(userID, bookID uint) error {
@@ -80,6 +90,10 @@ func (r *combinedBookRepository) ReturnBook(userID, bookID uint) error {
 	return r.borrowRepo.Return(record.ID)
 }
 
+func (r *combinedBookRepository) ReturnBorrowRecord(recordID uint) error {
+	return r.borrowRepo.Return(recordID)
+}
+
 func (r *combinedBookRepository) GetBorrowedBooks(userID uint) ([]models.Book, error) {
 	records, err := r.borrowRepo.FindActiveByUser(userID)
 	if err != nil {
@@ -97,18 +111,18 @@ func (r *combinedBookRepository) GetBorrowedBooks(userID uint) ([]models.Book, e
 	return books, nil
 }
 
-func (r *combinedBookRepository) GetBorrowRecords(userID uint) ([]models.BorrowRecord, error) {
-	return r.borrowRepo.FindByUser(userID)
-}
-
-func (r *combinedBookRepository) GetAllBorrowRecords() ([]models.BorrowRecord, error) {
-	return r.borrowRepo.FindAll()
+func (r *combinedBookRepository) FindBorrowRecords(filter BorrowRecordFilter, spec QuerySpec) (*Page[models.BorrowRecord], error) {
+	return r.borrowRepo.Find(filter, spec)
 }
 
 func (r *combinedBookRepository) GetActiveBorrowRecord(userID, bookID uint) (*models.BorrowRecord, error) {
 	return r.borrowRepo.FindActiveByUserAndBook(userID, bookID)
 }
 
-func (r *combinedBookRepository) ExistsByTitleAndAuthor(title, author string) (bool, error) {
-	return r.bookRepo.ExistsByTitleAndAuthor(title, author)
+func (r *combinedBookRepository) GetActiveBorrowRecordByCopy(copyID uint) (*models.BorrowRecord, error) {
+	return r.borrowRepo.FindActiveByCopy(copyID)
+}
+
+func (r *combinedBookRepository) FindByISBN(isbn string) (*models.Book, error) {
+	return r.bookRepo.FindByISBN(isbn)
 }
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// loginAttemptRetention 长时间没有更新且未处于锁定状态的计数器会被清理
+	loginAttemptRetention = 24 * time.Hour
+	// loginAttemptSweepInterval 两次批量清理之间的最短间隔，清理由写入顺带触发
+	loginAttemptSweepInterval = 10 * time.Minute
+)
+
+// attemptExpired 计数器已超过保留期且不在锁定中
+func attemptExpired(attempt *models.LoginAttempt, now time.Time) bool {
+	return now.Sub(attempt.UpdatedAt) > loginAttemptRetention &&
+		(attempt.LockedUntil == nil || now.After(*attempt.LockedUntil))
+}
+
+// LoginAttemptRepository 登录失败次数和请求频率计数存储
+// 默认使用进程内存实现；多实例部署时应切换为数据库实现，使计数在实例间共享
+type LoginAttemptRepository interface {
+	Get(key string) (*models.LoginAttempt, error)
+	Save(attempt *models.LoginAttempt) error
+	Delete(key string) error
+}
+
+// NewLoginAttemptRepository 根据配置选择计数存储实现
+func NewLoginAttemptRepository() LoginAttemptRepository {
+	if config.AppConfig.LoginAttemptStore == "database" {
+		return NewDatabaseLoginAttemptRepository()
+	}
+	return NewMemoryLoginAttemptRepository()
+}
+
+// ---------------- 内存实现 ----------------
+
+type memoryLoginAttemptRepository struct {
+	mu        sync.Mutex
+	attempts  map[string]models.LoginAttempt
+	lastSweep time.Time
+}
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{attempts: make(map[string]models.LoginAttempt)}
+}
+
+// Get 返回计数器的副本，不存在或已过期时返回 nil
+func (r *memoryLoginAttemptRepository) Get(key string) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	if attemptExpired(&attempt, time.Now()) {
+		delete(r.attempts, key)
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) Save(attempt *models.LoginAttempt) error {
+	if attempt.Key == "" {
+		return fmt.Errorf("计数键不能为空")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 每隔一段时间顺带清理过期的计数器，避免内存无限增长
+	now := time.Now()
+	if now.Sub(r.lastSweep) >= loginAttemptSweepInterval {
+		for key, existing := range r.attempts {
+			if attemptExpired(&existing, now) {
+				delete(r.attempts, key)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	attempt.UpdatedAt = now
+	r.attempts[attempt.Key] = *attempt
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+// ---------------- 数据库实现 ----------------
+
+type databaseLoginAttemptRepository struct {
+	db *gorm.DB
+
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDatabaseLoginAttemptRepository() LoginAttemptRepository {
+	return &databaseLoginAttemptRepository{db: config.DB}
+}
+
+func (r *databaseLoginAttemptRepository) Get(key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Where("attempt_key = ?", key).First(&attempt).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询登录计数失败: %w", err)
+	}
+	if attemptExpired(&attempt, time.Now()) {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (r *databaseLoginAttemptRepository) Save(attempt *models.LoginAttempt) error {
+	if attempt.Key == "" {
+		return fmt.Errorf("计数键不能为空")
+	}
+
+	if err := r.sweep(); err != nil {
+		return err
+	}
+
+	if err := r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(attempt).Error; err != nil {
+		return fmt.Errorf("保存登录计数失败: %w", err)
+	}
+	return nil
+}
+
+// sweep 每个实例每隔一段时间批量删除一次过期的计数器，期间过期的记录由 Get 忽略
+func (r *databaseLoginAttemptRepository) sweep() error {
+	r.sweepMu.Lock()
+	defer r.sweepMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastSweep) < loginAttemptSweepInterval {
+		return nil
+	}
+
+	if err := r.db.Where("updated_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-loginAttemptRetention), now).
+		Delete(&models.LoginAttempt{}).Error; err != nil {
+		return fmt.Errorf("清理过期登录计数失败: %w", err)
+	}
+	r.lastSweep = now
+	return nil
+}
+
+func (r *databaseLoginAttemptRepository) Delete(key string) error {
+	if err := r.db.Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return fmt.Errorf("删除登录计数失败: %w", err)
+	}
+	return nil
+}
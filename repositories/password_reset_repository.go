@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	Create(token *models.PasswordResetToken) error
+	FindByHash(tokenHash string) (*models.PasswordResetToken, error)
+	Consume(token *models.PasswordResetToken, passwordHash string) (bool, error)
+	Reset(userID uint, passwordHash string) error
+}
+
+// errResetTokenUsed 令牌已被并发请求使用，用于回滚 Consume 的事务
+var errResetTokenUsed = errors.New("重置令牌已使用")
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository() PasswordResetRepository {
+	return &passwordResetRepository{db: config.DB}
+}
+
+func (r *passwordResetRepository) Create(token *models.PasswordResetToken) error {
+	if token.UserID == 0 || token.TokenHash == "" {
+		return fmt.Errorf("重置令牌信息不完整")
+	}
+
+	if err := r.db.Create(token).Error; err != nil {
+		return fmt.Errorf("保存重置令牌失败: %w", err)
+	}
+	return nil
+}
+
+func (r *passwordResetRepository) FindByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("重置令牌不存在")
+		}
+		return nil, fmt.Errorf("查询重置令牌失败: %w", err)
+	}
+
+	return &token, nil
+}
+
+// Consume 在同一事务中将令牌标记为已使用、更新用户密码、作废该用户的其他重置令牌并撤销其全部 API 密钥
+// 只有第一次调用会返回 true，保证令牌只能使用一次；任一步失败都会整体回滚，令牌仍可再次使用
+func (r *passwordResetRepository) Consume(token *models.PasswordResetToken, passwordHash string) (bool, error) {
+	if passwordHash == "" {
+		return false, fmt.Errorf("新密码不能为空")
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("更新重置令牌失败: %w", result.Error)
+		}
+		if result.RowsAffected != 1 {
+			return errResetTokenUsed
+		}
+
+		return replacePassword(tx, token.UserID, passwordHash, now)
+	})
+	if errors.Is(err, errResetTokenUsed) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Reset 不经过重置令牌直接设置新密码，供管理员和命令行使用
+// 与 Consume 一样在同一事务中作废该用户未使用的重置令牌并撤销其全部 API 密钥
+func (r *passwordResetRepository) Reset(userID uint, passwordHash string) error {
+	if passwordHash == "" {
+		return fmt.Errorf("新密码不能为空")
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replacePassword(tx, userID, passwordHash, time.Now())
+	})
+}
+
+func replacePassword(tx *gorm.DB, userID uint, passwordHash string, now time.Time) error {
+	result := tx.Model(&models.User{}).Where("id = ?", userID).Update("password", passwordHash)
+	if result.Error != nil {
+		return fmt.Errorf("更新密码失败: %w", result.Error)
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("用户不存在")
+	}
+
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error; err != nil {
+		return fmt.Errorf("作废重置令牌失败: %w", err)
+	}
+
+	// 密码可能已泄露，用旧密码登录后创建的密钥也不再可信
+	if err := tx.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("撤销API密钥失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidQuery 分页、排序或游标参数无效
+var ErrInvalidQuery = errors.New("无效的查询参数")
+
+// QuerySpec 列表查询共用的分页和排序方式，过滤条件由各列表的 XxxFilter 给出
+// 提供 Cursor 时按游标翻页并忽略 Page，游标取自上一页的 NextCursor，排序方式沿用生成游标时的设置
+type QuerySpec struct {
+	Page     int // 从 1 开始
+	PageSize int // 缺省为 DefaultPageSize，最大 MaxPageSize
+	Cursor   string
+	Sort     string // 排序字段，只能使用各列表允许的字段，为空时使用列表的默认排序
+	Order    string // asc 或 desc，为空时使用该字段的默认方向
+}
+
+// Page 分页结果
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`          // 符合过滤条件的总数
+	Page       int    `json:"page,omitempty"` // 按游标翻页时为空
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"` // 没有下一页时为空
+}
+
+// MapPage 逐项转换分页结果，分页信息保持不变
+func MapPage[T, U any](page *Page[T], fn func(T) U) *Page[U] {
+	items := make([]U, len(page.Items))
+	for i, item := range page.Items {
+		items[i] = fn(item)
+	}
+	return &Page[U]{Items: items, Total: page.Total, Page: page.Page, PageSize: page.PageSize, NextCursor: page.NextCursor}
+}
+
+// sortField 列表允许排序的一个字段，字段值不能为 NULL，否则游标无法比较
+type sortField[T any] struct {
+	column string       // 带表名的列名或表达式
+	desc   bool         // 未指定方向时是否倒序
+	value  func(*T) any // 记录中该字段的值，写入游标
+}
+
+// sortOptions 一个列表的排序白名单，排序值相同时按 ID 排列，使游标位置唯一
+type sortOptions[T any] struct {
+	fields      map[string]sortField[T]
+	defaultSort string
+	idColumn    string
+	id          func(*T) uint
+}
+
+// pageCursor 游标记录上一页最后一条的排序值和ID，以及生成游标时的排序方式
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// paginate 对已加好过滤条件的 query 计数并取出一页，query 上的 Preload 只作用于取出的记录
+func paginate[T any](query *gorm.DB, spec QuerySpec, sorts sortOptions[T]) (*Page[T], error) {
+	pageSize := spec.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	sortName, desc, err := sorts.resolve(spec.Sort, spec.Order)
+	if err != nil {
+		return nil, err
+	}
+	var after *pageCursor
+	if spec.Cursor != "" {
+		if after, err = decodeCursor(spec.Cursor); err != nil {
+			return nil, err
+		}
+		if (spec.Sort != "" && spec.Sort != after.Sort) || (spec.Order != "" && desc != after.Desc) {
+			return nil, fmt.Errorf("%w: 游标与排序方式不一致", ErrInvalidQuery)
+		}
+		sortName, desc = after.Sort, after.Desc
+	}
+	field, ok := sorts.fields[sortName]
+	if !ok {
+		return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidQuery)
+	}
+
+	page := &Page[T]{PageSize: pageSize}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, compare := "ASC", ">"
+	if desc {
+		direction, compare = "DESC", "<"
+	}
+	rows := query.Order(field.column + " " + direction).Order(sorts.idColumn + " " + direction)
+	if after != nil {
+		value, err := cursorValue(field, after.Value)
+		if err != nil {
+			return nil, err
+		}
+		rows = rows.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))",
+			field.column, compare, field.column, sorts.idColumn, compare), value, value, after.ID)
+	} else {
+		page.Page = max(spec.Page, 1)
+		rows = rows.Offset((page.Page - 1) * pageSize)
+	}
+
+	// 多取一条判断是否还有下一页
+	var items []T
+	if err := rows.Limit(pageSize + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) > pageSize {
+		items = items[:pageSize]
+		last := &items[pageSize-1]
+		page.NextCursor, err = encodeCursor(sortName, desc, field.value(last), sorts.id(last))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if items == nil {
+		items = []T{}
+	}
+	page.Items = items
+	return page, nil
+}
+
+// resolve 校验排序字段和方向，返回实际使用的字段名和是否倒序
+func (o sortOptions[T]) resolve(name, order string) (string, bool, error) {
+	if name == "" {
+		name = o.defaultSort
+	}
+	field, ok := o.fields[name]
+	if !ok {
+		allowed := make([]string, 0, len(o.fields))
+		for allowedName := range o.fields {
+			allowed = append(allowed, allowedName)
+		}
+		sort.Strings(allowed)
+		return "", false, fmt.Errorf("%w: 不支持按 %s 排序，可用字段: %s", ErrInvalidQuery, name, strings.Join(allowed, ", "))
+	}
+
+	switch strings.ToLower(order) {
+	case "":
+		return name, field.desc, nil
+	case "asc":
+		return name, false, nil
+	case "desc":
+		return name, true, nil
+	}
+	return "", false, fmt.Errorf("%w: 排序方向只能是 asc 或 desc", ErrInvalidQuery)
+}
+
+func encodeCursor(sort string, desc bool, value any, id uint) (string, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(pageCursor{Sort: sort, Desc: desc, Value: raw, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidQuery)
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidQuery)
+	}
+	return &c, nil
+}
+
+// cursorValue 按字段值的类型解析游标中的排序值，时间等类型才能与数据库列正确比较
+func cursorValue[T any](field sortField[T], raw json.RawMessage) (any, error) {
+	target := reflect.New(reflect.TypeOf(field.value(new(T))))
+	if err := json.Unmarshal(raw, target.Interface()); err != nil {
+		return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidQuery)
+	}
+	return target.Elem().Interface(), nil
+}
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// pageItem 分页测试使用的记录，Rank 有重复值，用于检查按 ID 区分排序值相同的记录
+type pageItem struct {
+	ID   uint `gorm:"primarykey"`
+	Rank int
+	Name string
+}
+
+var pageItemSorts = sortOptions[pageItem]{
+	fields: map[string]sortField[pageItem]{
+		"rank": {column: "page_items.rank", value: func(p *pageItem) any { return p.Rank }},
+		"name": {column: "page_items.name", value: func(p *pageItem) any { return p.Name }},
+		"id":   {column: "page_items.id", value: func(p *pageItem) any { return p.ID }},
+	},
+	defaultSort: "rank",
+	idColumn:    "page_items.id",
+	id:          func(p *pageItem) uint { return p.ID },
+}
+
+// openPageTestDB 创建只有 page_items 表的内存 SQLite 数据库并写入7条记录
+func openPageTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	// 内存数据库只对单个连接可见
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&pageItem{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	items := []pageItem{
+		{ID: 1, Rank: 2, Name: "c"},
+		{ID: 2, Rank: 1, Name: "a"},
+		{ID: 3, Rank: 2, Name: "a"},
+		{ID: 4, Rank: 3, Name: "b"},
+		{ID: 5, Rank: 1, Name: "c"},
+		{ID: 6, Rank: 2, Name: "b"},
+		{ID: 7, Rank: 3, Name: "a"},
+	}
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatalf("写入测试数据失败: %v", err)
+	}
+	return db
+}
+
+func TestPaginateCursor(t *testing.T) {
+	tests := []struct {
+		name string
+		spec QuerySpec
+		// wantIDs 沿 NextCursor 翻完所有页后依次得到的记录
+		wantIDs   []uint
+		wantPages int
+	}{
+		{
+			name:      "排序值相同时按 ID 排列",
+			spec:      QuerySpec{PageSize: 2},
+			wantIDs:   []uint{2, 5, 1, 3, 6, 4, 7},
+			wantPages: 4,
+		},
+		{
+			name:      "倒序时 ID 也倒序",
+			spec:      QuerySpec{PageSize: 3, Order: "desc"},
+			wantIDs:   []uint{7, 4, 6, 3, 1, 5, 2},
+			wantPages: 3,
+		},
+		{
+			name:      "游标跨过排序值相同的记录",
+			spec:      QuerySpec{PageSize: 1, Sort: "name"},
+			wantIDs:   []uint{2, 3, 7, 4, 6, 1, 5},
+			wantPages: 7,
+		},
+		{
+			name:      "页大小等于总数时只有一页",
+			spec:      QuerySpec{PageSize: 7, Sort: "id"},
+			wantIDs:   []uint{1, 2, 3, 4, 5, 6, 7},
+			wantPages: 1,
+		},
+		{
+			name:      "页大小超过总数时只有一页",
+			spec:      QuerySpec{PageSize: 50, Sort: "id", Order: "desc"},
+			wantIDs:   []uint{7, 6, 5, 4, 3, 2, 1},
+			wantPages: 1,
+		},
+		{
+			name:      "按页码翻页后可以接着使用游标",
+			spec:      QuerySpec{PageSize: 2, Page: 3},
+			wantIDs:   []uint{6, 4, 7},
+			wantPages: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openPageTestDB(t)
+
+			var ids []uint
+			spec := tt.spec
+			pages := 0
+			for {
+				page, err := paginate(db.Model(&pageItem{}), spec, pageItemSorts)
+				if err != nil {
+					t.Fatalf("第 %d 页查询失败: %v", pages+1, err)
+				}
+				pages++
+				if page.Total != 7 {
+					t.Errorf("第 %d 页 Total = %d，期望 7", pages, page.Total)
+				}
+				if page.NextCursor != "" && len(page.Items) != spec.PageSize {
+					t.Errorf("第 %d 页有下一页但只有 %d 条记录", pages, len(page.Items))
+				}
+				for _, item := range page.Items {
+					ids = append(ids, item.ID)
+				}
+				if page.NextCursor == "" || pages > len(tt.wantIDs) {
+					break
+				}
+				spec.Cursor = page.NextCursor
+			}
+
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("记录顺序 = %v，期望 %v", ids, tt.wantIDs)
+			}
+			if pages != tt.wantPages {
+				t.Errorf("页数 = %d，期望 %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestPaginateInvalidQuery(t *testing.T) {
+	mustEncode := func(sort string, desc bool, value any, id uint) string {
+		cursor, err := encodeCursor(sort, desc, value, id)
+		if err != nil {
+			t.Fatalf("生成游标失败: %v", err)
+		}
+		return cursor
+	}
+
+	tests := []struct {
+		name string
+		spec QuerySpec
+	}{
+		{name: "不支持的排序字段", spec: QuerySpec{Sort: "created_at"}},
+		{name: "排序方向无效", spec: QuerySpec{Order: "up"}},
+		{name: "游标不是 base64", spec: QuerySpec{Cursor: "!!!"}},
+		{name: "游标不是 JSON", spec: QuerySpec{Cursor: "bm90LWpzb24"}},
+		{name: "游标中的排序字段不存在", spec: QuerySpec{Cursor: mustEncode("created_at", false, 1, 1)}},
+		{name: "游标中的排序值类型不符", spec: QuerySpec{Cursor: mustEncode("rank", false, "a", 1)}},
+		{name: "游标与排序字段不一致", spec: QuerySpec{Sort: "name", Cursor: mustEncode("rank", false, 1, 1)}},
+		{name: "游标与排序方向不一致", spec: QuerySpec{Order: "desc", Cursor: mustEncode("rank", false, 1, 1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openPageTestDB(t)
+
+			_, err := paginate(db.Model(&pageItem{}), tt.spec, pageItemSorts)
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("期望 ErrInvalidQuery，实际返回 %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval 清理已经补满、不再需要保存的令牌桶的间隔
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitResult 一次取令牌的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // 取令牌后桶中剩余的整数令牌
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌可用的时间
+	ResetAfter time.Duration // 距离令牌桶补满的时间
+}
+
+// RateLimitStore 令牌桶存储，实现必须保证同一个键的取令牌操作是原子的
+// 默认使用进程内存实现；多实例部署需要共享计数时，可以基于 Redis 等实现该接口
+type RateLimitStore interface {
+	// Take 从 key 对应的令牌桶中取一个令牌，桶容量为 capacity，每秒补充 refillRate 个令牌
+	Take(key string, capacity int, refillRate float64, now time.Time) (RateLimitResult, error)
+}
+
+// NewRateLimitStore 返回进程内存中的令牌桶存储
+// RATE_LIMIT_STORE 目前只接受 memory，由 config.Validate 检查；增加共享存储实现后在这里按配置选择
+func NewRateLimitStore() RateLimitStore {
+	return NewMemoryRateLimitStore()
+}
+
+// ---------------- 内存实现 ----------------
+
+type tokenBucket struct {
+	tokens     float64
+	updatedAt  time.Time
+	capacity   int
+	refillRate float64
+}
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *memoryRateLimitStore) Take(key string, capacity int, refillRate float64, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(capacity), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+	bucket.capacity = capacity
+	bucket.refillRate = refillRate
+	bucket.refill(now)
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / refillRate)
+	}
+	result.Remaining = int(math.Floor(bucket.tokens))
+	result.ResetAfter = secondsToDuration((float64(capacity) - bucket.tokens) / refillRate)
+	return result, nil
+}
+
+// refill 按经过的时间补充令牌，不超过桶容量
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updatedAt).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.capacity), b.tokens+elapsed*b.refillRate)
+	}
+	b.updatedAt = now
+}
+
+// sweep 删除已经补满的令牌桶，再次访问时按满桶重新创建，结果相同
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.capacity) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceForUser(userID uint, codeHashes []string) error
+	Consume(userID uint, codeHash string) (bool, error)
+	CountUnused(userID uint) (int64, error)
+	DeleteByUser(userID uint) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository() RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: config.DB}
+}
+
+// ReplaceForUser 删除用户原有的恢复码并写入新的一组
+func (r *recoveryCodeRepository) ReplaceForUser(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("删除旧恢复码失败: %w", err)
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if err := tx.Create(&codes).Error; err != nil {
+			return fmt.Errorf("保存恢复码失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// Consume 使用一个未使用过的恢复码，只有第一次调用会返回 true
+func (r *recoveryCodeRepository) Consume(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("使用恢复码失败: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计恢复码失败: %w", err)
+	}
+	return count, nil
+}
+
+func (r *recoveryCodeRepository) DeleteByUser(userID uint) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("删除恢复码失败: %w", err)
+	}
+	return nil
+}
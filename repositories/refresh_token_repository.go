@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(tokenHash string) (*models.RefreshToken, error)
+	MarkUsed(id uint) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeAllByUser(userID uint) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository() RefreshTokenRepository {
+	return &refreshTokenRepository{db: config.DB}
+}
+
+func (r *refreshTokenRepository) Create(token *models.RefreshToken) error {
+	if token.UserID == 0 || token.TokenHash == "" || token.FamilyID == "" {
+		return fmt.Errorf("刷新令牌信息不完整")
+	}
+
+	if err := r.db.Create(token).Error; err != nil {
+		return fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("刷新令牌不存在")
+		}
+		return nil, fmt.Errorf("查询刷新令牌失败: %w", err)
+	}
+
+	return &token, nil
+}
+
+// MarkUsed 将令牌标记为已使用，只有第一次调用会返回 true，用于防止并发重放
+func (r *refreshTokenRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("更新刷新令牌失败: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	if err := r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("撤销令牌族失败: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeAllByUser(userID uint) error {
+	if err := r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("撤销用户刷新令牌失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type RoleRepository interface {
+	Create(role *models.Role) error
+	FindByName(name models.UserRole) (*models.Role, error)
+	FindAll() ([]models.Role, error)
+	Update(role *models.Role) error
+	Delete(name models.UserRole) error
+	ExistsByName(name models.UserRole) (bool, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository() RoleRepository {
+	return &roleRepository{db: config.DB}
+}
+
+func (r *roleRepository) Create(role *models.Role) error {
+	if role.Name == "" {
+		return fmt.Errorf("角色名不能为空")
+	}
+
+	exists, err := r.ExistsByName(role.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("角色已存在")
+	}
+
+	return r.db.Create(role).Error
+}
+
+func (r *roleRepository) FindByName(name models.UserRole) (*models.Role, error) {
+	if name == "" {
+		return nil, fmt.Errorf("角色名不能为空")
+	}
+
+	var role models.Role
+	err := r.db.Where("name = ?", name).First(&role).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("角色不存在")
+		}
+		return nil, fmt.Errorf("查询角色失败: %w", err)
+	}
+
+	return &role, nil
+}
+
+func (r *roleRepository) FindAll() ([]models.Role, error) {
+	var roles []models.Role
+
+	if err := r.db.Order("id ASC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("查询角色列表失败: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) Update(role *models.Role) error {
+	var existing models.Role
+	if err := r.db.First(&existing, role.ID).Error; err != nil {
+		return fmt.Errorf("角色不存在")
+	}
+
+	return r.db.Save(role).Error
+}
+
+func (r *roleRepository) Delete(name models.UserRole) error {
+	var role models.Role
+	if err := r.db.Where("name = ?", name).First(&role).Error; err != nil {
+		return fmt.Errorf("角色不存在")
+	}
+
+	var userCount int64
+	if err := r.db.Model(&models.User{}).
+		Where("role = ?", name).
+		Count(&userCount).Error; err != nil {
+		return fmt.Errorf("检查角色使用情况失败: %w", err)
+	}
+
+	if userCount > 0 {
+		return fmt.Errorf("仍有 %d 个用户使用该角色，无法删除", userCount)
+	}
+
+	return r.db.Delete(&role).Error
+}
+
+func (r *roleRepository) ExistsByName(name models.UserRole) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Role{}).
+		Where("name = ?", name).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询角色存在性失败: %w", err)
+	}
+	return count > 0, nil
+}
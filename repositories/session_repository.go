@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	FindByID(id uint) (*models.Session, error)
+	FindByFamily(familyID string) (*models.Session, error)
+	FindActiveByUser(userID uint, spec QuerySpec) (*Page[models.Session], error)
+	Touch(id uint, ip string, lastSeenAt time.Time) error
+	Extend(id uint, ip string, expiresAt time.Time) error
+	Revoke(id uint) error
+	RevokeByFamily(familyID string) error
+	RevokeAllByUser(userID uint) error
+}
+
+// sessionSorts 会话列表允许的排序字段
+var sessionSorts = sortOptions[models.Session]{
+	fields: map[string]sortField[models.Session]{
+		"last_seen_at": {column: "sessions.last_seen_at", desc: true, value: func(s *models.Session) any { return s.LastSeenAt }},
+		"created_at":   {column: "sessions.created_at", desc: true, value: func(s *models.Session) any { return s.CreatedAt }},
+		"id":           {column: "sessions.id", value: func(s *models.Session) any { return s.ID }},
+	},
+	defaultSort: "last_seen_at",
+	idColumn:    "sessions.id",
+	id:          func(s *models.Session) uint { return s.ID },
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{db: config.DB}
+}
+
+func (r *sessionRepository) Create(session *models.Session) error {
+	if session.UserID == 0 || session.FamilyID == "" {
+		return fmt.Errorf("会话信息不完整")
+	}
+
+	if err := r.db.Create(session).Error; err != nil {
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) FindByID(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.First(&session, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("会话不存在")
+		}
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) FindByFamily(familyID string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("会话不存在")
+		}
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	return &session, nil
+}
+
+// FindActiveByUser 分页查询用户未撤销且未过期的会话，默认最近活跃的在前
+func (r *sessionRepository) FindActiveByUser(userID uint, spec QuerySpec) (*Page[models.Session], error) {
+	query := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now())
+
+	page, err := paginate(query, spec, sessionSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	return page, nil
+}
+
+// Touch 记录会话最近一次使用的时间和 IP
+func (r *sessionRepository) Touch(id uint, ip string, lastSeenAt time.Time) error {
+	return r.db.Model(&models.Session{}).Where("id = ?", id).Updates(map[string]any{
+		"ip":           ip,
+		"last_seen_at": lastSeenAt,
+	}).Error
+}
+
+// Extend 刷新令牌轮换后顺延会话的到期时间
+func (r *sessionRepository) Extend(id uint, ip string, expiresAt time.Time) error {
+	return r.db.Model(&models.Session{}).Where("id = ?", id).Updates(map[string]any{
+		"ip":           ip,
+		"last_seen_at": time.Now(),
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+func (r *sessionRepository) Revoke(id uint) error {
+	if err := r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("撤销会话失败: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeByFamily(familyID string) error {
+	if err := r.db.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("撤销会话失败: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllByUser(userID uint) error {
+	if err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("撤销用户会话失败: %w", err)
+	}
+	return nil
+}
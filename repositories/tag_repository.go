@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type TagRepository interface {
+	FindByID(id uint) (*models.Tag, error)
+	FindByKey(key string) (*models.Tag, error)
+	Search(query string, spec QuerySpec) (*Page[models.TagCount], error)
+	Update(tag *models.Tag) error
+	Merge(source, target *models.Tag) error
+	Delete(id uint) error
+	FindBooks(tagID uint, spec QuerySpec) (*Page[models.Book], error)
+}
+
+// tagBookCount 标签的图书数，用子查询而不是 JOIN 分组，计数和排序都可以直接使用
+const tagBookCount = "(SELECT COUNT(*) FROM book_tags WHERE book_tags.tag_id = tags.id)"
+
+// tagSorts 标签列表允许的排序字段
+var tagSorts = sortOptions[models.TagCount]{
+	fields: map[string]sortField[models.TagCount]{
+		"book_count": {column: tagBookCount, desc: true, value: func(t *models.TagCount) any { return t.BookCount }},
+		"name":       {column: "tags.name", value: func(t *models.TagCount) any { return t.Name }},
+		"id":         {column: "tags.id", value: func(t *models.TagCount) any { return t.ID }},
+	},
+	defaultSort: "book_count",
+	idColumn:    "tags.id",
+	id:          func(t *models.TagCount) uint { return t.ID },
+}
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository() TagRepository {
+	return &tagRepository{db: config.DB}
+}
+
+func (r *tagRepository) FindByID(id uint) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.First(&tag, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("标签不存在")
+		}
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	return &tag, nil
+}
+
+func (r *tagRepository) FindByKey(key string) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.Where("name_key = ?", key).First(&tag).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("标签不存在")
+		}
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	return &tag, nil
+}
+
+// Search 按名称模糊查找标签并统计图书数，关键词为空时返回全部标签，默认常用标签在前
+func (r *tagRepository) Search(query string, spec QuerySpec) (*Page[models.TagCount], error) {
+	db := r.db.Model(&models.Tag{}).Select("tags.*, " + tagBookCount + " AS book_count")
+	if query != "" {
+		db = db.Where("tags.name_key LIKE ?", "%"+models.TagKey(query)+"%")
+	}
+	page, err := paginate(db, spec, tagSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	return page, nil
+}
+
+func (r *tagRepository) Update(tag *models.Tag) error {
+	return r.db.Model(tag).Select("name", "name_key").Updates(tag).Error
+}
+
+// Merge 把 source 的图书转给 target，然后删除 source
+func (r *tagRepository) Merge(source, target *models.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// target 已有的图书不重复关联
+		var bookIDs []uint
+		if err := tx.Model(&models.BookTag{}).
+			Where("tag_id = ? AND book_id NOT IN (?)", source.ID,
+				tx.Model(&models.BookTag{}).Select("book_id").Where("tag_id = ?", target.ID)).
+			Pluck("book_id", &bookIDs).Error; err != nil {
+			return fmt.Errorf("查询标签图书失败: %w", err)
+		}
+		if len(bookIDs) > 0 {
+			links := make([]models.BookTag, len(bookIDs))
+			for i, bookID := range bookIDs {
+				links[i] = models.BookTag{BookID: bookID, TagID: target.ID}
+			}
+			if err := tx.CreateInBatches(&links, 500).Error; err != nil {
+				return fmt.Errorf("合并标签图书失败: %w", err)
+			}
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&models.BookTag{}).Error; err != nil {
+			return fmt.Errorf("删除标签图书失败: %w", err)
+		}
+		return tx.Delete(&models.Tag{}, source.ID).Error
+	})
+}
+
+// Delete 删除标签及其与图书的关联
+func (r *tagRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", id).Delete(&models.BookTag{}).Error; err != nil {
+			return fmt.Errorf("删除标签图书失败: %w", err)
+		}
+		return tx.Delete(&models.Tag{}, id).Error
+	})
+}
+
+// FindBooks 带有该标签的图书，排序方式与图书列表相同
+func (r *tagRepository) FindBooks(tagID uint, spec QuerySpec) (*Page[models.Book], error) {
+	query := r.db.Model(&models.Book{}).
+		Where("books.id IN (?)", r.db.Model(&models.BookTag{}).Select("book_id").Where("tag_id = ?", tagID))
+	page, err := paginate(query, spec, bookSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询标签图书失败: %w", err)
+	}
+	return page, nil
+}
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TokenRevocationRepository 访问令牌撤销存储
+// 默认使用进程内存实现；多实例部署时应切换为数据库实现
+type TokenRevocationRepository interface {
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	RevokeUserTokens(userID uint, before time.Time) error
+	UserRevokedBefore(userID uint) (time.Time, error)
+}
+
+// NewTokenRevocationRepository 根据配置选择撤销存储实现
+func NewTokenRevocationRepository() TokenRevocationRepository {
+	if config.AppConfig.TokenRevocationStore == "database" {
+		return NewDatabaseTokenRevocationRepository()
+	}
+	return NewMemoryTokenRevocationRepository()
+}
+
+// ---------------- 内存实现 ----------------
+
+type memoryTokenRevocationRepository struct {
+	mu            sync.RWMutex
+	tokens        map[string]time.Time
+	revokedBefore map[uint]time.Time
+}
+
+func NewMemoryTokenRevocationRepository() TokenRevocationRepository {
+	return &memoryTokenRevocationRepository{
+		tokens:        make(map[string]time.Time),
+		revokedBefore: make(map[uint]time.Time),
+	}
+}
+
+func (r *memoryTokenRevocationRepository) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	if jti == "" {
+		return fmt.Errorf("令牌ID不能为空")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 顺带清理已过期的记录，避免内存无限增长
+	now := time.Now()
+	for id, exp := range r.tokens {
+		if now.After(exp) {
+			delete(r.tokens, id)
+		}
+	}
+
+	r.tokens[jti] = expiresAt
+	return nil
+}
+
+func (r *memoryTokenRevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, revoked := r.tokens[jti]
+	return revoked, nil
+}
+
+func (r *memoryTokenRevocationRepository) RevokeUserTokens(userID uint, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revokedBefore[userID] = before
+	return nil
+}
+
+func (r *memoryTokenRevocationRepository) UserRevokedBefore(userID uint) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.revokedBefore[userID], nil
+}
+
+// ---------------- 数据库实现 ----------------
+
+type databaseTokenRevocationRepository struct {
+	db *gorm.DB
+}
+
+func NewDatabaseTokenRevocationRepository() TokenRevocationRepository {
+	return &databaseTokenRevocationRepository{db: config.DB}
+}
+
+func (r *databaseTokenRevocationRepository) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	if jti == "" {
+		return fmt.Errorf("令牌ID不能为空")
+	}
+
+	if err := r.db.Where("expires_at < ?", time.Now()).
+		Delete(&models.RevokedToken{}).Error; err != nil {
+		return fmt.Errorf("清理过期撤销记录失败: %w", err)
+	}
+
+	record := &models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record).Error; err != nil {
+		return fmt.Errorf("撤销令牌失败: %w", err)
+	}
+	return nil
+}
+
+func (r *databaseTokenRevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.RevokedToken{}).
+		Where("jti = ?", jti).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询令牌撤销状态失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *databaseTokenRevocationRepository) RevokeUserTokens(userID uint, before time.Time) error {
+	record := &models.UserTokenRevocation{UserID: userID, RevokedBefore: before}
+	if err := r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(record).Error; err != nil {
+		return fmt.Errorf("撤销用户令牌失败: %w", err)
+	}
+	return nil
+}
+
+func (r *databaseTokenRevocationRepository) UserRevokedBefore(userID uint) (time.Time, error) {
+	var record models.UserTokenRevocation
+	err := r.db.Where("user_id = ?", userID).First(&record).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("查询用户令牌撤销状态失败: %w", err)
+	}
+	return record.RevokedBefore, nil
+}
@@ -3,11 +3,18 @@ package repositories
 import (
 	"book-management-system/config"
 	"book-management-system/models"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrLastAdmin 停用、删除或降级后系统中将没有可用的管理员
+var ErrLastAdmin = errors.New("不能移除最后一个管理员")
+
 type UserRepository interface {
 	Create(user *models.User) error
 	FindByID(id uint) (*models.User, error)
@@ -15,11 +22,54 @@ type UserRepository interface {
 	FindByEmail(email string) (*models.User, error)
 	Update(user *models.User) error
 	Delete(id uint) error
-	FindAll() ([]models.User, error)
+	Find(filter UserFilter, spec QuerySpec) (*Page[models.User], error)
 	Count() (int64, error)
 	CountByRole(role models.UserRole) (int64, error)
 	UpdateUsername(userID uint, newUsername string) error
-	UpdatePassword(userID uint, newPassword string) error
+	UpdatePassword(userID uint, passwordHash string) error
+	UpdateRole(userID uint, role models.UserRole) error
+	UpdateStatus(userID uint, status models.UserStatus) error
+	CountActiveByRole(role models.UserRole) (int64, error)
+	FindDeleted(filter UserFilter, spec QuerySpec) (*Page[models.User], error)
+	FindRole(id uint) (models.UserRole, error)
+	FindDeletedByID(id uint) (*models.User, error)
+	Restore(id uint) (*models.User, error)
+	MarkEmailVerified(userID uint) error
+	UpdateTOTP(userID uint, secret string, enabled bool) error
+	AdvanceTOTPStep(userID uint, step int64) (bool, error)
+	FindByOIDCSubject(subject string) (*models.User, error)
+	LinkOIDCSubject(userID uint, subject string) error
+}
+
+// UserFilter 用户列表的过滤条件，零值字段表示不过滤
+type UserFilter struct {
+	Query  string // 用户名或邮箱包含关键词
+	Role   models.UserRole
+	Status models.UserStatus
+}
+
+// userSorts 用户列表允许的排序字段
+var userSorts = sortOptions[models.User]{
+	fields: map[string]sortField[models.User]{
+		"created_at": {column: "users.created_at", desc: true, value: func(u *models.User) any { return u.CreatedAt }},
+		"username":   {column: "users.username", value: func(u *models.User) any { return u.Username }},
+		"id":         {column: "users.id", value: func(u *models.User) any { return u.ID }},
+	},
+	defaultSort: "created_at",
+	idColumn:    "users.id",
+	id:          func(u *models.User) uint { return u.ID },
+}
+
+// deletedUserSorts 已删除用户列表允许的排序字段，默认最近删除的在前
+var deletedUserSorts = sortOptions[models.User]{
+	fields: map[string]sortField[models.User]{
+		"deleted_at": {column: "users.deleted_at", desc: true, value: func(u *models.User) any { return u.DeletedAt.Time }},
+		"username":   userSorts.fields["username"],
+		"id":         userSorts.fields["id"],
+	},
+	defaultSort: "deleted_at",
+	idColumn:    "users.id",
+	id:          func(u *models.User) uint { return u.ID },
 }
 
 type userRepository struct {
@@ -134,36 +184,40 @@ func (r *userRepository) Update(user *models.User) error {
 }
 
 func (r *userRepository) Delete(id uint) error {
-	var user models.User
-	if err := r.db.First(&user, id).Error; err != nil {
-		return fmt.Errorf("用户不存在")
-	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return fmt.Errorf("用户不存在")
+		}
 
-	var borrowedCount int64
-	if err := r.db.Model(&models.BorrowRecord{}).
-		Where("user_id = ? AND returned_at IS NULL", id).
-		Count(&borrowedCount).Error; err != nil {
-		return fmt.Errorf("检查借阅记录失败: %w", err)
-	}
+		var borrowedCount int64
+		if err := tx.Model(&models.BorrowRecord{}).
+			Where("user_id = ? AND returned_at IS NULL", id).
+			Count(&borrowedCount).Error; err != nil {
+			return fmt.Errorf("检查借阅记录失败: %w", err)
+		}
 
-	if borrowedCount > 0 {
-		return fmt.Errorf("用户有未归还的图书，无法删除")
-	}
+		if borrowedCount > 0 {
+			return fmt.Errorf("用户有未归还的图书，无法删除")
+		}
 
-	return r.db.Delete(&models.User{}, id).Error
+		if err := ensureAdminRemains(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, id).Error
+	})
 }
 
-func (r *userRepository) FindAll() ([]models.User, error) {
-	var users []models.User
+// Find 按过滤条件分页查询用户，默认按注册时间倒序
+func (r *userRepository) Find(filter UserFilter, spec QuerySpec) (*Page[models.User], error) {
+	query := filterUsers(r.db.Model(&models.User{}), filter).
+		Select("id, username, email, role, status, created_at, updated_at")
 
-	if err := r.db.Model(&models.User{}).
-		Select("id, username, email, role, created_at, updated_at").
-		Order("created_at DESC").
-		Find(&users).Error; err != nil {
+	page, err := paginate(query, spec, userSorts)
+	if err != nil {
 		return nil, fmt.Errorf("查询用户列表失败: %w", err)
 	}
-
-	return users, nil
+	return page, nil
 }
 
 func (r *userRepository) Count() (int64, error) {
@@ -211,8 +265,9 @@ func (r *userRepository) UpdateUsername(userID uint, newUsername string) error {
 	return r.db.Save(&user).Error
 }
 
-func (r *userRepository) UpdatePassword(userID uint, newPassword string) error {
-	if newPassword == "" {
+// UpdatePassword 更新密码哈希，调用方负责在传入前完成哈希
+func (r *userRepository) UpdatePassword(userID uint, passwordHash string) error {
+	if passwordHash == "" {
 		return fmt.Errorf("新密码不能为空")
 	}
 
@@ -222,7 +277,199 @@ func (r *userRepository) UpdatePassword(userID uint, newPassword string) error {
 		return fmt.Errorf("用户不存在")
 	}
 
-	// 更新密码
-	user.Password = newPassword
-	return r.db.Save(&user).Error
+	// 只更新密码列，避免覆盖并发修改的其他字段
+	return r.db.Model(&user).Update("password", passwordHash).Error
+}
+
+func (r *userRepository) UpdateRole(userID uint, role models.UserRole) error {
+	if role == "" {
+		return fmt.Errorf("角色不能为空")
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("用户不存在")
+		}
+
+		if role != models.RoleAdmin {
+			if err := ensureAdminRemains(tx, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Update("role", role).Error
+	})
+}
+
+func (r *userRepository) UpdateStatus(userID uint, status models.UserStatus) error {
+	if status == "" {
+		return fmt.Errorf("状态不能为空")
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("用户不存在")
+		}
+
+		if status != models.UserStatusActive {
+			if err := ensureAdminRemains(tx, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Update("status", status).Error
+	})
+}
+
+// ensureAdminRemains 在修改用户的事务中锁定全部可用管理员的行，确认移除 userID 之后至少还剩一个；
+// 并发的停用、删除或降级在锁上排队，后执行的一方读到前者提交后的结果，不会同时通过检查
+func ensureAdminRemains(tx *gorm.DB, userID uint) error {
+	var adminIDs []uint
+	if err := tx.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND status = ?", models.RoleAdmin, models.UserStatusActive).
+		Pluck("id", &adminIDs).Error; err != nil {
+		return fmt.Errorf("检查管理员数量失败: %w", err)
+	}
+	if slices.Contains(adminIDs, userID) && len(adminIDs) <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// CountActiveByRole 统计指定角色中未停用的用户数量
+func (r *userRepository) CountActiveByRole(role models.UserRole) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.User{}).
+		Where("role = ? AND status = ?", role, models.UserStatusActive).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("按角色统计失败: %w", err)
+	}
+	return count, nil
+}
+
+// FindDeleted 分页查询已软删除的用户，默认按删除时间倒序
+func (r *userRepository) FindDeleted(filter UserFilter, spec QuerySpec) (*Page[models.User], error) {
+	query := filterUsers(r.db.Unscoped().Model(&models.User{}), filter).
+		Select("id, username, email, role, status, created_at, updated_at, deleted_at").
+		Where("deleted_at IS NOT NULL")
+
+	page, err := paginate(query, spec, deletedUserSorts)
+	if err != nil {
+		return nil, fmt.Errorf("查询已删除用户失败: %w", err)
+	}
+	return page, nil
+}
+
+func filterUsers(query *gorm.DB, filter UserFilter) *gorm.DB {
+	if filter.Query != "" {
+		pattern := "%" + filter.Query + "%"
+		query = query.Where("users.username LIKE ? OR users.email LIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("users.role = ?", filter.Role)
+	}
+	if filter.Status != "" {
+		query = query.Where("users.status = ?", filter.Status)
+	}
+	return query
+}
+
+// FindRole 查询用户的角色，包括已软删除的用户
+func (r *userRepository) FindRole(id uint) (models.UserRole, error) {
+	var user models.User
+	if err := r.db.Unscoped().Select("id", "role").First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("用户不存在")
+		}
+		return "", fmt.Errorf("查询用户失败: %w", err)
+	}
+	return user.Role, nil
+}
+
+// FindDeletedByID 查询已软删除的用户，未删除或不存在的用户都返回错误
+func (r *userRepository) FindDeletedByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("已删除的用户不存在")
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return &user, nil
+}
+
+// Restore 恢复已软删除的用户
+func (r *userRepository) Restore(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&user).Error; err != nil {
+		return nil, fmt.Errorf("已删除的用户不存在")
+	}
+
+	if err := r.db.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("恢复用户失败: %w", err)
+	}
+
+	return r.FindByID(id)
+}
+
+// MarkEmailVerified 记录邮箱验证时间，并激活等待验证的账户
+func (r *userRepository) MarkEmailVerified(userID uint) error {
+	var user models.User
+	if err := r.db.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("用户不存在")
+	}
+
+	updates := map[string]any{"email_verified_at": time.Now()}
+	if user.Status == models.UserStatusPending {
+		updates["status"] = models.UserStatusActive
+	}
+
+	return r.db.Model(&user).Updates(updates).Error
+}
+
+// UpdateTOTP 保存两步验证密钥和启用状态，同时重置已使用的时间步
+func (r *userRepository) UpdateTOTP(userID uint, secret string, enabled bool) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":    secret,
+		"totp_enabled":   enabled,
+		"totp_last_step": 0,
+	}).Error
+}
+
+// AdvanceTOTPStep 记录已使用的验证码时间步，只接受比上一次更新的时间步，防止验证码被重放
+func (r *userRepository) AdvanceTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *userRepository) FindByOIDCSubject(subject string) (*models.User, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("身份标识不能为空")
+	}
+
+	var user models.User
+	err := r.db.Where("oidc_subject = ?", subject).First(&user).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("用户不存在")
+		}
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	return &user, nil
+}
+
+// LinkOIDCSubject 将身份提供方的用户标识关联到本地账户
+func (r *userRepository) LinkOIDCSubject(userID uint, subject string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("oidc_subject", subject).Error
 }
@@ -1,10 +1,14 @@
 package routers
 
 import (
+	"book-management-system/config"
 	"book-management-system/controllers"
 	"book-management-system/middlewares"
+	"book-management-system/models"
 	"book-management-system/repositories"
 	"book-management-system/services"
+	"book-management-system/utils"
+	"log"
 
 	_ "book-management-system/docs"
 
@@ -13,79 +17,268 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRouter() *gin.Engine {
+func SetupRouter(tokenService utils.TokenService) *gin.Engine {
 	router := gin.Default()
-	router.Use(middlewares.CORSMiddleware())
+	if len(config.AppConfig.TrustedProxies) > 0 {
+		if err := router.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+			log.Fatalf("TRUSTED_PROXIES 配置无效: %v", err)
+		}
+	}
+	router.Use(middlewares.RequestID())
+	router.Use(middlewares.SecurityHeaders(config.AppConfig.HSTSMaxAge, config.AppConfig.HSTSIncludeSubdomains))
+	router.Use(middlewares.MaxBodySize(config.AppConfig.MaxRequestBodyBytes))
+	// 公开的图书查询可以对任意源开放，管理接口只允许配置的管理端源访问
+	// /api/books 下的借还接口需要认证，按最长前缀匹配回到默认策略
+	router.Use(middlewares.CORS(config.AppConfig.CORS,
+		middlewares.CORSRule{PathPrefix: "/api/books", Policy: config.AppConfig.CatalogCORS},
+		middlewares.CORSRule{PathPrefix: "/api/books/borrow", Policy: config.AppConfig.CORS},
+		middlewares.CORSRule{PathPrefix: "/api/books/return", Policy: config.AppConfig.CORS},
+		middlewares.CORSRule{PathPrefix: "/api/books/my-borrowed", Policy: config.AppConfig.CORS},
+		middlewares.CORSRule{PathPrefix: "/api/books/my-records", Policy: config.AppConfig.CORS},
+		middlewares.CORSRule{PathPrefix: "/api/authors", Policy: config.AppConfig.CatalogCORS},
+		middlewares.CORSRule{PathPrefix: "/api/categories", Policy: config.AppConfig.CatalogCORS},
+		middlewares.CORSRule{PathPrefix: "/api/tags", Policy: config.AppConfig.CatalogCORS},
+		middlewares.CORSRule{PathPrefix: "/api/admin", Policy: config.AppConfig.AdminCORS},
+	))
 
-	// 添加Swagger文档路由
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// 添加Swagger文档路由，生产环境默认关闭
+	if config.AppConfig.SwaggerEnabled {
+		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	}
 
 	// 初始化仓库和服务
 	userRepo := repositories.NewUserRepository()
 	bookRepo := repositories.NewCombinedBookRepository()
+	copyRepo := repositories.NewBookCopyRepository()
+	authorRepo := repositories.NewAuthorRepository()
+	categoryRepo := repositories.NewCategoryRepository()
+	tagRepo := repositories.NewTagRepository()
+	refreshTokenRepo := repositories.NewRefreshTokenRepository()
+	sessionRepo := repositories.NewSessionRepository()
+	tokenRevocationRepo := repositories.NewTokenRevocationRepository()
+	roleRepo := repositories.NewRoleRepository()
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository()
+	apiKeyRepo := repositories.NewAPIKeyRepository()
+	passwordResetRepo := repositories.NewPasswordResetRepository()
+	loginAttemptRepo := repositories.NewLoginAttemptRepository()
+	auditRepo := repositories.NewAuditRepository()
+
+	auditService := services.NewAuditService(auditRepo)
+	refreshTokenService := services.NewRefreshTokenService(refreshTokenRepo, sessionRepo, config.AppConfig.RefreshTokenExpire)
+	sessionService := services.NewSessionService(sessionRepo, refreshTokenRepo, auditService)
+	tokenRevocationService := services.NewTokenRevocationService(tokenRevocationRepo, refreshTokenService)
+	passwordHasher := services.NewPasswordHasher()
+	mailer := services.NewMailer()
+	loginGuard := services.NewLoginGuard(loginAttemptRepo)
+	emailVerificationService := services.NewEmailVerificationService(userRepo, mailer, loginGuard)
+	roleService := services.NewRoleService(roleRepo, userRepo, tokenRevocationService, auditService)
+	twoFactorService := services.NewTwoFactorService(userRepo, recoveryCodeRepo, passwordHasher, roleService, tokenRevocationService, loginGuard, auditService)
+	credentialVerifier, err := services.NewCredentialVerifier(userRepo, roleRepo, passwordHasher, auditService)
+	if err != nil {
+		log.Fatalf("初始化认证后端失败: %v", err)
+	}
+	authService := services.NewAuthService(userRepo, tokenService, passwordHasher, refreshTokenService, tokenRevocationService, emailVerificationService, loginGuard, twoFactorService, credentialVerifier, sessionService, auditService)
+	passwordResetService := services.NewPasswordResetService(userRepo, passwordResetRepo, passwordHasher, tokenRevocationService, mailer, loginGuard, auditService)
+	authorService := services.NewAuthorService(authorRepo, auditService)
+	categoryService := services.NewCategoryService(categoryRepo, auditService)
+	tagService := services.NewTagService(tagRepo, auditService)
+	bookService := services.NewBookService(bookRepo, copyRepo, authorService, categoryService, tagService, auditService)
+	copyService := services.NewBookCopyService(copyRepo, bookRepo, auditService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo, roleService, auditService)
+	oidcService := services.NewOIDCService(userRepo, roleRepo, passwordHasher, authService, auditService)
+	rateLimiter := services.NewRateLimiter(repositories.NewRateLimitStore())
+	userService := services.NewUserService(userRepo, roleRepo, passwordResetRepo, roleService, passwordHasher, tokenRevocationService, loginGuard, auditService)
 
-	authService := services.NewAuthService(userRepo)
-	bookService := services.NewBookService(bookRepo)
+	if err := roleService.EnsureDefaultRoles(); err != nil {
+		log.Printf("初始化内置角色失败: %v", err)
+	}
 
-	authController := controllers.NewAuthController(authService)
+	authController := controllers.NewAuthController(authService, emailVerificationService, passwordResetService)
 	bookController := controllers.NewBookController(bookService)
+	copyController := controllers.NewBookCopyController(copyService)
+	authorController := controllers.NewAuthorController(authorService)
+	categoryController := controllers.NewCategoryController(categoryService)
+	tagController := controllers.NewTagController(tagService)
+	roleController := controllers.NewRoleController(roleService)
+	userController := controllers.NewUserController(userService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
+	wellKnownController := controllers.NewWellKnownController(tokenService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	oidcController := controllers.NewOIDCController(oidcService)
+	sessionController := controllers.NewSessionController(sessionService)
+	auditController := controllers.NewAuditController(auditService)
+
+	// 按权限校验的中间件
+	can := func(permissions ...models.Permission) gin.HandlerFunc {
+		return middlewares.RequirePermission(roleService, permissions...)
+	}
+	// 停用、删除、重置他人账户时，还要求能够授予目标用户的角色
+	manageUser := middlewares.RequireRoleAuthority(roleService, userService)
+
+	// 令牌校验公钥
+	router.GET("/.well-known/jwks.json", wellKnownController.JWKS)
 
 	// 公共路由
-	api := router.Group("/api")
+	api := router.Group("/api", middlewares.RequireJSON())
 	{
 		// 认证路由
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", authController.Register)
-			auth.POST("/login", authController.Login)
-			auth.POST("/logout", authController.Logout)
+			auth.POST("/register", middlewares.ThrottleByIP(loginGuard, "register"), authController.Register)
+			auth.POST("/login", middlewares.ThrottleByIP(loginGuard, "login"), authController.Login)
+			auth.POST("/refresh", authController.Refresh)
+			auth.POST("/2fa/verify", middlewares.ThrottleByIP(loginGuard, "login"), authController.VerifyTwoFactor)
+			auth.GET("/verify-email", authController.VerifyEmail)
+			auth.POST("/verify-email/resend", middlewares.ThrottleByIP(loginGuard, "mail"), authController.ResendVerification)
+			auth.POST("/password/forgot", middlewares.ThrottleByIP(loginGuard, "mail"), authController.ForgotPassword)
+			auth.POST("/password/reset", authController.ResetPassword)
+
+			// 单点登录
+			auth.GET("/oidc/login", oidcController.Login)
+			auth.GET("/oidc/callback", middlewares.ThrottleByIP(loginGuard, "login"), oidcController.Callback)
 		}
 
-		// 公开的图书查询
-		books := api.Group("/books")
+		// 公开的图书查询，按 IP 限流，搜索单独再限一次
+		books := api.Group("/books", middlewares.RateLimit(rateLimiter, "catalog", config.AppConfig.RateLimitCatalog))
 		{
 			books.GET("", bookController.GetAllBooks)
-			books.GET("/search", bookController.SearchBooks)
+			books.GET("/search", middlewares.RateLimit(rateLimiter, "search", config.AppConfig.RateLimitSearch), bookController.SearchBooks)
 			books.GET("/:id", bookController.GetBookByID)
 			books.GET("/:id/availability", bookController.CheckAvailability)
 		}
+
+		// 公开的作者查询，与图书查询共用限额
+		authors := api.Group("/authors", middlewares.RateLimit(rateLimiter, "catalog", config.AppConfig.RateLimitCatalog))
+		{
+			authors.GET("", authorController.GetAuthors)
+			authors.GET("/:id", authorController.GetAuthor)
+			authors.GET("/:id/books", authorController.GetAuthorBooks)
+		}
+
+		// 公开的分类和标签浏览，与图书查询共用限额
+		categories := api.Group("/categories", middlewares.RateLimit(rateLimiter, "catalog", config.AppConfig.RateLimitCatalog))
+		{
+			categories.GET("", categoryController.GetCategories)
+			categories.GET("/:id", categoryController.GetCategory)
+			categories.GET("/:id/books", categoryController.GetCategoryBooks)
+		}
+		tags := api.Group("/tags", middlewares.RateLimit(rateLimiter, "catalog", config.AppConfig.RateLimitCatalog))
+		{
+			tags.GET("", tagController.GetTags)
+			tags.GET("/:id/books", tagController.GetTagBooks)
+		}
 	}
 
 	// 需要认证的路由
+	// 先按 IP 限流再校验凭据，携带无效令牌或密钥的请求同样计数，不会绕过限制直接查询数据库；
+	// 按 API 密钥或用户的计数需要认证结果，放在认证之后
 	authenticated := api.Group("")
-	authenticated.Use(middlewares.AuthMiddleware())
+	authenticated.Use(middlewares.RateLimit(rateLimiter, "auth", config.AppConfig.RateLimitAuth),
+		middlewares.AuthMiddleware(authService, apiKeyService),
+		middlewares.RateLimit(rateLimiter, "api", config.AppConfig.RateLimitAPI))
 	{
+		// 退出登录
+		session := authenticated.Group("/auth", middlewares.RequireSession())
+		{
+			session.POST("/logout", authController.Logout)
+			session.POST("/logout-all", authController.LogoutAll)
+		}
+
 		// 用户相关
 		user := authenticated.Group("/users")
 		{
-			user.GET("/profile", authController.GetProfile)      // 获取用户资料
-			user.PUT("/username", authController.ChangeUsername) // 新增：更改用户名
-			user.PUT("/password", authController.ChangePassword)
+			user.GET("/profile", middlewares.RequireScope(models.PermissionProfileRead), authController.GetProfile) // 获取用户资料
+
+			// 账户安全相关的操作只能使用访问令牌
+			account := user.Group("", middlewares.RequireSession())
+			{
+				account.PUT("/username", authController.ChangeUsername) // 新增：更改用户名
+				account.PUT("/password", authController.ChangePassword)
+
+				// 两步验证
+				account.GET("/2fa", twoFactorController.GetStatus)
+				account.POST("/2fa/enroll", twoFactorController.Enroll)
+				account.POST("/2fa/confirm", twoFactorController.Confirm)
+				account.POST("/2fa/disable", twoFactorController.Disable)
+				account.POST("/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+
+				// API密钥
+				account.GET("/api-keys", apiKeyController.GetAPIKeys)
+				account.POST("/api-keys", apiKeyController.CreateAPIKey)
+				account.DELETE("/api-keys/:id", apiKeyController.RevokeAPIKey)
+
+				// 登录会话
+				account.GET("/sessions", sessionController.GetMySessions)
+				account.DELETE("/sessions/:id", sessionController.RevokeMySession)
+			}
 		}
 
 		// 书籍借还（管理员和普通用户都可以）
 		books := authenticated.Group("/books")
 		{
-			books.POST("/borrow", bookController.BorrowBook)
-			books.POST("/return", bookController.ReturnBook)
-			books.GET("/my-borrowed", bookController.GetMyBorrowedBooks)
-			books.GET("/my-records", bookController.GetMyBorrowRecords)
+			books.POST("/borrow", can(models.PermissionBorrowSelf), bookController.BorrowBook)
+			books.POST("/return", can(models.PermissionBorrowSelf), bookController.ReturnBook)
+			books.GET("/my-borrowed", can(models.PermissionBorrowSelf), bookController.GetMyBorrowedBooks)
+			books.GET("/my-records", can(models.PermissionBorrowSelf), bookController.GetMyBorrowRecords)
 		}
 
-		// 管理员专用路由
+		// 管理路由，按权限逐个授权
 		admin := authenticated.Group("/admin")
-		admin.Use(middlewares.AdminOnly())
 		{
 			// 图书管理
-			admin.POST("/books", bookController.CreateBook)
-			admin.PUT("/books/:id", bookController.UpdateBook)
-			admin.DELETE("/books/:id", bookController.DeleteBook)
+			admin.POST("/books", can(models.PermissionBookCreate), bookController.CreateBook)
+			admin.PUT("/books/:id", can(models.PermissionBookUpdate), bookController.UpdateBook)
+			admin.DELETE("/books/:id", can(models.PermissionBookDelete), bookController.DeleteBook)
+
+			// 作者、分类和标签维护，与图书编目使用相同的权限
+			admin.POST("/authors", can(models.PermissionBookCreate), authorController.CreateAuthor)
+			admin.PUT("/authors/:id", can(models.PermissionBookUpdate), authorController.UpdateAuthor)
+			admin.DELETE("/authors/:id", can(models.PermissionBookDelete), authorController.DeleteAuthor)
+			admin.POST("/categories", can(models.PermissionBookCreate), categoryController.CreateCategory)
+			admin.PUT("/categories/:id", can(models.PermissionBookUpdate), categoryController.UpdateCategory)
+			admin.POST("/categories/:id/move", can(models.PermissionBookUpdate), categoryController.MoveCategory)
+			admin.POST("/categories/:id/merge", can(models.PermissionBookUpdate), categoryController.MergeCategory)
+			admin.DELETE("/categories/:id", can(models.PermissionBookDelete), categoryController.DeleteCategory)
+			admin.PUT("/tags/:id", can(models.PermissionBookUpdate), tagController.RenameTag)
+			admin.DELETE("/tags/:id", can(models.PermissionBookDelete), tagController.DeleteTag)
+
+			// 馆藏副本
+			admin.GET("/books/:id/copies", can(models.PermissionCopyManage), copyController.GetCopies)
+			admin.POST("/books/:id/copies", can(models.PermissionCopyManage), copyController.AddCopies)
+			admin.GET("/copies/:barcode", can(models.PermissionCopyManage), copyController.GetCopy)
+			admin.PUT("/copies/:barcode", can(models.PermissionCopyManage), copyController.UpdateCopy)
+			admin.DELETE("/copies/:barcode", can(models.PermissionCopyManage), copyController.DeleteCopy)
 
 			// 借阅记录管理
-			admin.GET("/borrow-records", bookController.GetAllBorrowRecords)
+			admin.GET("/borrow-records", can(models.PermissionBorrowReadAll), bookController.GetAllBorrowRecords)
+			admin.POST("/circulation/checkout", can(models.PermissionBorrowCheckoutOthers), bookController.CheckoutForUser)
+			admin.POST("/circulation/checkin", can(models.PermissionBorrowCheckoutOthers), bookController.CheckinForUser)
 
 			//用户管理
-			admin.GET("/users", authController.GetAllUsers)
+			admin.GET("/users", can(models.PermissionUserRead), authController.GetAllUsers)
+			admin.GET("/users/deleted", can(models.PermissionUserManage), userController.GetDeletedUsers)
+			admin.POST("/users", can(models.PermissionUserManage), userController.CreateUser)
+			admin.POST("/users/:id/suspend", can(models.PermissionUserManage), manageUser, userController.SuspendUser)
+			admin.POST("/users/:id/activate", can(models.PermissionUserManage), manageUser, userController.ActivateUser)
+			admin.DELETE("/users/:id", can(models.PermissionUserManage), manageUser, userController.DeleteUser)
+			admin.POST("/users/:id/restore", can(models.PermissionUserManage), manageUser, userController.RestoreUser)
+			admin.POST("/users/:id/unlock", can(models.PermissionUserManage), manageUser, userController.UnlockUser)
+			admin.POST("/users/:id/password/reset", can(models.PermissionUserManage), manageUser, userController.ResetUserPassword)
+			admin.POST("/users/:id/2fa/reset", can(models.PermissionUserManage), manageUser, twoFactorController.ResetUserTwoFactor)
+			admin.POST("/users/:id/revoke-sessions", can(models.PermissionUserManage), manageUser, authController.RevokeUserSessions)
+			admin.GET("/users/:id/sessions", can(models.PermissionUserRead), sessionController.GetUserSessions)
+			admin.DELETE("/users/:id/sessions/:sessionId", can(models.PermissionUserManage), manageUser, sessionController.RevokeUserSession)
+			admin.PUT("/users/:id/role", can(models.PermissionRoleManage), roleController.AssignRole)
+
+			// 角色管理
+			admin.GET("/roles", can(models.PermissionRoleManage), roleController.GetAllRoles)
+			admin.GET("/permissions", can(models.PermissionRoleManage), roleController.GetPermissions)
+			admin.POST("/roles", can(models.PermissionRoleManage), roleController.CreateRole)
+			admin.PUT("/roles/:name", can(models.PermissionRoleManage), roleController.UpdateRole)
+			admin.DELETE("/roles/:name", can(models.PermissionRoleManage), roleController.DeleteRole)
+
+			// 审计日志
+			admin.GET("/audit", can(models.PermissionAuditRead), auditController.GetAuditEvents)
 		}
 	}
 
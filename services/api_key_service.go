@@ -0,0 +1,171 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix 所有 API 密钥的固定前缀，便于在日志和代码仓库中识别泄露的密钥
+const apiKeyPrefix = "bms_"
+
+// apiKeyTouchInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const apiKeyTouchInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("API密钥无效、已过期或已撤销")
+
+// APIKeyService 管理用户的个人 API 密钥
+// 密钥格式为 bms_<标识>_<密文>，标识部分作为 Prefix 明文保存，用于查找和展示
+type APIKeyService interface {
+	Create(actor Actor, name string, scopes []models.Permission, expiresAt *time.Time) (*models.APIKey, string, error)
+	List(userID uint, spec repositories.QuerySpec) (*repositories.Page[models.APIKey], error)
+	Revoke(actor Actor, keyID uint) error
+	Authenticate(rawKey string) (*models.User, *models.APIKey, error)
+}
+
+type apiKeyService struct {
+	keyRepo     repositories.APIKeyRepository
+	userRepo    repositories.UserRepository
+	roleService RoleService
+	audit       AuditService
+}
+
+func NewAPIKeyService(keyRepo repositories.APIKeyRepository, userRepo repositories.UserRepository, roleService RoleService, audit AuditService) APIKeyService {
+	return &apiKeyService{
+		keyRepo:     keyRepo,
+		userRepo:    userRepo,
+		roleService: roleService,
+		audit:       audit,
+	}
+}
+
+// Create 创建密钥，返回的明文密钥只出现这一次
+// 作用范围必须是用户当前角色拥有的权限
+func (s *apiKeyService) Create(actor Actor, name string, scopes []models.Permission, expiresAt *time.Time) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("密钥名称不能为空")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("至少需要指定一个权限范围")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", errors.New("过期时间必须晚于当前时间")
+	}
+
+	userID := actor.UserID
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, scope := range scopes {
+		if !models.IsValidPermission(scope) {
+			return nil, "", fmt.Errorf("未知权限: %s", scope)
+		}
+	}
+	allowed, err := s.roleService.HasPermissions(user.Role, scopes...)
+	if err != nil {
+		return nil, "", err
+	}
+	if !allowed {
+		return nil, "", errors.New("密钥的权限范围不能超出当前角色的权限")
+	}
+
+	identifier, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	// base64url 字符集包含下划线，替换掉以免与分隔符混淆
+	prefix := apiKeyPrefix + strings.ReplaceAll(identifier, "_", "-")
+	rawKey := prefix + "_" + secret
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.keyRepo.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	// 密钥的ID在写入后才确定，审计记录无法写入时撤销密钥，明文不会返回给调用方
+	if err := s.audit.RecordSecurity(actor, models.AuditAPIKeyCreate, models.AuditTargetAPIKey, key.ID, nil, key); err != nil {
+		if revokeErr := s.keyRepo.Revoke(key.ID, userID); revokeErr != nil {
+			log.Printf("撤销未能审计的API密钥 %d 失败: %v", key.ID, revokeErr)
+		}
+		return nil, "", err
+	}
+	return key, rawKey, nil
+}
+
+func (s *apiKeyService) List(userID uint, spec repositories.QuerySpec) (*repositories.Page[models.APIKey], error) {
+	return s.keyRepo.FindByUser(userID, spec)
+}
+
+// Revoke 撤销当前用户自己的密钥
+func (s *apiKeyService) Revoke(actor Actor, keyID uint) error {
+	key, err := s.keyRepo.FindByID(keyID)
+	if err != nil || key.UserID != actor.UserID || key.RevokedAt != nil {
+		return errors.New("API密钥不存在或已撤销")
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditAPIKeyRevoke, models.AuditTargetAPIKey, key.ID, key, nil); err != nil {
+		return err
+	}
+	return s.keyRepo.Revoke(key.ID, actor.UserID)
+}
+
+// Authenticate 校验密钥并返回所属用户，同时记录最近使用时间
+func (s *apiKeyService) Authenticate(rawKey string) (*models.User, *models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	// 标识部分不含下划线，其后的第一个下划线即为分隔符；密文部分可能包含下划线
+	separator := strings.Index(rawKey[len(apiKeyPrefix):], "_")
+	if separator <= 0 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.keyRepo.FindByPrefix(rawKey[:len(apiKeyPrefix)+separator])
+	if err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(rawKey))) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.FindByID(key.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err := checkLoginAllowed(user); err != nil {
+		return nil, nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.keyRepo.TouchLastUsed(key.ID, now); err != nil {
+			log.Printf("更新API密钥 %d 使用时间失败: %v", key.ID, err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return user, key, nil
+}
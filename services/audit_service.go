@@ -0,0 +1,161 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// Actor 发起操作的主体，由控制器从请求上下文中取得
+type Actor struct {
+	UserID    uint
+	Username  string
+	Role      models.UserRole
+	APIKeyID  uint
+	Scopes    []models.Permission // 使用 API 密钥时为密钥的作用范围，使用访问令牌时为 nil
+	IP        string
+	RequestID string
+
+	system bool
+}
+
+// SystemActor 不经过 HTTP 请求的操作主体，如命令行工具，不受角色权限限制
+func SystemActor(name string) Actor {
+	return Actor{Username: name, system: true}
+}
+
+// ErrAuditUnavailable 安全相关操作的审计记录无法写入，操作未执行
+var ErrAuditUnavailable = errors.New("审计日志暂时无法写入，操作未执行，请稍后重试")
+
+// 审计差异中忽略的字段，这些字段随每次写入变化，不反映操作内容
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// AuditService 记录管理和流通操作的审计日志
+type AuditService interface {
+	// Record 记录一次操作，before/after 为操作前后的对象（结构体或 map），新建时 before 为 nil，删除时 after 为 nil
+	Record(actor Actor, action models.AuditAction, targetType string, targetID any, before, after any)
+	// RecordSecurity 记录账户、角色、凭据和会话等安全相关操作，写入失败时返回错误
+	RecordSecurity(actor Actor, action models.AuditAction, targetType string, targetID any, before, after any) error
+	Find(filter repositories.AuditFilter, spec repositories.QuerySpec) (*repositories.Page[models.AuditEvent], error)
+	Export(filter repositories.AuditFilter, fn func(events []models.AuditEvent) error) error
+}
+
+type auditService struct {
+	auditRepo repositories.AuditRepository
+}
+
+func NewAuditService(auditRepo repositories.AuditRepository) AuditService {
+	return &auditService{auditRepo: auditRepo}
+}
+
+// Record 在业务操作完成后写入，写入失败只记录日志，不回滚已完成的操作
+func (s *auditService) Record(actor Actor, action models.AuditAction, targetType string, targetID any, before, after any) {
+	event := newAuditEvent(actor, action, targetType, targetID, before, after)
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("写入审计日志失败 (%s %s:%s): %v", action, targetType, event.TargetID, err)
+	}
+}
+
+// RecordSecurity 在校验通过之后、写入变更之前调用，写入失败时调用方应放弃操作，
+// 保证不会出现没有审计记录的安全变更；变更本身写入失败时会留下一条未生效的记录，安全审计宁多勿漏
+func (s *auditService) RecordSecurity(actor Actor, action models.AuditAction, targetType string, targetID any, before, after any) error {
+	event := newAuditEvent(actor, action, targetType, targetID, before, after)
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("写入审计日志失败，已放弃操作 (%s %s:%s): %v", action, targetType, event.TargetID, err)
+		return ErrAuditUnavailable
+	}
+	return nil
+}
+
+func newAuditEvent(actor Actor, action models.AuditAction, targetType string, targetID any, before, after any) *models.AuditEvent {
+	event := &models.AuditEvent{
+		ActorName:  actor.Username,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		IP:         actor.IP,
+		RequestID:  actor.RequestID,
+	}
+	if actor.UserID != 0 {
+		event.ActorID = &actor.UserID
+	}
+	if actor.APIKeyID != 0 {
+		event.APIKeyID = &actor.APIKeyID
+	}
+
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		log.Printf("生成审计差异失败 (%s %s:%s): %v", action, targetType, event.TargetID, err)
+	}
+	event.Changes = changes
+	return event
+}
+
+func (s *auditService) Find(filter repositories.AuditFilter, spec repositories.QuerySpec) (*repositories.Page[models.AuditEvent], error) {
+	return s.auditRepo.Find(filter, spec)
+}
+
+// Export 按写入顺序分批读取全部符合条件的记录
+func (s *auditService) Export(filter repositories.AuditFilter, fn func(events []models.AuditEvent) error) error {
+	return s.auditRepo.FindInBatches(filter, 500, fn)
+}
+
+// auditDiff 比较对象序列化后的字段，只保留发生变化的字段；不输出 JSON 的字段（如密码哈希）不会出现在差异中
+func auditDiff(before, after any) (map[string]models.AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for key, value := range beforeFields {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if next, ok := afterFields[key]; !ok || !reflect.DeepEqual(value, next) {
+			changes[key] = models.AuditChange{Before: value, After: afterFields[key]}
+		}
+	}
+	for key, value := range afterFields {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if _, ok := beforeFields[key]; !ok {
+			changes[key] = models.AuditChange{After: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+// auditFields 将对象转换为字段名到值的映射，字段名与接口返回的 JSON 一致
+func auditFields(v any) (map[string]any, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -1,29 +1,95 @@
 package services
 
 import (
+	"book-management-system/config"
 	"book-management-system/models"
 	"book-management-system/repositories"
 	"book-management-system/utils"
-	"fmt"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// TokenPair 登录成功后签发的访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // 访问令牌有效期，单位秒
+	SessionID    uint
+}
+
+// LoginResult 登录结果
+// 启用两步验证的账户在密码校验通过后只返回 ChallengeToken，需要再调用 VerifyTwoFactor 换取令牌
+type LoginResult struct {
+	User              *models.User
+	Tokens            *TokenPair
+	TwoFactorRequired bool
+	ChallengeToken    string
+}
+
+const twoFactorChallengePurpose = "2fa_challenge"
+
+// twoFactorChallengeData 两步验证挑战令牌携带的数据，密码变更后旧的挑战令牌自动失效
+type twoFactorChallengeData struct {
+	UserID       uint   `json:"uid"`
+	PasswordHash string `json:"ph"`
+}
+
+var (
+	ErrAccountSuspended          = errors.New("账户已被停用，请联系管理员")
+	ErrInvalidTwoFactorChallenge = errors.New("两步验证已超时，请重新登录")
 )
 
 type AuthService interface {
 	Register(username, password, email string) (*models.User, error)
-	Login(username, password string) (*models.User, string, error)
+	Login(username, password string, client ClientInfo) (*LoginResult, error)
+	CompleteLogin(user *models.User, client ClientInfo) (*LoginResult, error)
+	VerifyTwoFactor(challengeToken, code string, client ClientInfo) (*models.User, *TokenPair, error)
+	Refresh(refreshToken string, client ClientInfo) (*models.User, *TokenPair, error)
+	IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error)
+	Authenticate(tokenString string, client ClientInfo) (*models.User, *utils.Claims, error)
+	Logout(claims *utils.Claims, refreshToken string) error
+	LogoutAll(actor Actor, userID uint) error
 	GetUserByID(id uint) (*models.User, error)
-	ChangeUsername(userID uint, newUsername string) error
-	ChangePassword(userID uint, oldPassword, newPassword string) error
-	GetAllUsers() ([]models.User, error)
+	ChangeUsername(actor Actor, userID uint, newUsername string) error
+	ChangePassword(actor Actor, userID uint, oldPassword, newPassword string) error
+	ListUsers(filter repositories.UserFilter, spec repositories.QuerySpec) (*repositories.Page[models.User], error)
 	VerifyPassword(userID uint, password string) (bool, error)
 }
 
 type authService struct {
-	userRepo repositories.UserRepository
+	userRepo      repositories.UserRepository
+	tokens        utils.TokenService
+	hasher        PasswordHasher
+	refreshTokens RefreshTokenService
+	revocations   TokenRevocationService
+	verification  EmailVerificationService
+	guard         LoginGuard
+	twoFactor     TwoFactorService
+	credentials   CredentialVerifier
+	sessions      SessionService
+	audit         AuditService
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthService(userRepo repositories.UserRepository) AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(userRepo repositories.UserRepository, tokens utils.TokenService, hasher PasswordHasher, refreshTokens RefreshTokenService, revocations TokenRevocationService, verification EmailVerificationService, guard LoginGuard, twoFactor TwoFactorService, credentials CredentialVerifier, sessions SessionService, audit AuditService) AuthService {
+	return &authService{
+		userRepo:      userRepo,
+		tokens:        tokens,
+		hasher:        hasher,
+		refreshTokens: refreshTokens,
+		revocations:   revocations,
+		verification:  verification,
+		guard:         guard,
+		twoFactor:     twoFactor,
+		credentials:   credentials,
+		sessions:      sessions,
+		audit:         audit,
+	}
 }
 
 func (s *authService) Register(username, password, email string) (*models.User, error) {
@@ -35,36 +101,270 @@ func (s *authService) Register(username, password, email string) (*models.User,
 		return nil, errors.New("邮箱已存在")
 	}
 
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// 需要邮箱验证时，账户在点击验证链接前保持 pending 状态
+	status := models.UserStatusActive
+	if config.AppConfig.EmailVerificationRequired {
+		status = models.UserStatusPending
+	}
+
 	user := &models.User{
 		Username: username,
-		Password: password,
+		Password: passwordHash,
 		Email:    email,
 		Role:     models.RoleUser,
+		Status:   status,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
+	if err := s.verification.SendVerification(user); err != nil {
+		// 发送失败不影响注册，用户可以稍后重新发送
+		log.Printf("发送验证邮件给用户 %d 失败: %v", user.ID, err)
+	}
+
 	return user, nil
 }
 
-func (s *authService) Login(username, password string) (*models.User, string, error) {
-	user, err := s.userRepo.FindByUsername(username)
+// Login 按 AUTH_BACKENDS 配置的顺序校验用户名和密码
+func (s *authService) Login(username, password string, client ClientInfo) (*LoginResult, error) {
+	if err := s.guard.CheckAccount(username); err != nil {
+		return nil, err
+	}
+
+	user, err := s.credentials.Verify(username, password)
+	if err != nil {
+		// 认证后端不可用不计入失败次数，避免目录服务故障时锁定所有账户
+		if errors.Is(err, ErrAuthBackendUnavailable) {
+			return nil, err
+		}
+		if errors.Is(err, ErrUnknownCredentialUser) {
+			// 用户不存在时同样执行一次哈希校验，避免通过响应时间探测用户名
+			s.hasher.Verify(s.dummyPasswordHash(), password)
+			err = ErrInvalidCredentials
+		}
+		s.recordLoginFailure(username)
+		return nil, err
+	}
+
+	return s.CompleteLogin(user, client)
+}
+
+// CompleteLogin 为已通过身份校验的用户完成登录：启用两步验证时返回挑战令牌，否则直接签发令牌
+// 密码登录和外部身份提供方登录共用该流程，避免绕过两步验证
+func (s *authService) CompleteLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	if user.TOTPEnabled {
+		if err := checkLoginAllowed(user); err != nil {
+			return nil, err
+		}
+
+		// 失败计数在两步验证通过后才清零，验证码错误同样计入失败次数
+		challenge, err := utils.SignToken(twoFactorChallengePurpose, twoFactorChallengeData{
+			UserID:       user.ID,
+			PasswordHash: utils.HashToken(user.Password),
+		}, config.AppConfig.TwoFactorChallengeExpire)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	s.recordLoginSuccess(user)
+
+	tokens, err := s.IssueTokens(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// VerifyTwoFactor 校验登录挑战令牌和验证码（或恢复码），通过后签发令牌
+func (s *authService) VerifyTwoFactor(challengeToken, code string, client ClientInfo) (*models.User, *TokenPair, error) {
+	var data twoFactorChallengeData
+	if err := utils.VerifySignedToken(challengeToken, twoFactorChallengePurpose, &data); err != nil {
+		return nil, nil, ErrInvalidTwoFactorChallenge
+	}
+
+	user, err := s.userRepo.FindByID(data.UserID)
+	if err != nil || utils.HashToken(user.Password) != data.PasswordHash {
+		return nil, nil, ErrInvalidTwoFactorChallenge
+	}
+
+	if err := s.guard.CheckAccount(user.Username); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.twoFactor.VerifyCode(user, code); err != nil {
+		s.recordLoginFailure(user.Username)
+		return nil, nil, err
+	}
+
+	s.recordLoginSuccess(user)
+
+	tokens, err := s.IssueTokens(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+func (s *authService) recordLoginSuccess(user *models.User) {
+	if err := s.guard.RecordSuccess(user.Username); err != nil {
+		log.Printf("清除用户 %d 的登录失败计数失败: %v", user.ID, err)
+	}
+}
+
+func (s *authService) recordLoginFailure(username string) {
+	if err := s.guard.RecordFailure(username); err != nil {
+		log.Printf("记录登录失败次数失败: %v", err)
+	}
+}
+
+// dummyPasswordHash 返回用于等时校验的哈希值，首次调用时生成
+func (s *authService) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy-password-for-timing")
+	})
+	return s.dummyHash
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，刷新令牌每次使用后都会轮换
+func (s *authService) Refresh(refreshToken string, client ClientInfo) (*models.User, *TokenPair, error) {
+	session, newRefreshToken, err := s.refreshTokens.Rotate(refreshToken, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if err := checkLoginAllowed(user); err != nil {
+		return nil, nil, err
+	}
+
+	accessToken, err := s.tokens.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int64(config.AppConfig.JWTExpire.Seconds()),
+		SessionID:    session.ID,
+	}, nil
+}
+
+// IssueTokens 为用户创建新的登录会话，签发访问令牌并开启新的刷新令牌族
+// 账户被停用或未验证邮箱且策略为拒绝登录时返回错误
+func (s *authService) IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error) {
+	if err := checkLoginAllowed(user); err != nil {
+		return nil, err
+	}
+
+	session, refreshToken, err := s.refreshTokens.Issue(user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.tokens.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(config.AppConfig.JWTExpire.Seconds()),
+		SessionID:    session.ID,
+	}, nil
+}
+
+// Authenticate 校验访问令牌的签名、有效期、是否已被撤销、所属会话是否已退出以及用户当前状态
+func (s *authService) Authenticate(tokenString string, client ClientInfo) (*models.User, *utils.Claims, error) {
+	claims, err := s.tokens.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked, err := s.revocations.IsRevoked(claims)
 	if err != nil {
-		return nil, "", errors.New("用户名或密码错误")
+		return nil, nil, fmt.Errorf("检查令牌状态失败: %w", err)
+	}
+	if revoked {
+		return nil, nil, errors.New("令牌已被撤销")
 	}
 
-	if user.Password != password {
-		return nil, "", errors.New("用户名或密码错误")
+	if claims.SessionID != 0 {
+		if err := s.sessions.Validate(claims.SessionID, client); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	token, err := utils.GenerateToken(user)
+	// 已删除或已停用的用户即使持有未过期的令牌也会被拒绝
+	user, err := s.userRepo.FindByID(claims.UserID)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, errors.New("用户不存在")
+	}
+	if err := checkLoginAllowed(user); err != nil {
+		return nil, nil, err
+	}
+
+	return user, claims, nil
+}
+
+// checkLoginAllowed 根据账户状态和未验证邮箱的登录策略判断是否允许使用账户
+func checkLoginAllowed(user *models.User) error {
+	switch user.Status {
+	case models.UserStatusSuspended:
+		return ErrAccountSuspended
+	case models.UserStatusPending:
+		if config.AppConfig.UnverifiedLoginPolicy == "deny" {
+			return ErrEmailNotVerified
+		}
+	}
+	return nil
+}
+
+// Logout 撤销当前访问令牌并退出其所属会话；如果提供了刷新令牌，同时撤销其所在的令牌族
+func (s *authService) Logout(claims *utils.Claims, refreshToken string) error {
+	if err := s.revocations.RevokeToken(claims); err != nil {
+		return err
 	}
 
-	return user, token, nil
+	if claims.SessionID != 0 {
+		if err := s.sessions.End(claims.UserID, claims.SessionID); err != nil && err != ErrSessionNotFound {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		if err := s.refreshTokens.Revoke(refreshToken); err != nil && err != ErrInvalidRefreshToken {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogoutAll 撤销用户在所有设备上的登录状态，用户本人和管理员都可能发起
+func (s *authService) LogoutAll(actor Actor, userID uint) error {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+	if err := s.audit.RecordSecurity(actor, models.AuditUserSessionsRevoke, models.AuditTargetUser, userID, nil, nil); err != nil {
+		return err
+	}
+	return s.revocations.RevokeAllForUser(userID)
 }
 
 func (s *authService) GetUserByID(id uint) (*models.User, error) {
@@ -72,19 +372,33 @@ func (s *authService) GetUserByID(id uint) (*models.User, error) {
 }
 
 // ChangeUsername 更改用户名
-func (s *authService) ChangeUsername(userID uint, newUsername string) error {
+func (s *authService) ChangeUsername(actor Actor, userID uint, newUsername string) error {
 	if newUsername == "" {
 		return errors.New("新用户名不能为空")
 	}
-	
+
 	if len(newUsername) < 3 {
 		return errors.New("用户名长度至少3个字符")
 	}
-	
+
 	if len(newUsername) > 50 {
 		return errors.New("用户名长度不能超过50个字符")
 	}
-	
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	// 先排除用户名已被占用的情况，避免为不会生效的修改写入审计记录
+	if existing, err := s.userRepo.FindByUsername(newUsername); err == nil && existing.ID != userID {
+		return fmt.Errorf("用户名 '%s' 已被占用", newUsername)
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserUsernameChange, models.AuditTargetUser, userID,
+		map[string]any{"username": user.Username}, map[string]any{"username": newUsername}); err != nil {
+		return err
+	}
 	return s.userRepo.UpdateUsername(userID, newUsername)
 }
 
@@ -94,21 +408,35 @@ func (s *authService) VerifyPassword(userID uint, password string) (bool, error)
 	if err != nil {
 		return false, errors.New("用户不存在")
 	}
-	
-	// 由于移除了密码加密，直接比较
-	return user.Password == password, nil
+
+	valid, err := s.hasher.Verify(user.Password, password)
+	if err != nil {
+		return false, err
+	}
+	if valid {
+		rehashPasswordIfNeeded(s.userRepo, s.hasher, user, password)
+	}
+	return valid, nil
 }
 
 // ChangePassword 更改密码
-func (s *authService) ChangePassword(userID uint, oldPassword, newPassword string) error {
+func (s *authService) ChangePassword(actor Actor, userID uint, oldPassword, newPassword string) error {
 	if newPassword == "" {
 		return errors.New("新密码不能为空")
 	}
-	
+
 	if len(newPassword) < 6 {
 		return errors.New("密码长度至少6个字符")
 	}
-	
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.AuthSource == models.AuthSourceLDAP {
+		return errors.New("LDAP 账户的密码需要在目录服务中修改")
+	}
+
 	// 验证原密码
 	valid, err := s.VerifyPassword(userID, oldPassword)
 	if err != nil {
@@ -117,21 +445,30 @@ func (s *authService) ChangePassword(userID uint, oldPassword, newPassword strin
 	if !valid {
 		return errors.New("原密码错误")
 	}
-	
-	return s.userRepo.UpdatePassword(userID, newPassword)
+
+	passwordHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserPasswordChange, models.AuditTargetUser, userID, nil, nil); err != nil {
+		return err
+	}
+	return s.userRepo.UpdatePassword(userID, passwordHash)
 }
 
-// GetAllUsers 获取所有用户（仅管理员使用）
-func (s *authService) GetAllUsers() ([]models.User, error) {
-	users, err := s.userRepo.FindAll()
+// ListUsers 分页获取用户列表（仅管理员使用）
+func (s *authService) ListUsers(filter repositories.UserFilter, spec repositories.QuerySpec) (*repositories.Page[models.User], error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	page, err := s.userRepo.Find(filter, spec)
 	if err != nil {
 		return nil, fmt.Errorf("获取用户列表失败: %w", err)
 	}
-	
+
 	// 移除密码字段，保护隐私
-	for i := range users {
-		users[i].Password = ""
+	for i := range page.Items {
+		page.Items[i].Password = ""
 	}
-	
-	return users, nil
-}
\ No newline at end of file
+
+	return page, nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	maxAuthorNameRunes    = 255
+	maxAuthorNameVariants = 20
+	maxBookContributors   = 50
+	// minAuthorYear 允许登记公元前的作者，年份用负数表示
+	minAuthorYear = -3000
+)
+
+type AuthorService interface {
+	CreateAuthor(actor Actor, author *models.Author) error
+	GetAuthor(id uint) (*models.Author, error)
+	SearchAuthors(filter repositories.AuthorFilter, spec repositories.QuerySpec) (*repositories.Page[models.Author], error)
+	UpdateAuthor(actor Actor, id uint, update AuthorUpdate) (*models.Author, error)
+	DeleteAuthor(actor Actor, id uint) error
+	GetAuthorBooks(id uint, spec repositories.QuerySpec) (*repositories.Page[models.Book], error)
+	ResolveContributors(inputs []ContributorInput) ([]models.BookAuthor, error)
+}
+
+// AuthorUpdate 作者的部分更新，为 nil 的字段保持不变
+type AuthorUpdate struct {
+	Name         *string
+	NameVariants []string // nil 表示不变，空切片表示清空
+	BirthYear    *int     // 0 表示清除
+	DeathYear    *int     // 0 表示清除
+	Bio          *string
+	NeedsReview  *bool
+}
+
+// ContributorInput 图书的一位责任者，AuthorID 为 0 时按 Name 查找，找不到则随图书一起新建作者
+type ContributorInput struct {
+	AuthorID uint
+	Name     string
+	Role     models.ContributorRole
+}
+
+type authorService struct {
+	authorRepo repositories.AuthorRepository
+	audit      AuditService
+}
+
+func NewAuthorService(authorRepo repositories.AuthorRepository, audit AuditService) AuthorService {
+	return &authorService{authorRepo: authorRepo, audit: audit}
+}
+
+func (s *authorService) CreateAuthor(actor Actor, author *models.Author) error {
+	if err := validateAuthor(author); err != nil {
+		return err
+	}
+	if err := s.authorRepo.Create(author); err != nil {
+		return fmt.Errorf("创建作者失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditAuthorCreate, models.AuditTargetAuthor, author.ID, nil, author)
+	return nil
+}
+
+func (s *authorService) GetAuthor(id uint) (*models.Author, error) {
+	return s.authorRepo.FindByID(id)
+}
+
+func (s *authorService) SearchAuthors(filter repositories.AuthorFilter, spec repositories.QuerySpec) (*repositories.Page[models.Author], error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	return s.authorRepo.Search(filter, spec)
+}
+
+func (s *authorService) UpdateAuthor(actor Actor, id uint, update AuthorUpdate) (*models.Author, error) {
+	author, err := s.authorRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *author
+
+	if update.Name != nil {
+		author.Name = *update.Name
+	}
+	if update.NameVariants != nil {
+		author.NameVariants = update.NameVariants
+	}
+	if update.BirthYear != nil {
+		author.BirthYear = optionalYear(*update.BirthYear)
+	}
+	if update.DeathYear != nil {
+		author.DeathYear = optionalYear(*update.DeathYear)
+	}
+	if update.Bio != nil {
+		author.Bio = *update.Bio
+	}
+	if update.NeedsReview != nil {
+		author.NeedsReview = *update.NeedsReview
+	}
+	if err := validateAuthor(author); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorRepo.Update(author); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditAuthorUpdate, models.AuditTargetAuthor, id, before, author)
+	return author, nil
+}
+
+// DeleteAuthor 删除作者，仍关联图书的作者不能删除
+func (s *authorService) DeleteAuthor(actor Actor, id uint) error {
+	author, err := s.authorRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.authorRepo.CountBooks(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("作者仍关联 %d 种图书，请先修改这些图书的责任者", count)
+	}
+
+	if err := s.authorRepo.Delete(id); err != nil {
+		return fmt.Errorf("删除作者失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditAuthorDelete, models.AuditTargetAuthor, id, author, nil)
+	return nil
+}
+
+// GetAuthorBooks 作者参与的图书，每本书的 contributors 列出该作者在书中的角色
+func (s *authorService) GetAuthorBooks(id uint, spec repositories.QuerySpec) (*repositories.Page[models.Book], error) {
+	if _, err := s.authorRepo.FindByID(id); err != nil {
+		return nil, err
+	}
+	return s.authorRepo.FindBooks(id, spec)
+}
+
+// ResolveContributors 把图书的责任者解析为作者记录，返回的关联已加载 Author，重复的作者和角色组合只保留第一个
+// 按姓名给出的新作者只做校验、不保存，其 AuthorID 为 0，同名的新作者共用一个 Author，
+// 由图书仓库在写入图书的事务中创建，图书写入失败时不会留下孤立的作者
+func (s *authorService) ResolveContributors(inputs []ContributorInput) ([]models.BookAuthor, error) {
+	if len(inputs) > maxBookContributors {
+		return nil, fmt.Errorf("责任者不能超过 %d 位", maxBookContributors)
+	}
+
+	type linkKey struct {
+		author *models.Author
+		id     uint
+		role   models.ContributorRole
+	}
+	pending := make(map[string]*models.Author)
+	seen := make(map[linkKey]bool, len(inputs))
+	contributors := make([]models.BookAuthor, 0, len(inputs))
+	for _, input := range inputs {
+		if input.Role == "" {
+			input.Role = models.ContributorAuthor
+		}
+		if !input.Role.IsValid() {
+			return nil, fmt.Errorf("无效的责任者角色: %s", input.Role)
+		}
+
+		author, err := s.resolveAuthor(input, pending)
+		if err != nil {
+			return nil, err
+		}
+
+		key := linkKey{id: author.ID, role: input.Role}
+		if author.ID == 0 {
+			key.author = author
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		contributors = append(contributors, models.BookAuthor{AuthorID: author.ID, Role: input.Role, Author: author})
+	}
+	return contributors, nil
+}
+
+// resolveAuthor 按ID或姓名查找作者，找不到时返回 pending 中同名的或新建的未保存作者
+func (s *authorService) resolveAuthor(input ContributorInput, pending map[string]*models.Author) (*models.Author, error) {
+	if input.AuthorID != 0 {
+		author, err := s.authorRepo.FindByID(input.AuthorID)
+		if err != nil {
+			return nil, fmt.Errorf("作者 %d 不存在", input.AuthorID)
+		}
+		return author, nil
+	}
+
+	name := strings.TrimSpace(input.Name)
+	key := models.AuthorNameKey(name)
+	if key == "" {
+		return nil, errors.New("责任者需要提供作者ID或姓名")
+	}
+	if author, err := s.authorRepo.FindByNameKey(key); err == nil {
+		return author, nil
+	}
+	if author, ok := pending[key]; ok {
+		return author, nil
+	}
+
+	author := &models.Author{Name: name}
+	if err := validateAuthor(author); err != nil {
+		return nil, err
+	}
+	pending[key] = author
+	return author, nil
+}
+
+// validateAuthor 校验作者信息，并就地规范化姓名和其他写法
+func validateAuthor(author *models.Author) error {
+	author.Name = strings.TrimSpace(author.Name)
+	author.NameKey = models.AuthorNameKey(author.Name)
+	if author.NameKey == "" {
+		return errors.New("作者姓名不能为空")
+	}
+	if len([]rune(author.Name)) > maxAuthorNameRunes {
+		return fmt.Errorf("作者姓名不能超过 %d 个字符", maxAuthorNameRunes)
+	}
+
+	// 与正式姓名写法相同的异名没有意义，去掉
+	variants := make([]string, 0, len(author.NameVariants))
+	seen := map[string]bool{author.NameKey: true}
+	for _, variant := range author.NameVariants {
+		variant = strings.TrimSpace(variant)
+		key := models.AuthorNameKey(variant)
+		if key == "" || seen[key] {
+			continue
+		}
+		if len([]rune(variant)) > maxAuthorNameRunes {
+			return fmt.Errorf("作者姓名不能超过 %d 个字符", maxAuthorNameRunes)
+		}
+		seen[key] = true
+		variants = append(variants, variant)
+	}
+	if len(variants) > maxAuthorNameVariants {
+		return fmt.Errorf("其他写法不能超过 %d 个", maxAuthorNameVariants)
+	}
+	author.NameVariants = variants
+	author.VariantKeys = ""
+	if len(variants) > 0 {
+		keys := make([]string, 0, len(variants))
+		for _, variant := range variants {
+			keys = append(keys, models.AuthorNameKey(variant))
+		}
+		author.VariantKeys = "|" + strings.Join(keys, "|") + "|"
+	}
+
+	currentYear := time.Now().Year()
+	for _, year := range []*int{author.BirthYear, author.DeathYear} {
+		if year != nil && (*year < minAuthorYear || *year > currentYear) {
+			return fmt.Errorf("年份必须在 %d 到 %d 之间", minAuthorYear, currentYear)
+		}
+	}
+	if author.BirthYear != nil && author.DeathYear != nil && *author.DeathYear < *author.BirthYear {
+		return errors.New("卒年不能早于生年")
+	}
+
+	author.Bio = strings.TrimSpace(author.Bio)
+	return nil
+}
+
+func optionalYear(year int) *int {
+	if year == 0 {
+		return nil
+	}
+	return &year
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrBarcodeExists = errors.New("条码已被其他副本使用")
+
+// barcodePattern 条码只允许字母、数字和连字符，统一保存为大写
+var barcodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9-]{2,63}$`)
+
+// maxCopiesPerRequest 一次最多添加的副本数
+const maxCopiesPerRequest = 200
+
+type BookCopyService interface {
+	AddCopies(actor Actor, bookID uint, copies []models.BookCopy) ([]models.BookCopy, error)
+	GetCopies(bookID uint, spec repositories.QuerySpec) (*repositories.Page[models.BookCopy], error)
+	GetCopyByBarcode(barcode string) (*models.BookCopy, error)
+	UpdateCopy(actor Actor, barcode string, update BookCopyUpdate) (*models.BookCopy, error)
+	DeleteCopy(actor Actor, barcode string) error
+}
+
+// BookCopyUpdate 副本的部分更新，为 nil 的字段保持不变
+type BookCopyUpdate struct {
+	Status     *models.CopyStatus
+	Condition  *models.CopyCondition
+	AcquiredOn *time.Time
+	Price      *float64
+	Note       *string
+}
+
+type bookCopyService struct {
+	copyRepo repositories.BookCopyRepository
+	bookRepo repositories.BookRepository
+	audit    AuditService
+}
+
+func NewBookCopyService(copyRepo repositories.BookCopyRepository, bookRepo repositories.BookRepository, audit AuditService) BookCopyService {
+	return &bookCopyService{copyRepo: copyRepo, bookRepo: bookRepo, audit: audit}
+}
+
+func (s *bookCopyService) AddCopies(actor Actor, bookID uint, copies []models.BookCopy) ([]models.BookCopy, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, err
+	}
+	if err := validateNewCopies(s.copyRepo, copies); err != nil {
+		return nil, err
+	}
+	for i := range copies {
+		copies[i].BookID = bookID
+	}
+
+	if err := s.copyRepo.Create(copies); err != nil {
+		return nil, err
+	}
+
+	for i := range copies {
+		s.audit.Record(actor, models.AuditCopyCreate, models.AuditTargetBookCopy, copies[i].ID, nil, copies[i])
+	}
+	return copies, nil
+}
+
+func (s *bookCopyService) GetCopies(bookID uint, spec repositories.QuerySpec) (*repositories.Page[models.BookCopy], error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, err
+	}
+	return s.copyRepo.FindByBook(bookID, spec)
+}
+
+func (s *bookCopyService) GetCopyByBarcode(barcode string) (*models.BookCopy, error) {
+	return s.copyRepo.FindByBarcode(NormalizeBarcode(barcode))
+}
+
+// UpdateCopy 修改副本的状态、品相等信息，借出和归还只能通过流通操作完成
+func (s *bookCopyService) UpdateCopy(actor Actor, barcode string, update BookCopyUpdate) (*models.BookCopy, error) {
+	bookCopy, err := s.copyRepo.FindByBarcode(NormalizeBarcode(barcode))
+	if err != nil {
+		return nil, err
+	}
+	before := *bookCopy
+
+	if bookCopy.Status == models.CopyStatusOnLoan {
+		return nil, errors.New("副本已借出，请先办理归还")
+	}
+	if update.Status != nil {
+		if *update.Status == models.CopyStatusOnLoan {
+			return nil, errors.New("借出状态只能通过借书办理")
+		}
+		bookCopy.Status = *update.Status
+	}
+	if update.Condition != nil {
+		bookCopy.Condition = *update.Condition
+	}
+	if update.AcquiredOn != nil {
+		bookCopy.AcquiredOn = update.AcquiredOn
+	}
+	if update.Price != nil {
+		bookCopy.Price = update.Price
+	}
+	if update.Note != nil {
+		bookCopy.Note = strings.TrimSpace(*update.Note)
+	}
+	if err := validateCopy(bookCopy); err != nil {
+		return nil, err
+	}
+
+	if err := s.copyRepo.Update(bookCopy); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditCopyUpdate, models.AuditTargetBookCopy, bookCopy.ID, before, bookCopy)
+	return bookCopy, nil
+}
+
+// DeleteCopy 删除误登记的副本，有借阅历史的副本应改为注销状态
+func (s *bookCopyService) DeleteCopy(actor Actor, barcode string) error {
+	bookCopy, err := s.copyRepo.FindByBarcode(NormalizeBarcode(barcode))
+	if err != nil {
+		return err
+	}
+
+	borrowed, err := s.copyRepo.HasBorrowRecords(bookCopy.ID)
+	if err != nil {
+		return err
+	}
+	if borrowed {
+		return errors.New("副本有借阅记录，不能删除，请将状态改为注销")
+	}
+
+	if err := s.copyRepo.Delete(bookCopy); err != nil {
+		return err
+	}
+
+	s.audit.Record(actor, models.AuditCopyDelete, models.AuditTargetBookCopy, bookCopy.ID, bookCopy, nil)
+	return nil
+}
+
+// NormalizeBarcode 去除扫码枪或手工输入带入的空白，并统一为大写
+func NormalizeBarcode(barcode string) string {
+	return strings.ToUpper(strings.TrimSpace(barcode))
+}
+
+// validateNewCopies 校验待添加的副本，并检查条码在请求内和库中均未被使用
+func validateNewCopies(copyRepo repositories.BookCopyRepository, copies []models.BookCopy) error {
+	if len(copies) == 0 {
+		return errors.New("至少需要一册副本")
+	}
+	if len(copies) > maxCopiesPerRequest {
+		return fmt.Errorf("一次最多添加 %d 册副本", maxCopiesPerRequest)
+	}
+
+	seen := make(map[string]bool, len(copies))
+	for i := range copies {
+		bookCopy := &copies[i]
+		bookCopy.Barcode = NormalizeBarcode(bookCopy.Barcode)
+		if bookCopy.Status == "" {
+			bookCopy.Status = models.CopyStatusAvailable
+		}
+		if bookCopy.Status == models.CopyStatusOnLoan {
+			return errors.New("借出状态只能通过借书办理")
+		}
+		if err := validateCopy(bookCopy); err != nil {
+			return err
+		}
+
+		// 未指定条码的副本由系统生成
+		if bookCopy.Barcode == "" {
+			continue
+		}
+		if seen[bookCopy.Barcode] {
+			return fmt.Errorf("条码 %s 重复", bookCopy.Barcode)
+		}
+		seen[bookCopy.Barcode] = true
+		if _, err := copyRepo.FindByBarcode(bookCopy.Barcode); err == nil {
+			return fmt.Errorf("%w: %s", ErrBarcodeExists, bookCopy.Barcode)
+		}
+	}
+	return nil
+}
+
+// validateCopy 校验副本字段，品相缺省为良好
+func validateCopy(bookCopy *models.BookCopy) error {
+	if bookCopy.Barcode != "" && !barcodePattern.MatchString(bookCopy.Barcode) {
+		return fmt.Errorf("条码 %s 格式不正确，只能包含字母、数字和连字符，长度 3 到 64", bookCopy.Barcode)
+	}
+	if !bookCopy.Status.IsValid() {
+		return fmt.Errorf("无效的副本状态: %s", bookCopy.Status)
+	}
+	if bookCopy.Condition == "" {
+		bookCopy.Condition = models.CopyConditionGood
+	}
+	if !bookCopy.Condition.IsValid() {
+		return fmt.Errorf("无效的副本品相: %s", bookCopy.Condition)
+	}
+	if bookCopy.Price != nil && *bookCopy.Price < 0 {
+		return errors.New("价格不能为负数")
+	}
+	if bookCopy.AcquiredOn != nil && bookCopy.AcquiredOn.After(time.Now()) {
+		return errors.New("入藏日期不能晚于今天")
+	}
+	return nil
+}
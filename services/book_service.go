@@ -3,112 +3,391 @@ package services
 import (
 	"book-management-system/models"
 	"book-management-system/repositories"
+	"book-management-system/utils"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 )
 
+var ErrISBNExists = errors.New("该 ISBN 的图书已存在，请使用更新功能")
+
+const (
+	maxBookSubjects     = 20
+	maxBookSubjectRunes = 100
+)
+
+// languageTagPattern 宽松的 BCP 47 语言标签，如 zh、zh-CN、en-US
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// BookUpdate 图书的部分更新，为 nil 的字段保持不变
+type BookUpdate struct {
+	Title           *string
+	Author          *string // 未提供 Contributors 时按作者文本重新关联著者，编者、译者等保持不变
+	ISBN            *string // 空字符串表示清除 ISBN
+	Publisher       *string
+	PublicationYear *int
+	Edition         *string
+	Language        *string
+	PageCount       *int
+	Description     *string
+	Subjects        []string           // nil 表示不变，空切片表示清空
+	Contributors    []ContributorInput // nil 表示不变，提供时整体替换并由此生成作者文本
+	CategoryIDs     []uint             // nil 表示不变，空切片表示移出全部分类
+	Tags            []string           // nil 表示不变，空切片表示清空
+}
+
 type BookService interface {
-	CreateBook(book *models.Book) error
+	CreateBook(actor Actor, book *models.Book, contributors []ContributorInput) error
 	GetBookByID(id uint) (*models.Book, error)
-	GetAllBooks() ([]models.Book, error)
-	UpdateBook(id uint, book *models.Book) error
-	DeleteBook(id uint) error
-	SearchBooks(query string) ([]models.Book, error)
-	BorrowBook(userID, bookID uint) error
-	ReturnBook(userID, bookID uint) error
+	ListBooks(filter repositories.BookFilter, spec repositories.QuerySpec) (*repositories.Page[models.Book], error)
+	UpdateBook(actor Actor, id uint, update BookUpdate) error
+	DeleteBook(actor Actor, id uint) error
+	BorrowBook(actor Actor, userID, bookID uint) error
+	ReturnBook(actor Actor, userID, bookID uint) error
+	CheckoutCopy(actor Actor, userID uint, barcode string) (*models.BorrowRecord, error)
+	CheckinCopy(actor Actor, barcode string) (*models.BorrowRecord, error)
 	GetBorrowedBooks(userID uint) ([]models.Book, error)
-	GetBorrowRecords(userID uint) ([]models.BorrowRecord, error)
-	GetAllBorrowRecords() ([]models.BorrowRecord, error)
+	ListBorrowRecords(filter repositories.BorrowRecordFilter, spec repositories.QuerySpec) (*repositories.Page[models.BorrowRecord], error)
 	CheckBookAvailability(bookID uint) (bool, error)
 }
 
 type bookService struct {
-	bookRepo repositories.BookRepositoryWithBorrow
+	bookRepo   repositories.BookRepositoryWithBorrow
+	copyRepo   repositories.BookCopyRepository
+	authors    AuthorService
+	categories CategoryService
+	tags       TagService
+	audit      AuditService
 }
 
-func NewBookService(bookRepo repositories.BookRepositoryWithBorrow) BookService {
-	return &bookService{bookRepo: bookRepo}
+func NewBookService(bookRepo repositories.BookRepositoryWithBorrow, copyRepo repositories.BookCopyRepository, authors AuthorService, categories CategoryService, tags TagService, audit AuditService) BookService {
+	return &bookService{bookRepo: bookRepo, copyRepo: copyRepo, authors: authors, categories: categories, tags: tags, audit: audit}
 }
 
-func (s *bookService) CreateBook(book *models.Book) error {
-	if book.Title == "" {
-		return errors.New("书名不能为空")
+// CreateBook 创建图书及其副本，未提供副本时按 TotalCopies 生成自动条码的副本
+// 提供 contributors 时作者文本由责任者生成，否则把作者文本拆分为著者
+// book.Categories 只需填写ID，book.Tags 只需填写名称，不存在的标签自动创建
+func (s *bookService) CreateBook(actor Actor, book *models.Book, contributors []ContributorInput) error {
+	explicit := len(contributors) > 0
+	if !explicit {
+		contributors = authorInputs(book.Author)
 	}
-	if book.Author == "" {
+	if len(contributors) == 0 {
 		return errors.New("作者不能为空")
 	}
-	if book.TotalCopies <= 0 {
-		return errors.New("库存数量必须大于0")
+
+	if len(book.Copies) == 0 {
+		if book.TotalCopies <= 0 {
+			return errors.New("库存数量必须大于0")
+		}
+		book.Copies = make([]models.BookCopy, book.TotalCopies)
+	}
+	if err := validateBook(book); err != nil {
+		return err
+	}
+	if err := validateNewCopies(s.copyRepo, book.Copies); err != nil {
+		return err
 	}
 
-	//检查图书是否已存在
-	exists, err := s.bookRepo.ExistsByTitleAndAuthor(book.Title, book.Author)
+	// 按 ISBN 查重，同一书名和作者的不同版本可以共存
+	if err := s.checkISBNUnique(book.ISBN, 0); err != nil {
+		return err
+	}
+
+	categoryIDs := make([]uint, 0, len(book.Categories))
+	for _, category := range book.Categories {
+		categoryIDs = append(categoryIDs, category.ID)
+	}
+	categories, err := s.categories.ResolveCategories(categoryIDs)
 	if err != nil {
-		return fmt.Errorf("检查图书存在性失败: %w", err)
+		return err
 	}
-	if exists {
-		return errors.New("该图书已存在，请使用更新功能")
+	book.Categories = categories
+	tagNames := make([]string, 0, len(book.Tags))
+	for _, tag := range book.Tags {
+		tagNames = append(tagNames, tag.Name)
 	}
+	tags, err := s.tags.ResolveTags(tagNames)
+	if err != nil {
+		return err
+	}
+	book.Tags = tags
 
-	// 设置可用库存
-	if book.Available == 0 {
-		book.Available = book.TotalCopies
+	resolved, err := s.authors.ResolveContributors(contributors)
+	if err != nil {
+		return err
+	}
+	book.Contributors = resolved
+	if explicit {
+		book.Author = models.ContributorDisplay(resolved)
+	}
+
+	created := pendingAuthors(resolved)
+	if err := s.bookRepo.Create(book); err != nil {
+		return err
 	}
 
-	return s.bookRepo.Create(book)
+	s.recordAuthorsCreated(actor, created)
+	s.audit.Record(actor, models.AuditBookCreate, models.AuditTargetBook, book.ID, nil, bookAuditFields(book))
+	for i := range book.Copies {
+		s.audit.Record(actor, models.AuditCopyCreate, models.AuditTargetBookCopy, book.Copies[i].ID, nil, book.Copies[i])
+	}
+	return nil
 }
 
 func (s *bookService) GetBookByID(id uint) (*models.Book, error) {
 	return s.bookRepo.FindByID(id)
 }
 
-func (s *bookService) GetAllBooks() ([]models.Book, error) {
-	return s.bookRepo.FindAll()
-}
-
-func (s *bookService) CheckBookExists(title, author string) (bool, error) {
-	return s.bookRepo.ExistsByTitleAndAuthor(title, author)
+func (s *bookService) ListBooks(filter repositories.BookFilter, spec repositories.QuerySpec) (*repositories.Page[models.Book], error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	return s.bookRepo.Find(filter, spec)
 }
 
-func (s *bookService) UpdateBook(id uint, book *models.Book) error {
+func (s *bookService) UpdateBook(actor Actor, id uint, update BookUpdate) error {
 	existing, err := s.bookRepo.FindByID(id)
 	if err != nil {
 		return err
 	}
+	before := *existing
 
-	existing.Title = book.Title
-	existing.Author = book.Author
+	update.apply(existing)
+	if update.Contributors != nil && len(update.Contributors) == 0 {
+		return errors.New("至少需要一位责任者")
+	}
+	if update.Contributors == nil && strings.TrimSpace(existing.Author) == "" {
+		return errors.New("作者不能为空")
+	}
+	if err := validateBook(existing); err != nil {
+		return err
+	}
+	if !sameISBN(before.ISBN, existing.ISBN) {
+		if err := s.checkISBNUnique(existing.ISBN, id); err != nil {
+			return err
+		}
+	}
 
-	if existing.TotalCopies != book.TotalCopies {
-		diff := book.TotalCopies - existing.TotalCopies
-		existing.Available += diff
-		if existing.Available < 0 {
-			existing.Available = 0
+	if update.CategoryIDs != nil {
+		if existing.Categories, err = s.categories.ResolveCategories(update.CategoryIDs); err != nil {
+			return err
+		}
+	}
+	if update.Tags != nil {
+		if existing.Tags, err = s.tags.ResolveTags(update.Tags); err != nil {
+			return err
 		}
-		existing.TotalCopies = book.TotalCopies
 	}
 
-	return s.bookRepo.Update(existing)
+	switch {
+	case update.Contributors != nil:
+		resolved, err := s.authors.ResolveContributors(update.Contributors)
+		if err != nil {
+			return err
+		}
+		existing.Contributors = resolved
+		existing.Author = models.ContributorDisplay(resolved)
+	case existing.Author != before.Author:
+		// 只改了作者文本：按新文本重新关联著者，保留编者、译者等其他责任者
+		resolved, err := s.authors.ResolveContributors(authorInputs(existing.Author))
+		if err != nil {
+			return err
+		}
+		for _, c := range before.Contributors {
+			if c.Role != models.ContributorAuthor {
+				resolved = append(resolved, c)
+			}
+		}
+		existing.Contributors = resolved
+	}
+
+	created := pendingAuthors(existing.Contributors)
+	if err := s.bookRepo.Update(existing); err != nil {
+		return err
+	}
+
+	s.recordAuthorsCreated(actor, created)
+	s.audit.Record(actor, models.AuditBookUpdate, models.AuditTargetBook, id, bookAuditFields(&before), bookAuditFields(existing))
+	return nil
 }
 
-func (s *bookService) DeleteBook(id uint) error {
-	book, err := s.bookRepo.FindByID(id)
+// pendingAuthors 返回尚未保存、将随图书一起创建的作者，共用的 Author 只返回一次
+func pendingAuthors(contributors []models.BookAuthor) []*models.Author {
+	var authors []*models.Author
+	seen := make(map[*models.Author]bool)
+	for _, c := range contributors {
+		if c.AuthorID == 0 && c.Author != nil && !seen[c.Author] {
+			seen[c.Author] = true
+			authors = append(authors, c.Author)
+		}
+	}
+	return authors
+}
+
+// recordAuthorsCreated 为随图书一起创建的作者写审计日志，调用时图书已保存，作者已有ID
+func (s *bookService) recordAuthorsCreated(actor Actor, authors []*models.Author) {
+	for _, author := range authors {
+		s.audit.Record(actor, models.AuditAuthorCreate, models.AuditTargetAuthor, author.ID, nil, author)
+	}
+}
+
+// authorInputs 把作者文本拆分为著者，含有逗号、& 或 and 的部分按一位著者处理，
+// 需要拆分时由调用方通过 contributors 逐一指定
+func authorInputs(author string) []ContributorInput {
+	names, _ := models.SplitAuthorNames(author)
+	inputs := make([]ContributorInput, 0, len(names))
+	for _, name := range names {
+		inputs = append(inputs, ContributorInput{Name: name, Role: models.ContributorAuthor})
+	}
+	return inputs
+}
+
+// apply 将非 nil 的字段写入 book，校验和规范化由 validateBook 完成
+func (u BookUpdate) apply(book *models.Book) {
+	if u.Title != nil {
+		book.Title = *u.Title
+	}
+	if u.Author != nil {
+		book.Author = *u.Author
+	}
+	if u.ISBN != nil {
+		isbn := *u.ISBN
+		book.ISBN = &isbn
+	}
+	if u.Publisher != nil {
+		book.Publisher = *u.Publisher
+	}
+	if u.PublicationYear != nil {
+		book.PublicationYear = *u.PublicationYear
+	}
+	if u.Edition != nil {
+		book.Edition = *u.Edition
+	}
+	if u.Language != nil {
+		book.Language = *u.Language
+	}
+	if u.PageCount != nil {
+		book.PageCount = *u.PageCount
+	}
+	if u.Description != nil {
+		book.Description = *u.Description
+	}
+	if u.Subjects != nil {
+		book.Subjects = u.Subjects
+	}
+}
+
+// validateBook 校验图书信息，并就地规范化 ISBN、语言标签和主题词
+func validateBook(book *models.Book) error {
+	book.Title = strings.TrimSpace(book.Title)
+	book.Author = strings.TrimSpace(book.Author)
+	if book.Title == "" {
+		return errors.New("书名不能为空")
+	}
+
+	if book.ISBN != nil {
+		if strings.TrimSpace(*book.ISBN) == "" {
+			// 未登记 ISBN 的图书保存为 NULL，不参与唯一索引
+			book.ISBN = nil
+		} else {
+			isbn, err := utils.NormalizeISBN(*book.ISBN)
+			if err != nil {
+				return err
+			}
+			book.ISBN = &isbn
+		}
+	}
+
+	if book.PublicationYear != 0 {
+		if maxYear := time.Now().Year() + 1; book.PublicationYear < 1000 || book.PublicationYear > maxYear {
+			return fmt.Errorf("出版年份必须在 1000 到 %d 之间", maxYear)
+		}
+	}
+	if book.PageCount < 0 {
+		return errors.New("页数不能为负数")
+	}
+
+	book.Language = strings.TrimSpace(book.Language)
+	if book.Language != "" && !languageTagPattern.MatchString(book.Language) {
+		return errors.New("语言必须是 BCP 47 语言标签，如 zh-CN、en")
+	}
+
+	subjects, err := normalizeSubjects(book.Subjects)
 	if err != nil {
 		return err
 	}
+	book.Subjects = subjects
+
+	book.Publisher = strings.TrimSpace(book.Publisher)
+	book.Edition = strings.TrimSpace(book.Edition)
+	return nil
+}
+
+// normalizeSubjects 去除主题词两端空白，忽略空项和重复项（不区分大小写）
+func normalizeSubjects(subjects []string) ([]string, error) {
+	result := make([]string, 0, len(subjects))
+	seen := make(map[string]bool, len(subjects))
+	for _, subject := range subjects {
+		subject = strings.TrimSpace(subject)
+		if subject == "" {
+			continue
+		}
+		if len([]rune(subject)) > maxBookSubjectRunes {
+			return nil, fmt.Errorf("主题词不能超过 %d 个字符", maxBookSubjectRunes)
+		}
+		key := strings.ToLower(subject)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, subject)
+	}
+	if len(result) > maxBookSubjects {
+		return nil, fmt.Errorf("主题词不能超过 %d 个", maxBookSubjects)
+	}
+	return result, nil
+}
 
-	if book.Available != book.TotalCopies {
-		return errors.New("无法删除正在借阅的书籍")
+// checkISBNUnique 检查 ISBN 是否已被其他图书使用，excludeID 为正在更新的图书
+func (s *bookService) checkISBNUnique(isbn *string, excludeID uint) error {
+	if isbn == nil {
+		return nil
+	}
+	existing, err := s.bookRepo.FindByISBN(*isbn)
+	if err != nil {
+		// 未找到即可使用；其他查询错误交给数据库唯一索引兜底
+		return nil
+	}
+	if existing.ID != excludeID {
+		return fmt.Errorf("%w（图书ID %d《%s》）", ErrISBNExists, existing.ID, existing.Title)
 	}
+	return nil
+}
 
-	return s.bookRepo.Delete(id)
+func sameISBN(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
-func (s *bookService) SearchBooks(query string) ([]models.Book, error) {
-	return s.bookRepo.Search(query)
+func (s *bookService) DeleteBook(actor Actor, id uint) error {
+	book, err := s.bookRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.bookRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.audit.Record(actor, models.AuditBookDelete, models.AuditTargetBook, id, bookAuditFields(book), nil)
+	return nil
 }
 
-func (s *bookService) BorrowBook(userID, bookID uint) error {
+// BorrowBook 为读者借出图书，actor 为办理人，读者自助借阅时即读者本人
+func (s *bookService) BorrowBook(actor Actor, userID, bookID uint) error {
 	book, err := s.bookRepo.FindByID(bookID)
 	if err != nil {
 		return errors.New("图书不存在")
@@ -118,23 +397,105 @@ func (s *bookService) BorrowBook(userID, bookID uint) error {
 		return errors.New("图书已全部借出")
 	}
 
-	return s.bookRepo.BorrowBook(userID, bookID)
+	record, err := s.bookRepo.BorrowBook(userID, bookID)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(actor, models.AuditCirculationBorrow, models.AuditTargetBorrowRecord, record.ID, nil, borrowAuditFields(record))
+	return nil
 }
 
-func (s *bookService) ReturnBook(userID, bookID uint) error {
-	return s.bookRepo.ReturnBook(userID, bookID)
+// CheckoutCopy 流通台扫码借出指定副本
+func (s *bookService) CheckoutCopy(actor Actor, userID uint, barcode string) (*models.BorrowRecord, error) {
+	bookCopy, err := s.copyRepo.FindByBarcode(NormalizeBarcode(barcode))
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.bookRepo.BorrowCopy(userID, bookCopy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditCirculationBorrow, models.AuditTargetBorrowRecord, record.ID, nil, borrowAuditFields(record))
+	return record, nil
 }
 
-func (s *bookService) GetBorrowedBooks(userID uint) ([]models.Book, error) {
-	return s.bookRepo.GetBorrowedBooks(userID)
+// CheckinCopy 流通台扫码归还副本，借阅人由副本当前的借阅记录确定
+func (s *bookService) CheckinCopy(actor Actor, barcode string) (*models.BorrowRecord, error) {
+	bookCopy, err := s.copyRepo.FindByBarcode(NormalizeBarcode(barcode))
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.bookRepo.GetActiveBorrowRecordByCopy(bookCopy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.bookRepo.ReturnBorrowRecord(record.ID); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditCirculationReturn, models.AuditTargetBorrowRecord, record.ID, map[string]any{"returned": false}, returnAuditFields(record))
+	return record, nil
+}
+
+func (s *bookService) ReturnBook(actor Actor, userID, bookID uint) error {
+	record, err := s.bookRepo.GetActiveBorrowRecord(userID, bookID)
+	if err != nil {
+		return fmt.Errorf("未找到借阅记录: %w", err)
+	}
+
+	if err := s.bookRepo.ReturnBook(userID, bookID); err != nil {
+		return err
+	}
+
+	s.audit.Record(actor, models.AuditCirculationReturn, models.AuditTargetBorrowRecord, record.ID, map[string]any{"returned": false}, returnAuditFields(record))
+	return nil
+}
+
+// borrowAuditFields 借阅记录中需要审计的字段，不包含预加载的图书和用户
+func borrowAuditFields(record *models.BorrowRecord) map[string]any {
+	fields := map[string]any{
+		"user_id":  record.UserID,
+		"book_id":  record.BookID,
+		"copy_id":  record.CopyID,
+		"due_date": record.DueDate,
+		"returned": record.ReturnedAt != nil,
+	}
+	if record.Copy != nil {
+		fields["barcode"] = record.Copy.Barcode
+	}
+	return fields
 }
 
-func (s *bookService) GetBorrowRecords(userID uint) ([]models.BorrowRecord, error) {
-	return s.bookRepo.GetBorrowRecords(userID)
+// returnAuditFields 差异中保留读者、图书和副本，便于不回查借阅记录就能看出归还的是什么
+func returnAuditFields(record *models.BorrowRecord) map[string]any {
+	after := borrowAuditFields(record)
+	after["returned"] = true
+	return after
+}
+
+// bookAuditFields 图书的审计字段，副本单独记录，责任者只保留作者ID和角色
+func bookAuditFields(book *models.Book) models.Book {
+	fields := *book
+	fields.Copies = nil
+	fields.Contributors = make([]models.BookAuthor, len(book.Contributors))
+	for i, c := range book.Contributors {
+		c.Author, c.Book = nil, nil
+		fields.Contributors[i] = c
+	}
+	return fields
+}
+
+func (s *bookService) GetBorrowedBooks(userID uint) ([]models.Book, error) {
+	return s.bookRepo.GetBorrowedBooks(userID)
 }
 
-func (s *bookService) GetAllBorrowRecords() ([]models.BorrowRecord, error) {
-	return s.bookRepo.GetAllBorrowRecords()
+func (s *bookService) ListBorrowRecords(filter repositories.BorrowRecordFilter, spec repositories.QuerySpec) (*repositories.Page[models.BorrowRecord], error) {
+	return s.bookRepo.FindBorrowRecords(filter, spec)
 }
 
 func (s *bookService) CheckBookAvailability(bookID uint) (bool, error) {
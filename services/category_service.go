@@ -0,0 +1,350 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxCategoryDepth 分类树最多的层数
+	maxCategoryDepth     = 8
+	maxCategoryNameRunes = 100
+	maxBookCategories    = 20
+)
+
+// CategoryNode 分类树中的一个节点
+type CategoryNode struct {
+	models.Category
+	BookCount int64           `json:"book_count"` // 直接归入该分类的图书数，不含下级分类
+	Children  []*CategoryNode `json:"children"`
+}
+
+// CategoryDetail 分类详情，含从根开始的各级上级分类和直接下级分类
+type CategoryDetail struct {
+	models.Category
+	Ancestors []models.Category `json:"ancestors"`
+	Children  []models.Category `json:"children"`
+}
+
+// CategoryUpdate 分类的部分更新，为 nil 的字段保持不变；调整位置使用 MoveCategory
+type CategoryUpdate struct {
+	Name        *string
+	Description *string
+	Position    *int
+}
+
+type CategoryService interface {
+	GetTree() ([]*CategoryNode, error)
+	GetCategory(id uint) (*CategoryDetail, error)
+	GetCategoryBooks(id uint, includeDescendants bool, spec repositories.QuerySpec) (*repositories.Page[models.Book], error)
+	CreateCategory(actor Actor, category *models.Category) error
+	UpdateCategory(actor Actor, id uint, update CategoryUpdate) (*models.Category, error)
+	MoveCategory(actor Actor, id uint, parentID *uint) (*models.Category, error)
+	MergeCategory(actor Actor, sourceID, targetID uint) (*models.Category, error)
+	DeleteCategory(actor Actor, id uint) error
+	ResolveCategories(ids []uint) ([]models.Category, error)
+}
+
+type categoryService struct {
+	categoryRepo repositories.CategoryRepository
+	audit        AuditService
+}
+
+func NewCategoryService(categoryRepo repositories.CategoryRepository, audit AuditService) CategoryService {
+	return &categoryService{categoryRepo: categoryRepo, audit: audit}
+}
+
+// GetTree 完整的分类树，同级分类按排列顺序和名称排列
+func (s *categoryService) GetTree() ([]*CategoryNode, error) {
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.categoryRepo.CountBooksByCategory()
+	if err != nil {
+		return nil, err
+	}
+
+	// FindAll 按层级排列，上级分类总是先于下级分类出现
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	roots := make([]*CategoryNode, 0)
+	for _, category := range categories {
+		node := &CategoryNode{Category: category, BookCount: counts[category.ID], Children: []*CategoryNode{}}
+		nodes[category.ID] = node
+		if category.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return roots, nil
+}
+
+func (s *categoryService) GetCategory(id uint) (*CategoryDetail, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors, err := s.categoryRepo.FindByIDs(category.AncestorIDs())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ancestors, func(i, j int) bool { return ancestors[i].Depth < ancestors[j].Depth })
+
+	children, err := s.categoryRepo.FindChildren(id)
+	if err != nil {
+		return nil, err
+	}
+	return &CategoryDetail{Category: *category, Ancestors: ancestors, Children: children}, nil
+}
+
+func (s *categoryService) GetCategoryBooks(id uint, includeDescendants bool, spec repositories.QuerySpec) (*repositories.Page[models.Book], error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.categoryRepo.FindBooks(category, includeDescendants, spec)
+}
+
+func (s *categoryService) CreateCategory(actor Actor, category *models.Category) error {
+	if err := validateCategory(category); err != nil {
+		return err
+	}
+	if category.ParentID != nil {
+		parent, err := s.categoryRepo.FindByID(*category.ParentID)
+		if err != nil {
+			return errors.New("上级分类不存在")
+		}
+		if parent.Depth+1 >= maxCategoryDepth {
+			return fmt.Errorf("分类最多 %d 级", maxCategoryDepth)
+		}
+	}
+	if err := s.checkSiblingName(category.ParentID, category.Name, 0); err != nil {
+		return err
+	}
+
+	if err := s.categoryRepo.Create(category); err != nil {
+		return fmt.Errorf("创建分类失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditCategoryCreate, models.AuditTargetCategory, category.ID, nil, category)
+	return nil
+}
+
+func (s *categoryService) UpdateCategory(actor Actor, id uint, update CategoryUpdate) (*models.Category, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *category
+
+	if update.Name != nil {
+		category.Name = *update.Name
+	}
+	if update.Description != nil {
+		category.Description = *update.Description
+	}
+	if update.Position != nil {
+		category.Position = *update.Position
+	}
+	if err := validateCategory(category); err != nil {
+		return nil, err
+	}
+	if category.Name != before.Name {
+		if err := s.checkSiblingName(category.ParentID, category.Name, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.categoryRepo.Update(category); err != nil {
+		return nil, fmt.Errorf("更新分类失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditCategoryUpdate, models.AuditTargetCategory, id, before, category)
+	return category, nil
+}
+
+// MoveCategory 把分类连同下级分类移到 parentID 之下，parentID 为 nil 时移为顶级分类
+func (s *categoryService) MoveCategory(actor Actor, id uint, parentID *uint) (*models.Category, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *category
+
+	var parent *models.Category
+	depth := 0
+	if parentID != nil {
+		if parent, err = s.categoryRepo.FindByID(*parentID); err != nil {
+			return nil, errors.New("上级分类不存在")
+		}
+		if category.IsAncestorOf(parent) {
+			return nil, errors.New("不能把分类移到自身或其下级分类之下")
+		}
+		depth = parent.Depth + 1
+	}
+	if sameParent(category.ParentID, parentID) {
+		return category, nil
+	}
+
+	height, err := s.categoryRepo.SubtreeHeight(category)
+	if err != nil {
+		return nil, err
+	}
+	if depth+height >= maxCategoryDepth {
+		return nil, fmt.Errorf("移动后分类超过 %d 级", maxCategoryDepth)
+	}
+	if err := s.checkSiblingName(parentID, category.Name, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.categoryRepo.Move(category, parent); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditCategoryMove, models.AuditTargetCategory, id, before, category)
+	return category, nil
+}
+
+// MergeCategory 把 source 的图书和下级分类并入 target 后删除 source，返回 target
+func (s *categoryService) MergeCategory(actor Actor, sourceID, targetID uint) (*models.Category, error) {
+	if sourceID == targetID {
+		return nil, errors.New("不能把分类合并到自身")
+	}
+	source, err := s.categoryRepo.FindByID(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.categoryRepo.FindByID(targetID)
+	if err != nil {
+		return nil, errors.New("目标分类不存在")
+	}
+	if source.IsAncestorOf(target) {
+		return nil, errors.New("不能把分类合并到其下级分类")
+	}
+
+	// 下级分类挂到 target 之下后层级随之变化，且不能与 target 现有的下级分类重名
+	height, err := s.categoryRepo.SubtreeHeight(source)
+	if err != nil {
+		return nil, err
+	}
+	if target.Depth+height >= maxCategoryDepth {
+		return nil, fmt.Errorf("合并后分类超过 %d 级", maxCategoryDepth)
+	}
+	children, err := s.categoryRepo.FindChildren(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if err := s.checkSiblingName(&targetID, child.Name, sourceID); err != nil {
+			return nil, fmt.Errorf("下级分类「%s」与目标分类的下级分类重名，请先合并或改名", child.Name)
+		}
+	}
+
+	if err := s.categoryRepo.Merge(source, target); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(actor, models.AuditCategoryMerge, models.AuditTargetCategory, sourceID, source, target)
+	return target, nil
+}
+
+// DeleteCategory 删除分类，有下级分类或图书的分类需要先移走或合并
+func (s *categoryService) DeleteCategory(actor Actor, id uint) error {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	children, err := s.categoryRepo.CountChildren(id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return fmt.Errorf("分类下还有 %d 个下级分类，请先移走或删除", children)
+	}
+	books, err := s.categoryRepo.CountBooks(id)
+	if err != nil {
+		return err
+	}
+	if books > 0 {
+		return fmt.Errorf("分类下还有 %d 种图书，请先修改这些图书的分类或合并到其他分类", books)
+	}
+
+	if err := s.categoryRepo.Delete(id); err != nil {
+		return fmt.Errorf("删除分类失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditCategoryDelete, models.AuditTargetCategory, id, category, nil)
+	return nil
+}
+
+// ResolveCategories 按ID查找图书要归入的分类，忽略重复ID，结果按路径排列
+func (s *categoryService) ResolveCategories(ids []uint) ([]models.Category, error) {
+	unique := make([]uint, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) > maxBookCategories {
+		return nil, fmt.Errorf("一种图书最多归入 %d 个分类", maxBookCategories)
+	}
+
+	categories, err := s.categoryRepo.FindByIDs(unique)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) != len(unique) {
+		found := make(map[uint]bool, len(categories))
+		for _, category := range categories {
+			found[category.ID] = true
+		}
+		for _, id := range unique {
+			if !found[id] {
+				return nil, fmt.Errorf("分类 %d 不存在", id)
+			}
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Path < categories[j].Path })
+	return categories, nil
+}
+
+// checkSiblingName 检查同一上级分类下是否已有同名分类，excludeID 为正在修改的分类
+func (s *categoryService) checkSiblingName(parentID *uint, name string, excludeID uint) error {
+	existing, err := s.categoryRepo.FindSiblingByName(parentID, name)
+	if err != nil {
+		return nil
+	}
+	if existing.ID != excludeID {
+		return fmt.Errorf("同级已有名为「%s」的分类", existing.Name)
+	}
+	return nil
+}
+
+// validateCategory 校验分类信息，并就地去除名称和说明两端的空白
+func validateCategory(category *models.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return errors.New("分类名称不能为空")
+	}
+	if len([]rune(category.Name)) > maxCategoryNameRunes {
+		return fmt.Errorf("分类名称不能超过 %d 个字符", maxCategoryNameRunes)
+	}
+	category.Description = strings.TrimSpace(category.Description)
+	return nil
+}
+
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var (
+	ErrInvalidCredentials     = errors.New("用户名或密码错误")
+	ErrUnknownCredentialUser  = errors.New("用户不属于该认证后端")
+	ErrAuthBackendUnavailable = errors.New("认证服务暂时不可用，请稍后再试")
+)
+
+// CredentialVerifier 用户名密码的校验后端
+// 用户不由该后端管理时返回 ErrUnknownCredentialUser，由下一个后端继续尝试；
+// 返回 ErrInvalidCredentials 表示用户属于该后端但密码错误，不再尝试其他后端；
+// 后端自身故障时返回包装了 ErrAuthBackendUnavailable 的错误
+type CredentialVerifier interface {
+	Verify(username, password string) (*models.User, error)
+}
+
+// NewCredentialVerifier 按 AUTH_BACKENDS 的顺序组合认证后端
+func NewCredentialVerifier(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, hasher PasswordHasher, audit AuditService) (CredentialVerifier, error) {
+	var chain credentialChain
+	for _, backend := range config.AppConfig.AuthBackends {
+		switch backend {
+		case "local":
+			chain = append(chain, NewLocalCredentialVerifier(userRepo, hasher))
+		case "ldap":
+			chain = append(chain, NewLDAPCredentialVerifier(userRepo, roleRepo, hasher, audit))
+		default:
+			return nil, fmt.Errorf("不支持的认证后端: %s", backend)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("至少需要配置一个认证后端")
+	}
+	return chain, nil
+}
+
+// credentialChain 依次尝试各个后端，第一个认领该用户的后端决定校验结果
+type credentialChain []CredentialVerifier
+
+func (c credentialChain) Verify(username, password string) (*models.User, error) {
+	var unavailable error
+	for _, verifier := range c {
+		user, err := verifier.Verify(username, password)
+		switch {
+		case err == nil:
+			return user, nil
+		case errors.Is(err, ErrUnknownCredentialUser):
+			continue
+		case errors.Is(err, ErrAuthBackendUnavailable):
+			// 后端故障时继续尝试其他后端，其他后端也不认识该用户时才报告故障
+			log.Printf("认证后端校验失败: %v", err)
+			unavailable = err
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	if unavailable != nil {
+		return nil, ErrAuthBackendUnavailable
+	}
+	return nil, ErrUnknownCredentialUser
+}
+
+type localCredentialVerifier struct {
+	userRepo repositories.UserRepository
+	hasher   PasswordHasher
+}
+
+// NewLocalCredentialVerifier 使用数据库中保存的密码哈希校验，LDAP 账户不在此校验
+func NewLocalCredentialVerifier(userRepo repositories.UserRepository, hasher PasswordHasher) CredentialVerifier {
+	return &localCredentialVerifier{userRepo: userRepo, hasher: hasher}
+}
+
+func (v *localCredentialVerifier) Verify(username, password string) (*models.User, error) {
+	user, err := v.userRepo.FindByUsername(username)
+	if err != nil || user.AuthSource == models.AuthSourceLDAP {
+		return nil, ErrUnknownCredentialUser
+	}
+
+	valid, err := v.hasher.Verify(user.Password, password)
+	if err != nil || !valid {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 算法或参数变更、或仍为历史明文密码时，登录成功后透明地重新哈希
+	rehashPasswordIfNeeded(v.userRepo, v.hasher, user, password)
+	return user, nil
+}
+
+// rehashPasswordIfNeeded 使用当前哈希配置重新生成密码哈希，失败只记录日志不影响登录
+func rehashPasswordIfNeeded(userRepo repositories.UserRepository, hasher PasswordHasher, user *models.User, password string) {
+	if !hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	passwordHash, err := hasher.Hash(password)
+	if err != nil {
+		log.Printf("用户 %d 密码重新哈希失败: %v", user.ID, err)
+		return
+	}
+	if err := userRepo.UpdatePassword(user.ID, passwordHash); err != nil {
+		log.Printf("用户 %d 密码哈希升级失败: %v", user.ID, err)
+		return
+	}
+	user.Password = passwordHash
+}
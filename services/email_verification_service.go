@@ -0,0 +1,104 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+const emailVerificationPurpose = "email_verification"
+
+var ErrEmailNotVerified = errors.New("邮箱尚未验证，请先点击验证邮件中的链接")
+
+// emailVerificationData 验证令牌中携带的数据，邮箱变更后旧令牌自动失效
+type emailVerificationData struct {
+	UserID uint   `json:"uid"`
+	Email  string `json:"email"`
+}
+
+type EmailVerificationService interface {
+	SendVerification(user *models.User) error
+	Verify(token string) (*models.User, error)
+	Resend(email string)
+}
+
+type emailVerificationService struct {
+	userRepo repositories.UserRepository
+	mailer   Mailer
+	guard    LoginGuard
+}
+
+func NewEmailVerificationService(userRepo repositories.UserRepository, mailer Mailer, guard LoginGuard) EmailVerificationService {
+	return &emailVerificationService{userRepo: userRepo, mailer: mailer, guard: guard}
+}
+
+func (s *emailVerificationService) SendVerification(user *models.User) error {
+	token, err := utils.SignToken(emailVerificationPurpose, emailVerificationData{
+		UserID: user.ID,
+		Email:  user.Email,
+	}, config.AppConfig.EmailVerificationExpire)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/auth/verify-email?token=%s", config.AppConfig.AppBaseURL, url.QueryEscape(token))
+
+	return s.mailer.Send(MailMessage{
+		To:      user.Email,
+		Subject: "请验证您的邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n请在 %v 内点击以下链接完成邮箱验证：\n%s\n\n如果这不是您本人的操作，请忽略此邮件。\n",
+			user.Username, config.AppConfig.EmailVerificationExpire, link),
+	})
+}
+
+func (s *emailVerificationService) Verify(token string) (*models.User, error) {
+	var data emailVerificationData
+	if err := utils.VerifySignedToken(token, emailVerificationPurpose, &data); err != nil {
+		if errors.Is(err, utils.ErrSignedTokenExpired) {
+			return nil, errors.New("验证链接已过期，请重新发送验证邮件")
+		}
+		return nil, errors.New("验证链接无效")
+	}
+
+	user, err := s.userRepo.FindByID(data.UserID)
+	if err != nil || user.Email != data.Email {
+		return nil, errors.New("验证链接无效")
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.userRepo.FindByID(user.ID)
+}
+
+// Resend 重新发送验证邮件
+// 查询和发信在后台完成，无论邮箱是否存在调用方都立即返回，避免通过响应耗时泄露账户信息
+func (s *emailVerificationService) Resend(email string) {
+	go func() {
+		allowed, err := s.guard.AllowMailTo(email)
+		if err != nil {
+			log.Printf("检查验证邮件发送频率失败: %v", err)
+			return
+		}
+		if !allowed {
+			return
+		}
+
+		user, err := s.userRepo.FindByEmail(email)
+		if err != nil || user.EmailVerifiedAt != nil || user.Status != models.UserStatusPending {
+			return
+		}
+
+		if err := s.SendVerification(user); err != nil {
+			log.Printf("重新发送验证邮件给用户 %d 失败: %v", user.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"log"
+	"strings"
+)
+
+// unusablePasswordHash 为外部身份源创建的账户生成随机密码的哈希，账户无法使用本地密码登录
+// 单点登录创建的账户需要时可以通过忘记密码流程设置本地密码
+func unusablePasswordHash(hasher PasswordHasher) (string, error) {
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return hasher.Hash(randomPassword)
+}
+
+// mapGroupsToRole 按配置顺序返回第一个匹配用户组的角色，组名不区分大小写，忽略系统中不存在的角色
+func mapGroupsToRole(roleRepo repositories.RoleRepository, mappings []config.RoleMapping, groups []string) (models.UserRole, bool) {
+	for _, mapping := range mappings {
+		for _, group := range groups {
+			if !strings.EqualFold(group, mapping.Group) {
+				continue
+			}
+			role := models.UserRole(mapping.Role)
+			exists, err := roleRepo.ExistsByName(role)
+			if err != nil || !exists {
+				log.Printf("角色映射 %s=%s 指向不存在的角色，已忽略", mapping.Group, mapping.Role)
+				continue
+			}
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// syncGroupRole 登录时按外部身份源的用户组同步账户角色
+// 由该身份源创建的账户以身份源为准，不在任何映射组中时恢复默认角色；
+// 其他来源的账户只在命中映射时更新，避免覆盖管理员手工分配的角色；
+// 变更以身份源名义审计，审计记录无法写入时保留原角色
+func syncGroupRole(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, audit AuditService, user *models.User, groups []string, mappings []config.RoleMapping, defaultRole models.UserRole, source string) {
+	if len(mappings) == 0 {
+		return
+	}
+
+	role, matched := mapGroupsToRole(roleRepo, mappings, groups)
+	if !matched {
+		if user.AuthSource != source {
+			return
+		}
+		role = defaultRole
+	}
+	if role == user.Role {
+		return
+	}
+
+	if err := ensureAnotherAdmin(userRepo, user); err != nil {
+		log.Printf("用户 %d 的角色未按用户组同步: %v", user.ID, err)
+		return
+	}
+	if err := audit.RecordSecurity(SystemActor(source), models.AuditUserRoleAssign, models.AuditTargetUser, user.ID,
+		map[string]any{"role": user.Role}, map[string]any{"role": role}); err != nil {
+		log.Printf("用户 %d 的角色未按用户组同步: %v", user.ID, err)
+		return
+	}
+	if err := userRepo.UpdateRole(user.ID, role); err != nil {
+		log.Printf("用户 %d 的角色同步失败: %v", user.ID, err)
+		return
+	}
+	user.Role = role
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 创建迁移完毕并带有内置角色的内存 SQLite 数据库，测试期间替换 config.DB
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	// 内存数据库只对单个连接可见
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := config.MigrateDatabase(db); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	for _, role := range models.DefaultRoles() {
+		if err := db.Create(&role).Error; err != nil {
+			t.Fatalf("创建内置角色失败: %v", err)
+		}
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = previous })
+	return db
+}
+
+// setTestConfig 测试期间替换 config.AppConfig
+func setTestConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	if cfg.JWTSecret == "" {
+		cfg.JWTSecret = "test-secret"
+	}
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+// stubHasher 不做真实哈希的密码哈希器，避免测试受 Argon2 参数影响
+type stubHasher struct {
+	PasswordHasher
+}
+
+func (stubHasher) Hash(password string) (string, error) {
+	return "stub:" + password, nil
+}
+
+// stubAuthService 只实现 CompleteLogin，直接返回完成身份校验的用户
+type stubAuthService struct {
+	AuthService
+}
+
+func (stubAuthService) CompleteLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	return &LoginResult{User: user}, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+type ldapCredentialVerifier struct {
+	userRepo repositories.UserRepository
+	roleRepo repositories.RoleRepository
+	hasher   PasswordHasher
+	audit    AuditService
+}
+
+// NewLDAPCredentialVerifier 通过绑定 LDAP 目录校验密码，首次登录时自动创建本地账户
+func NewLDAPCredentialVerifier(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, hasher PasswordHasher, audit AuditService) CredentialVerifier {
+	return &ldapCredentialVerifier{userRepo: userRepo, roleRepo: roleRepo, hasher: hasher, audit: audit}
+}
+
+// ldapEntry 目录中查到的用户
+type ldapEntry struct {
+	DN     string
+	Email  string
+	Groups []string
+}
+
+func (v *ldapCredentialVerifier) Verify(username, password string) (*models.User, error) {
+	// 空密码会被服务器当作未认证绑定并返回成功，必须直接拒绝
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 本地账户不交给目录校验，避免同名的目录用户接管本地账户
+	if user, err := v.userRepo.FindByUsername(username); err == nil && user.AuthSource != models.AuthSourceLDAP {
+		return nil, ErrUnknownCredentialUser
+	}
+
+	entry, err := v.authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.provision(username, entry)
+}
+
+func (v *ldapCredentialVerifier) dial() (*ldap.Conn, error) {
+	cfg := config.AppConfig
+
+	serverURL, err := url.Parse(cfg.LDAPURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: LDAP_URL 无效: %v", ErrAuthBackendUnavailable, err)
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         serverURL.Hostname(),
+		InsecureSkipVerify: cfg.LDAPInsecureSkipVerify,
+	}
+
+	conn, err := ldap.DialURL(cfg.LDAPURL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: cfg.LDAPTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 连接 LDAP 失败: %v", ErrAuthBackendUnavailable, err)
+	}
+	conn.SetTimeout(cfg.LDAPTimeout)
+
+	if cfg.LDAPStartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: LDAP StartTLS 失败: %v", ErrAuthBackendUnavailable, err)
+		}
+	}
+	return conn, nil
+}
+
+// bindService 使用服务账号绑定，未配置时保持匿名
+func (v *ldapCredentialVerifier) bindService(conn *ldap.Conn) error {
+	cfg := config.AppConfig
+	if cfg.LDAPBindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(cfg.LDAPBindDN, cfg.LDAPBindPassword); err != nil {
+		return fmt.Errorf("%w: LDAP 服务账号绑定失败: %v", ErrAuthBackendUnavailable, err)
+	}
+	return nil
+}
+
+// authenticate 查找用户 DN 并以用户身份绑定校验密码，然后读取其所属的组
+func (v *ldapCredentialVerifier) authenticate(username, password string) (*ldapEntry, error) {
+	cfg := config.AppConfig
+
+	conn, err := v.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := v.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		cfg.LDAPBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(cfg.LDAPTimeout/time.Second), false,
+		fmt.Sprintf(cfg.LDAPUserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", cfg.LDAPEmailAttribute},
+		nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, ErrUnknownCredentialUser
+	}
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("%w: 查找 LDAP 用户失败: %v", ErrAuthBackendUnavailable, err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, ErrUnknownCredentialUser
+	}
+	if len(result.Entries) > 1 {
+		return nil, errors.New("LDAP 中存在多个匹配的用户，请联系管理员")
+	}
+
+	entry := &ldapEntry{
+		DN:    result.Entries[0].DN,
+		Email: result.Entries[0].GetAttributeValue(cfg.LDAPEmailAttribute),
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%w: LDAP 用户绑定失败: %v", ErrAuthBackendUnavailable, err)
+	}
+
+	// 未配置服务账号时以用户自身的身份查询组
+	if err := v.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	groups, err := conn.Search(ldap.NewSearchRequest(
+		cfg.LDAPGroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(cfg.LDAPTimeout/time.Second), false,
+		fmt.Sprintf(cfg.LDAPGroupFilter, ldap.EscapeFilter(entry.DN)),
+		[]string{cfg.LDAPGroupAttribute},
+		nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return entry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: 查询 LDAP 用户组失败: %v", ErrAuthBackendUnavailable, err)
+	}
+	for _, group := range groups.Entries {
+		if name := ldapGroupName(group, cfg.LDAPGroupAttribute); name != "" {
+			entry.Groups = append(entry.Groups, name)
+		}
+	}
+
+	return entry, nil
+}
+
+// ldapGroupName 读取组名属性，服务器未返回该属性时取 DN 的第一个 RDN 值
+func ldapGroupName(group *ldap.Entry, attribute string) string {
+	if name := group.GetAttributeValue(attribute); name != "" {
+		return name
+	}
+	dn, err := ldap.ParseDN(group.DN)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return ""
+	}
+	return dn.RDNs[0].Attributes[0].Value
+}
+
+// provision 首次登录时创建本地账户，之后每次登录按组同步角色
+func (v *ldapCredentialVerifier) provision(username string, entry *ldapEntry) (*models.User, error) {
+	cfg := config.AppConfig
+
+	if user, err := v.userRepo.FindByUsername(username); err == nil {
+		syncGroupRole(v.userRepo, v.roleRepo, v.audit, user, entry.Groups, cfg.LDAPRoleMapping,
+			models.UserRole(cfg.LDAPDefaultRole), models.AuthSourceLDAP)
+		return user, nil
+	}
+
+	email := strings.TrimSpace(entry.Email)
+	if email == "" {
+		return nil, errors.New("LDAP 账户缺少邮箱，无法创建账户，请联系管理员")
+	}
+
+	role, matched := mapGroupsToRole(v.roleRepo, cfg.LDAPRoleMapping, entry.Groups)
+	if !matched {
+		role = models.UserRole(cfg.LDAPDefaultRole)
+	}
+
+	passwordHash, err := unusablePasswordHash(v.hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	// 目录中的邮箱由管理员维护，视为已验证
+	verifiedAt := time.Now()
+	user := &models.User{
+		Username:        username,
+		Password:        passwordHash,
+		Email:           email,
+		EmailVerifiedAt: &verifiedAt,
+		Role:            role,
+		Status:          models.UserStatusActive,
+		AuthSource:      models.AuthSourceLDAP,
+	}
+	if err := createAuditedUser(v.userRepo, v.audit, SystemActor(models.AuthSourceLDAP), user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+	"gorm.io/gorm"
+)
+
+const (
+	testLDAPServiceDN       = "cn=svc,dc=example,dc=org"
+	testLDAPServicePassword = "svc-secret"
+	testLDAPPeopleDN        = "ou=people,dc=example,dc=org"
+	testLDAPGroupsDN        = "ou=groups,dc=example,dc=org"
+)
+
+type testLDAPUser struct {
+	password string
+	email    string
+}
+
+type testLDAPGroup struct {
+	members []string
+	// noNameAttribute 为 true 时不返回 cn 属性，校验方应从 DN 中取组名
+	noNameAttribute bool
+}
+
+// testDirectory 基于 gldap 的目录服务，按 uid 查找用户、按 member 查找组
+// 与常见的目录服务一样，空密码的绑定被当作未认证绑定并返回成功
+type testDirectory struct {
+	server *gldap.Server
+	url    string
+
+	mu     sync.Mutex
+	users  map[string]testLDAPUser
+	groups map[string]testLDAPGroup
+	// userBinds 收到的非空密码用户绑定请求的 DN
+	userBinds []string
+}
+
+func startTestDirectory(t *testing.T, users map[string]testLDAPUser, groups map[string]testLDAPGroup) *testDirectory {
+	t.Helper()
+
+	dir := &testDirectory{users: users, groups: groups}
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatalf("创建目录路由失败: %v", err)
+	}
+	mux.Bind(dir.bind)
+	mux.Search(dir.search)
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatalf("创建目录服务失败: %v", err)
+	}
+	server.Router(mux)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("分配端口失败: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	go server.Run(addr)
+	t.Cleanup(func() { server.Stop() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("目录服务启动超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	dir.server = server
+	dir.url = "ldap://" + addr
+	return dir
+}
+
+func testLDAPUserDN(uid string) string {
+	return "uid=" + uid + "," + testLDAPPeopleDN
+}
+
+func (d *testDirectory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer w.Write(resp)
+
+	m, err := r.GetSimpleBindMessage()
+	if err != nil {
+		return
+	}
+	password := string(m.Password)
+	if password == "" {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+	if m.UserName == testLDAPServiceDN {
+		if password == testLDAPServicePassword {
+			resp.SetResultCode(gldap.ResultSuccess)
+		}
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.userBinds = append(d.userBinds, m.UserName)
+	for uid, user := range d.users {
+		if testLDAPUserDN(uid) == m.UserName && user.password == password {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+	}
+}
+
+func (d *testDirectory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer w.Write(resp)
+
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		resp.SetResultCode(gldap.ResultOperationsError)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch m.BaseDN {
+	case testLDAPPeopleDN:
+		uid := filterValue(m.Filter, "uid")
+		if user, ok := d.users[uid]; ok {
+			attrs := map[string][]string{"uid": {uid}}
+			if user.email != "" {
+				attrs["mail"] = []string{user.email}
+			}
+			w.Write(r.NewSearchResponseEntry(testLDAPUserDN(uid), gldap.WithAttributes(attrs)))
+		}
+	case testLDAPGroupsDN:
+		member := filterValue(m.Filter, "member")
+		for name, group := range d.groups {
+			for _, uid := range group.members {
+				if testLDAPUserDN(uid) != member {
+					continue
+				}
+				attrs := map[string][]string{"cn": {name}}
+				if group.noNameAttribute {
+					attrs = map[string][]string{"objectClass": {"groupOfNames"}}
+				}
+				w.Write(r.NewSearchResponseEntry("cn="+name+","+testLDAPGroupsDN, gldap.WithAttributes(attrs)))
+			}
+		}
+	default:
+		resp.SetResultCode(gldap.ResultNoSuchObject)
+	}
+}
+
+// filterValue 取出形如 (attr=value) 的简单过滤条件中的值
+func filterValue(filter, attr string) string {
+	prefix := "(" + attr + "="
+	if !strings.HasPrefix(filter, prefix) || !strings.HasSuffix(filter, ")") {
+		return ""
+	}
+	return filter[len(prefix) : len(filter)-1]
+}
+
+func (d *testDirectory) boundUsers() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.userBinds...)
+}
+
+// newTestLDAPVerifier 创建连接测试目录的 LDAP 校验后端
+func newTestLDAPVerifier(t *testing.T, dir *testDirectory, mutate func(cfg *config.Config)) (CredentialVerifier, *gorm.DB) {
+	t.Helper()
+
+	cfg := &config.Config{
+		LDAPURL:            dir.url,
+		LDAPBindDN:         testLDAPServiceDN,
+		LDAPBindPassword:   testLDAPServicePassword,
+		LDAPBaseDN:         testLDAPPeopleDN,
+		LDAPUserFilter:     "(uid=%s)",
+		LDAPEmailAttribute: "mail",
+		LDAPGroupBaseDN:    testLDAPGroupsDN,
+		LDAPGroupFilter:    "(member=%s)",
+		LDAPGroupAttribute: "cn",
+		LDAPRoleMapping: []config.RoleMapping{
+			{Group: "library-staff", Role: string(models.RoleLibrarian)},
+			{Group: "it-admins", Role: string(models.RoleAdmin)},
+		},
+		LDAPDefaultRole: string(models.RoleUser),
+		LDAPTimeout:     5 * time.Second,
+	}
+	if mutate != nil {
+		mutate(cfg)
+	}
+	setTestConfig(t, cfg)
+	db := openTestDB(t)
+
+	return NewLDAPCredentialVerifier(repositories.NewUserRepository(), repositories.NewRoleRepository(), stubHasher{}, NewAuditService(repositories.NewAuditRepository())), db
+}
+
+func testLDAPDirectory(t *testing.T) *testDirectory {
+	return startTestDirectory(t,
+		map[string]testLDAPUser{
+			"alice": {password: "alice-pw", email: "alice@example.org"},
+			"bob":   {password: "bob-pw", email: "bob@example.org"},
+			"carol": {password: "carol-pw"},
+			"dave":  {password: "dave-pw", email: "dave@example.org"},
+		},
+		map[string]testLDAPGroup{
+			"library-staff": {members: []string{"alice"}},
+			"IT-Admins":     {members: []string{"bob"}, noNameAttribute: true},
+		},
+	)
+}
+
+func TestLDAPVerifierVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		// existing 登录前已存在的本地账户
+		existing *models.User
+		mutate   func(cfg *config.Config)
+		wantErr  error
+		// wantErrContains 无法用 errors.Is 判断时按错误信息匹配
+		wantErrContains string
+		// wantNoUserBind 为 true 时目录不应收到任何用户绑定请求
+		wantNoUserBind bool
+	}{
+		{name: "密码正确", username: "alice", password: "alice-pw"},
+		{name: "密码错误", username: "alice", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "目录中不存在的用户", username: "mallory", password: "pw", wantErr: ErrUnknownCredentialUser, wantNoUserBind: true},
+		{
+			// 目录会把空密码当作未认证绑定并返回成功
+			name: "拒绝空密码", username: "alice", password: "",
+			wantErr: ErrInvalidCredentials, wantNoUserBind: true,
+		},
+		{
+			name: "同名本地账户不交给目录校验", username: "alice", password: "alice-pw",
+			existing: &models.User{
+				Username: "alice", Email: "alice@local.test", Role: models.RoleAdmin,
+				Status: models.UserStatusActive, AuthSource: models.AuthSourceLocal,
+			},
+			wantErr: ErrUnknownCredentialUser, wantNoUserBind: true,
+		},
+		{
+			name: "服务账号绑定失败", username: "alice", password: "alice-pw",
+			mutate:  func(cfg *config.Config) { cfg.LDAPBindPassword = "wrong" },
+			wantErr: ErrAuthBackendUnavailable, wantNoUserBind: true,
+		},
+		{
+			name: "目录不可达", username: "alice", password: "alice-pw",
+			mutate:  func(cfg *config.Config) { cfg.LDAPURL = "ldap://127.0.0.1:1"; cfg.LDAPTimeout = time.Second },
+			wantErr: ErrAuthBackendUnavailable, wantNoUserBind: true,
+		},
+		{name: "目录账户缺少邮箱", username: "carol", password: "carol-pw", wantErrContains: "缺少邮箱"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testLDAPDirectory(t)
+			verifier, db := newTestLDAPVerifier(t, dir, tt.mutate)
+			if tt.existing != nil {
+				existing := *tt.existing
+				existing.Password = "stub:local-pw"
+				if err := db.Create(&existing).Error; err != nil {
+					t.Fatalf("创建本地账户失败: %v", err)
+				}
+			}
+
+			user, err := verifier.Verify(tt.username, tt.password)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("错误 = %v，期望 %v", err, tt.wantErr)
+				}
+			case tt.wantErrContains != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("错误 = %v，期望包含 %q", err, tt.wantErrContains)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("期望校验通过，实际返回 %v", err)
+				}
+				if user.AuthSource != models.AuthSourceLDAP || user.Email != "alice@example.org" || user.EmailVerifiedAt == nil {
+					t.Errorf("创建的账户 = %+v，期望来自 LDAP 且邮箱已验证", user)
+				}
+				var events int64
+				if err := db.Model(&models.AuditEvent{}).
+					Where("action = ? AND actor_name = ? AND target_id = ?", models.AuditUserCreate, models.AuthSourceLDAP, fmt.Sprint(user.ID)).
+					Count(&events).Error; err != nil || events != 1 {
+					t.Errorf("账户创建审计记录数 = %d (%v)，期望 1", events, err)
+				}
+			}
+
+			if tt.wantNoUserBind {
+				if binds := dir.boundUsers(); len(binds) > 0 {
+					t.Errorf("目录收到了用户绑定请求: %v", binds)
+				}
+			}
+
+			if tt.existing != nil {
+				var stored models.User
+				if err := db.Where("username = ?", tt.existing.Username).First(&stored).Error; err != nil {
+					t.Fatalf("查询本地账户失败: %v", err)
+				}
+				if stored.AuthSource != tt.existing.AuthSource || stored.Role != tt.existing.Role || stored.Email != tt.existing.Email {
+					t.Errorf("本地账户被修改: %+v", stored)
+				}
+			}
+		})
+	}
+}
+
+func TestLDAPVerifierGroupRoleMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		// existingRole 不为空时登录前已存在同名的 LDAP 账户
+		existingRole models.UserRole
+		wantRole     models.UserRole
+	}{
+		{name: "新账户按用户组分配角色", username: "alice", password: "alice-pw", wantRole: models.RoleLibrarian},
+		{name: "组名取自 DN 且不区分大小写", username: "bob", password: "bob-pw", wantRole: models.RoleAdmin},
+		{name: "已有账户按用户组升级", username: "alice", password: "alice-pw", existingRole: models.RoleUser, wantRole: models.RoleLibrarian},
+		{name: "已有账户按用户组变更角色", username: "bob", password: "bob-pw", existingRole: models.RoleLibrarian, wantRole: models.RoleAdmin},
+		{name: "已有账户移出所有映射组后恢复默认角色", username: "dave", password: "dave-pw", existingRole: models.RoleLibrarian, wantRole: models.RoleUser},
+		{name: "不在映射组中的新账户使用默认角色", username: "dave", password: "dave-pw", wantRole: models.RoleUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testLDAPDirectory(t)
+			verifier, db := newTestLDAPVerifier(t, dir, nil)
+			if tt.existingRole != "" {
+				existing := &models.User{
+					Username: tt.username, Password: "stub:unusable", Email: fmt.Sprintf("%s@example.org", tt.username),
+					Role: tt.existingRole, Status: models.UserStatusActive, AuthSource: models.AuthSourceLDAP,
+				}
+				if err := db.Create(existing).Error; err != nil {
+					t.Fatalf("创建已有账户失败: %v", err)
+				}
+			}
+
+			user, err := verifier.Verify(tt.username, tt.password)
+			if err != nil {
+				t.Fatalf("校验失败: %v", err)
+			}
+			if user.Role != tt.wantRole {
+				t.Errorf("角色 = %s，期望 %s", user.Role, tt.wantRole)
+			}
+
+			var stored models.User
+			if err := db.First(&stored, user.ID).Error; err != nil {
+				t.Fatalf("查询账户失败: %v", err)
+			}
+			if stored.Role != tt.wantRole {
+				t.Errorf("数据库中的角色 = %s，期望 %s", stored.Role, tt.wantRole)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAccountLocked 账户因连续登录失败被临时锁定
+type ErrAccountLocked struct {
+	RetryAfter time.Duration
+}
+
+func (e *ErrAccountLocked) Error() string {
+	return fmt.Sprintf("登录失败次数过多，账户已被临时锁定，请在 %v 后重试", e.RetryAfter.Round(time.Second))
+}
+
+// LoginGuard 登录暴力破解防护
+// 按账户统计连续失败次数并渐进式锁定，按 IP 限制登录、注册等接口的请求频率，
+// 按收件地址限制找回密码、验证邮件的发送次数
+type LoginGuard interface {
+	CheckAccount(username string) error
+	RecordFailure(username string) error
+	RecordSuccess(username string) error
+	UnlockAccount(username string) error
+	AllowIP(action, ip string) (bool, time.Duration, error)
+	AllowMailTo(email string) (bool, error)
+}
+
+// IPLimit 单个 IP 在一个时间窗口内允许的请求次数
+type IPLimit struct {
+	MaxRequests int
+	Window      time.Duration
+}
+
+type loginGuard struct {
+	// 计数器的读取-修改-写入需要串行执行，避免并发请求绕过阈值
+	mu          sync.Mutex
+	attemptRepo repositories.LoginAttemptRepository
+	maxFailures int
+	lockoutBase time.Duration
+	lockoutMax  time.Duration
+	ipLimits    map[string]IPLimit
+	mailLimit   IPLimit
+}
+
+// NewLoginGuard 使用配置中的阈值创建登录防护
+func NewLoginGuard(attemptRepo repositories.LoginAttemptRepository) LoginGuard {
+	cfg := config.AppConfig
+	return &loginGuard{
+		attemptRepo: attemptRepo,
+		maxFailures: cfg.LoginMaxFailedAttempts,
+		lockoutBase: cfg.LoginLockoutBase,
+		lockoutMax:  cfg.LoginLockoutMax,
+		ipLimits: map[string]IPLimit{
+			"login":    {MaxRequests: cfg.LoginIPMaxRequests, Window: cfg.IPThrottleWindow},
+			"register": {MaxRequests: cfg.RegisterIPMaxRequests, Window: cfg.IPThrottleWindow},
+			"mail":     {MaxRequests: cfg.MailIPMaxRequests, Window: cfg.IPThrottleWindow},
+		},
+		mailLimit: IPLimit{MaxRequests: cfg.MailRecipientMax, Window: cfg.MailRecipientWindow},
+	}
+}
+
+func accountKey(username string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// CheckAccount 账户处于锁定期时返回 ErrAccountLocked
+// 不存在的用户名同样会被计数和锁定，避免通过锁定行为探测账户是否存在
+func (g *loginGuard) CheckAccount(username string) error {
+	attempt, err := g.attemptRepo.Get(accountKey(username))
+	if err != nil || attempt == nil || attempt.LockedUntil == nil {
+		return err
+	}
+
+	if remaining := time.Until(*attempt.LockedUntil); remaining > 0 {
+		return &ErrAccountLocked{RetryAfter: remaining}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次登录失败
+// 达到阈值后每次失败都会锁定账户，锁定时长从基础时长开始逐次翻倍，直至上限
+func (g *loginGuard) RecordFailure(username string) error {
+	if g.maxFailures <= 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := accountKey(username)
+	attempt, err := g.attemptRepo.Get(key)
+	if err != nil {
+		return err
+	}
+	if attempt == nil {
+		attempt = &models.LoginAttempt{Key: key, WindowStart: time.Now()}
+	}
+
+	attempt.Count++
+	if attempt.Count >= g.maxFailures {
+		lockout := g.lockoutBase
+		for i := g.maxFailures; i < attempt.Count && lockout < g.lockoutMax; i++ {
+			lockout *= 2
+		}
+		if lockout > g.lockoutMax {
+			lockout = g.lockoutMax
+		}
+		lockedUntil := time.Now().Add(lockout)
+		attempt.LockedUntil = &lockedUntil
+	}
+
+	return g.attemptRepo.Save(attempt)
+}
+
+// RecordSuccess 登录成功后清零失败计数
+func (g *loginGuard) RecordSuccess(username string) error {
+	return g.attemptRepo.Delete(accountKey(username))
+}
+
+// UnlockAccount 管理员手动解除锁定并清零失败计数
+func (g *loginGuard) UnlockAccount(username string) error {
+	return g.attemptRepo.Delete(accountKey(username))
+}
+
+// AllowIP 按固定时间窗口统计 IP 的请求次数，超出限制时返回需要等待的时长
+func (g *loginGuard) AllowIP(action, ip string) (bool, time.Duration, error) {
+	limit, ok := g.ipLimits[action]
+	if !ok {
+		return true, 0, nil
+	}
+	return g.allow("ip:"+action+":"+ip, limit)
+}
+
+// AllowMailTo 统计发往同一地址的邮件数，超出限制时调用方应静默跳过发送
+func (g *loginGuard) AllowMailTo(email string) (bool, error) {
+	allowed, _, err := g.allow("mail:"+strings.ToLower(strings.TrimSpace(email)), g.mailLimit)
+	return allowed, err
+}
+
+func (g *loginGuard) allow(key string, limit IPLimit) (bool, time.Duration, error) {
+	if limit.MaxRequests <= 0 {
+		return true, 0, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	attempt, err := g.attemptRepo.Get(key)
+	if err != nil {
+		return false, 0, err
+	}
+
+	now := time.Now()
+	if attempt == nil || now.Sub(attempt.WindowStart) >= limit.Window {
+		attempt = &models.LoginAttempt{Key: key, WindowStart: now}
+	}
+
+	if attempt.Count >= limit.MaxRequests {
+		return false, attempt.WindowStart.Add(limit.Window).Sub(now), nil
+	}
+
+	attempt.Count++
+	if err := g.attemptRepo.Save(attempt); err != nil {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"book-management-system/repositories"
+	"errors"
+	"testing"
+	"time"
+)
+
+// loginAttemptStores 登录防护的两种计数存储，数据库实现使用内存中的 SQLite，不依赖外部服务
+var loginAttemptStores = []struct {
+	name string
+	open func(t *testing.T) repositories.LoginAttemptRepository
+}{
+	{"memory", func(t *testing.T) repositories.LoginAttemptRepository {
+		return repositories.NewMemoryLoginAttemptRepository()
+	}},
+	{"database", func(t *testing.T) repositories.LoginAttemptRepository {
+		openTestDB(t)
+		return repositories.NewDatabaseLoginAttemptRepository()
+	}},
+}
+
+func newTestLoginGuard(repo repositories.LoginAttemptRepository) *loginGuard {
+	return &loginGuard{
+		attemptRepo: repo,
+		maxFailures: 3,
+		lockoutBase: time.Minute,
+		lockoutMax:  4 * time.Minute,
+		ipLimits: map[string]IPLimit{
+			"login": {MaxRequests: 2, Window: time.Minute},
+		},
+	}
+}
+
+// expireLockout 把账户的锁定截止时间改到过去，模拟锁定期已结束
+func expireLockout(t *testing.T, g *loginGuard, username string) {
+	t.Helper()
+
+	attempt, err := g.attemptRepo.Get(accountKey(username))
+	if err != nil || attempt == nil || attempt.LockedUntil == nil {
+		t.Fatalf("账户 %s 没有处于锁定状态: %v", username, err)
+	}
+	past := time.Now().Add(-time.Second)
+	attempt.LockedUntil = &past
+	if err := g.attemptRepo.Save(attempt); err != nil {
+		t.Fatalf("保存登录计数失败: %v", err)
+	}
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		// after 在记录失败之后执行，用于解锁、登录成功或让锁定过期
+		after func(t *testing.T, g *loginGuard)
+		// checkAs 检查锁定状态时使用的用户名，为空时与记录失败的用户名相同
+		checkAs string
+		// wantLockout 期望的剩余锁定时长，0 表示未锁定
+		wantLockout time.Duration
+	}{
+		{name: "未达到阈值不锁定", failures: 2},
+		{name: "达到阈值后锁定", failures: 3, wantLockout: time.Minute},
+		{name: "超过阈值后锁定时长翻倍", failures: 4, wantLockout: 2 * time.Minute},
+		{name: "锁定时长不超过上限", failures: 8, wantLockout: 4 * time.Minute},
+		{name: "用户名不区分大小写", failures: 3, checkAs: " ALICE ", wantLockout: time.Minute},
+		{
+			name:     "管理员解锁后立即可以登录",
+			failures: 3,
+			after: func(t *testing.T, g *loginGuard) {
+				if err := g.UnlockAccount("alice"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:     "解锁后失败次数重新计算",
+			failures: 5,
+			after: func(t *testing.T, g *loginGuard) {
+				if err := g.UnlockAccount("alice"); err != nil {
+					t.Fatal(err)
+				}
+				if err := g.RecordFailure("alice"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:     "登录成功清零失败次数",
+			failures: 2,
+			after: func(t *testing.T, g *loginGuard) {
+				if err := g.RecordSuccess("alice"); err != nil {
+					t.Fatal(err)
+				}
+				if err := g.RecordFailure("alice"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:     "锁定到期后自动解除",
+			failures: 3,
+			after: func(t *testing.T, g *loginGuard) {
+				expireLockout(t, g, "alice")
+			},
+		},
+		{
+			name:     "锁定到期后再次失败按翻倍时长重新锁定",
+			failures: 3,
+			after: func(t *testing.T, g *loginGuard) {
+				expireLockout(t, g, "alice")
+				if err := g.RecordFailure("alice"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantLockout: 2 * time.Minute,
+		},
+	}
+
+	for _, store := range loginAttemptStores {
+		for _, tt := range tests {
+			t.Run(store.name+"/"+tt.name, func(t *testing.T) {
+				g := newTestLoginGuard(store.open(t))
+
+				for i := 0; i < tt.failures; i++ {
+					if err := g.RecordFailure("alice"); err != nil {
+						t.Fatalf("记录登录失败出错: %v", err)
+					}
+				}
+				if tt.after != nil {
+					tt.after(t, g)
+				}
+
+				username := tt.checkAs
+				if username == "" {
+					username = "alice"
+				}
+				err := g.CheckAccount(username)
+
+				if tt.wantLockout == 0 {
+					if err != nil {
+						t.Fatalf("期望未锁定，实际返回 %v", err)
+					}
+					return
+				}
+
+				var locked *ErrAccountLocked
+				if !errors.As(err, &locked) {
+					t.Fatalf("期望 ErrAccountLocked，实际返回 %v", err)
+				}
+				if locked.RetryAfter > tt.wantLockout || locked.RetryAfter < tt.wantLockout-5*time.Second {
+					t.Errorf("剩余锁定时长 = %v，期望约为 %v", locked.RetryAfter, tt.wantLockout)
+				}
+			})
+		}
+	}
+}
+
+func TestLoginGuardLockoutIsPerAccount(t *testing.T) {
+	for _, store := range loginAttemptStores {
+		t.Run(store.name, func(t *testing.T) {
+			g := newTestLoginGuard(store.open(t))
+
+			for i := 0; i < 3; i++ {
+				if err := g.RecordFailure("alice"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := g.CheckAccount("bob"); err != nil {
+				t.Fatalf("其他账户不应被锁定: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginGuardAllowIP(t *testing.T) {
+	for _, store := range loginAttemptStores {
+		t.Run(store.name, func(t *testing.T) {
+			g := newTestLoginGuard(store.open(t))
+
+			for i, want := range []bool{true, true, false} {
+				allowed, retryAfter, err := g.AllowIP("login", "10.0.0.1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if allowed != want {
+					t.Fatalf("第 %d 次请求 allowed = %v，期望 %v", i+1, allowed, want)
+				}
+				if !allowed && (retryAfter <= 0 || retryAfter > time.Minute) {
+					t.Errorf("retryAfter = %v，期望在 (0, 1m] 之间", retryAfter)
+				}
+			}
+
+			if allowed, _, err := g.AllowIP("login", "10.0.0.2"); err != nil || !allowed {
+				t.Errorf("其他 IP 不应受影响: allowed=%v err=%v", allowed, err)
+			}
+			if allowed, _, err := g.AllowIP("unknown", "10.0.0.1"); err != nil || !allowed {
+				t.Errorf("未配置限制的操作应放行: allowed=%v err=%v", allowed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"book-management-system/config"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MailMessage 待发送的邮件
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送抽象，生产环境使用SMTP，开发和测试环境可写入日志或文件
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// NewMailer 根据配置创建邮件发送器
+func NewMailer() Mailer {
+	cfg := config.AppConfig
+	switch cfg.MailerDriver {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "file":
+		return NewFileMailer(cfg.MailFilePath, cfg.MailFrom)
+	default:
+		return NewLogMailer(cfg.MailFrom)
+	}
+}
+
+// ---------------- SMTP ----------------
+
+type smtpMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, port),
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *smtpMailer) Send(msg MailMessage) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, buildMIMEMessage(m.from, msg)); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
+
+// ---------------- 日志 ----------------
+
+type logMailer struct {
+	from string
+}
+
+// NewLogMailer 将邮件内容输出到日志，仅用于开发环境
+func NewLogMailer(from string) Mailer {
+	return &logMailer{from: from}
+}
+
+func (m *logMailer) Send(msg MailMessage) error {
+	log.Printf("[mail] from=%s to=%s subject=%q\n%s", m.from, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// ---------------- 文件 ----------------
+
+type fileMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+// NewFileMailer 将邮件追加写入文件，便于测试环境读取验证链接
+func NewFileMailer(path, from string) Mailer {
+	return &fileMailer{path: path, from: from}
+}
+
+func (m *fileMailer) Send(msg MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开邮件文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(buildMIMEMessage(m.from, msg), "\r\n\r\n"...)); err != nil {
+		return fmt.Errorf("写入邮件文件失败: %w", err)
+	}
+	return nil
+}
+
+func buildMIMEMessage(from string, msg MailMessage) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
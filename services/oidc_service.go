@@ -0,0 +1,350 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStatePurpose = "oidc_state"
+	oidcStateExpire  = 10 * time.Minute
+)
+
+var (
+	ErrOIDCDisabled       = errors.New("未启用单点登录")
+	ErrInvalidOIDCState   = errors.New("登录请求已失效，请重新发起单点登录")
+	ErrOIDCEmailRequired  = errors.New("身份提供方未返回已验证的邮箱，无法关联账户")
+	ErrOIDCNotProvisioned = errors.New("该邮箱尚未在系统中注册，请联系管理员开通账户")
+)
+
+// oidcStateData 发起登录时保存在浏览器 Cookie 中的数据，经过签名防止篡改
+type oidcStateData struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"` // PKCE code_verifier
+}
+
+// OIDCAuthorization 跳转到身份提供方所需的信息
+type OIDCAuthorization struct {
+	URL         string
+	StateCookie string
+	ExpiresIn   time.Duration
+}
+
+// OIDCIdentity 从 ID Token 中解析出的用户身份
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+// OIDCService OpenID Connect 授权码 + PKCE 登录
+type OIDCService interface {
+	Enabled() bool
+	BeginLogin(ctx context.Context) (*OIDCAuthorization, error)
+	CompleteLogin(ctx context.Context, stateCookie, state, code string, client ClientInfo) (*LoginResult, error)
+}
+
+type oidcService struct {
+	userRepo repositories.UserRepository
+	roleRepo repositories.RoleRepository
+	hasher   PasswordHasher
+	auth     AuthService
+	audit    AuditService
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOIDCService(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, hasher PasswordHasher, auth AuthService, audit AuditService) OIDCService {
+	return &oidcService{
+		userRepo: userRepo,
+		roleRepo: roleRepo,
+		hasher:   hasher,
+		auth:     auth,
+		audit:    audit,
+	}
+}
+
+func (s *oidcService) Enabled() bool {
+	return config.AppConfig.OIDCEnabled
+}
+
+// client 首次使用时通过 issuer 的发现文档初始化客户端，身份提供方暂时不可用不影响服务启动
+func (s *oidcService) client(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrOIDCDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.oauth != nil {
+		return s.oauth, s.verifier, nil
+	}
+
+	cfg := config.AppConfig
+	provider, err := oidc.NewProvider(ctx, cfg.OIDCIssuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取身份提供方配置失败: %w", err)
+	}
+
+	s.oauth = &oauth2.Config{
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       cfg.OIDCScopes,
+	}
+	s.verifier = provider.Verifier(&oidc.Config{ClientID: cfg.OIDCClientID})
+	return s.oauth, s.verifier, nil
+}
+
+// BeginLogin 生成 state、nonce 和 PKCE 校验码，返回身份提供方的授权地址
+func (s *oidcService) BeginLogin(ctx context.Context) (*OIDCAuthorization, error) {
+	oauthConfig, _, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	data := oidcStateData{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}
+
+	cookie, err := utils.SignToken(oidcStatePurpose, data, oidcStateExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorization{
+		URL:         oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(data.Verifier)),
+		StateCookie: cookie,
+		ExpiresIn:   oidcStateExpire,
+	}, nil
+}
+
+// CompleteLogin 校验回调的 state，用授权码换取并校验 ID Token，然后关联或创建本地账户并完成登录
+func (s *oidcService) CompleteLogin(ctx context.Context, stateCookie, state, code string, client ClientInfo) (*LoginResult, error) {
+	oauthConfig, verifier, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var data oidcStateData
+	if err := utils.VerifySignedToken(stateCookie, oidcStatePurpose, &data); err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(data.State)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(data.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("授权码换取令牌失败: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("身份提供方未返回 ID Token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("ID Token 校验失败: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(data.Nonce)) != 1 {
+		return nil, errors.New("ID Token 校验失败: nonce 不匹配")
+	}
+
+	identity, err := parseOIDCIdentity(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.auth.CompleteLogin(user, client)
+}
+
+// parseOIDCIdentity 读取标准声明以及配置的用户组声明
+func parseOIDCIdentity(idToken *oidc.IDToken) (*OIDCIdentity, error) {
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     any    `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("解析 ID Token 失败: %w", err)
+	}
+
+	var all map[string]any
+	if err := idToken.Claims(&all); err != nil {
+		return nil, fmt.Errorf("解析 ID Token 失败: %w", err)
+	}
+
+	identity := &OIDCIdentity{
+		Subject:  idToken.Subject,
+		Email:    strings.TrimSpace(claims.Email),
+		Username: claims.PreferredUsername,
+		Groups:   claimStrings(all[config.AppConfig.OIDCGroupsClaim]),
+	}
+
+	// 部分身份提供方以字符串形式返回 email_verified
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = strings.EqualFold(v, "true")
+	}
+
+	return identity, nil
+}
+
+// claimStrings 将字符串数组或单个字符串形式的声明转换为字符串切片
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// resolveUser 按身份标识查找已关联的账户，其次按已验证的邮箱关联现有账户，最后按配置自动创建账户
+func (s *oidcService) resolveUser(identity *OIDCIdentity) (*models.User, error) {
+	if user, err := s.userRepo.FindByOIDCSubject(identity.Subject); err == nil {
+		s.syncRole(user, identity.Groups)
+		return user, nil
+	}
+
+	// 未经身份提供方验证的邮箱可能被任意填写，不能用来关联已有账户
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	if user, err := s.userRepo.FindByEmail(identity.Email); err == nil {
+		if user.OIDCSubject != nil {
+			return nil, errors.New("该邮箱对应的账户已关联其他单点登录身份")
+		}
+		if err := s.userRepo.LinkOIDCSubject(user.ID, identity.Subject); err != nil {
+			return nil, fmt.Errorf("关联账户失败: %w", err)
+		}
+		if user.EmailVerifiedAt == nil {
+			if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+				return nil, err
+			}
+		}
+		user, err = s.userRepo.FindByID(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		s.syncRole(user, identity.Groups)
+		return user, nil
+	}
+
+	if !config.AppConfig.OIDCAutoProvision {
+		return nil, ErrOIDCNotProvisioned
+	}
+
+	return s.provision(identity)
+}
+
+// provision 为首次登录的用户创建账户，账户没有可用的本地密码
+func (s *oidcService) provision(identity *OIDCIdentity) (*models.User, error) {
+	role, matched := mapGroupsToRole(s.roleRepo, config.AppConfig.OIDCRoleMapping, identity.Groups)
+	if !matched {
+		role = models.UserRole(config.AppConfig.OIDCDefaultRole)
+	}
+
+	passwordHash, err := unusablePasswordHash(s.hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := s.availableUsername(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := identity.Subject
+	verifiedAt := time.Now()
+	user := &models.User{
+		Username:        username,
+		Password:        passwordHash,
+		Email:           identity.Email,
+		EmailVerifiedAt: &verifiedAt,
+		Role:            role,
+		Status:          models.UserStatusActive,
+		AuthSource:      models.AuthSourceOIDC,
+		OIDCSubject:     &subject,
+	}
+	if err := createAuditedUser(s.userRepo, s.audit, SystemActor(models.AuthSourceOIDC), user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// syncRole 每次登录时按用户组同步角色，认证中间件每次请求都从数据库读取角色，已签发的令牌随即按新角色授权
+func (s *oidcService) syncRole(user *models.User, groups []string) {
+	syncGroupRole(s.userRepo, s.roleRepo, s.audit, user, groups, config.AppConfig.OIDCRoleMapping,
+		models.UserRole(config.AppConfig.OIDCDefaultRole), models.AuthSourceOIDC)
+}
+
+var usernameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// availableUsername 优先使用 preferred_username，其次使用邮箱前缀，重名时追加数字后缀
+func (s *oidcService) availableUsername(identity *OIDCIdentity) (string, error) {
+	base := usernameSanitizer.ReplaceAllString(identity.Username, "")
+	if len(base) < 3 {
+		local, _, _ := strings.Cut(identity.Email, "@")
+		base = usernameSanitizer.ReplaceAllString(local, "")
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 2; i <= 100; i++ {
+		if _, err := s.userRepo.FindByUsername(candidate); err != nil {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("无法生成可用的用户名")
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClientID = "library"
+	testOIDCKeyID    = "test-key"
+)
+
+// mockIdP 提供发现文档、JWKS 和令牌端点的模拟身份提供方
+// 授权端点由 authorize 直接模拟，授权码与授权请求中的 PKCE challenge 和 nonce 绑定
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成签名密钥失败: %v", err)
+	}
+	idp := &mockIdP{key: key, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (p *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testOIDCKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// token 授权码只能使用一次，code_verifier 必须与授权请求中的 S256 challenge 对应
+func (p *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	digest := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(digest[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   testOIDCClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": grant.nonce,
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = testOIDCKeyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// authorize 模拟用户在身份提供方同意授权，返回授权码；claims 中的 nonce 会覆盖授权请求中的值
+func (p *mockIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("解析授权地址失败: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("授权请求未使用 S256 PKCE: %s", authURL)
+	}
+
+	grant := mockGrant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		claims:    claims,
+	}
+	if nonce, ok := claims["nonce"].(string); ok {
+		grant.nonce = nonce
+	}
+
+	code := "code-" + query.Get("state")
+	p.mu.Lock()
+	p.grants[code] = grant
+	p.mu.Unlock()
+	return code
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// newTestOIDCService 创建连接模拟身份提供方的单点登录服务
+func newTestOIDCService(t *testing.T, idp *mockIdP, mappings []config.RoleMapping) (OIDCService, *gorm.DB) {
+	t.Helper()
+
+	setTestConfig(t, &config.Config{
+		OIDCEnabled:       true,
+		OIDCIssuer:        idp.server.URL,
+		OIDCClientID:      testOIDCClientID,
+		OIDCClientSecret:  "client-secret",
+		OIDCRedirectURL:   "http://localhost:8080/api/auth/oidc/callback",
+		OIDCScopes:        []string{"openid", "email", "profile"},
+		OIDCGroupsClaim:   "groups",
+		OIDCRoleMapping:   mappings,
+		OIDCDefaultRole:   string(models.RoleUser),
+		OIDCAutoProvision: true,
+	})
+	db := openTestDB(t)
+
+	return NewOIDCService(repositories.NewUserRepository(), repositories.NewRoleRepository(), stubHasher{}, stubAuthService{}, NewAuditService(repositories.NewAuditRepository())), db
+}
+
+// beginTestLogin 发起单点登录，返回授权信息和授权地址中的 state
+func beginTestLogin(t *testing.T, svc OIDCService) (*OIDCAuthorization, string) {
+	t.Helper()
+
+	auth, err := svc.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("发起单点登录失败: %v", err)
+	}
+	u, err := url.Parse(auth.URL)
+	if err != nil {
+		t.Fatalf("解析授权地址失败: %v", err)
+	}
+	return auth, u.Query().Get("state")
+}
+
+func aliceClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":                "alice-subject",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+}
+
+func TestOIDCCompleteLoginValidatesCallback(t *testing.T) {
+	tests := []struct {
+		name string
+		// callback 返回回调请求中的 state Cookie、state 参数和授权码
+		callback func(t *testing.T, svc OIDCService, idp *mockIdP) (cookie, state, code string)
+		wantErr  error
+		// wantErrContains 无法用 errors.Is 判断时按错误信息匹配
+		wantErrContains string
+	}{
+		{
+			name: "校验通过",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				auth, state := beginTestLogin(t, svc)
+				return auth.StateCookie, state, idp.authorize(t, auth.URL, aliceClaims())
+			},
+		},
+		{
+			name: "state 不匹配",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				auth, _ := beginTestLogin(t, svc)
+				return auth.StateCookie, "forged-state", idp.authorize(t, auth.URL, aliceClaims())
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name: "缺少 state",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				auth, _ := beginTestLogin(t, svc)
+				return auth.StateCookie, "", idp.authorize(t, auth.URL, aliceClaims())
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name: "state Cookie 被篡改",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				auth, state := beginTestLogin(t, svc)
+				return auth.StateCookie + "x", state, idp.authorize(t, auth.URL, aliceClaims())
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name: "state Cookie 来自另一次登录",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				other, _ := beginTestLogin(t, svc)
+				auth, state := beginTestLogin(t, svc)
+				return other.StateCookie, state, idp.authorize(t, auth.URL, aliceClaims())
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name: "nonce 不匹配",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				auth, state := beginTestLogin(t, svc)
+				claims := aliceClaims()
+				claims["nonce"] = "replayed-nonce"
+				return auth.StateCookie, state, idp.authorize(t, auth.URL, claims)
+			},
+			wantErrContains: "nonce 不匹配",
+		},
+		{
+			// 攻击者把为自己的授权请求签发的授权码注入受害者的回调
+			name: "PKCE 校验码不匹配",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				victim, state := beginTestLogin(t, svc)
+				attacker, _ := beginTestLogin(t, svc)
+				return victim.StateCookie, state, idp.authorize(t, attacker.URL, aliceClaims())
+			},
+			wantErrContains: "授权码换取令牌失败",
+		},
+		{
+			name: "授权码重放",
+			callback: func(t *testing.T, svc OIDCService, idp *mockIdP) (string, string, string) {
+				auth, state := beginTestLogin(t, svc)
+				code := idp.authorize(t, auth.URL, aliceClaims())
+				if _, err := svc.CompleteLogin(context.Background(), auth.StateCookie, state, code, ClientInfo{}); err != nil {
+					t.Fatalf("首次使用授权码失败: %v", err)
+				}
+				return auth.StateCookie, state, code
+			},
+			wantErrContains: "授权码换取令牌失败",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			svc, _ := newTestOIDCService(t, idp, nil)
+
+			cookie, state, code := tt.callback(t, svc, idp)
+			result, err := svc.CompleteLogin(context.Background(), cookie, state, code, ClientInfo{})
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("错误 = %v，期望 %v", err, tt.wantErr)
+				}
+			case tt.wantErrContains != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("错误 = %v，期望包含 %q", err, tt.wantErrContains)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("期望登录成功，实际返回 %v", err)
+				}
+				if result.User.Email != "alice@example.com" || result.User.AuthSource != models.AuthSourceOIDC {
+					t.Errorf("登录用户 = %+v，期望通过单点登录创建的 alice", result.User)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCGroupRoleMapping(t *testing.T) {
+	mappings := []config.RoleMapping{
+		{Group: "library-staff", Role: string(models.RoleLibrarian)},
+		{Group: "it-admins", Role: string(models.RoleAdmin)},
+		{Group: "ghosts", Role: "phantom"},
+	}
+	subject := "alice-subject"
+
+	tests := []struct {
+		name string
+		// existing 登录前已存在的账户，为空表示首次登录自动创建
+		existing *models.User
+		groups   any
+		// auditUnavailable 登录前删除审计表，模拟审计日志无法写入
+		auditUnavailable bool
+		wantRole         models.UserRole
+	}{
+		{name: "新账户按用户组分配角色", groups: []string{"library-staff"}, wantRole: models.RoleLibrarian},
+		{name: "组名不区分大小写", groups: []string{"IT-Admins"}, wantRole: models.RoleAdmin},
+		{name: "单个字符串形式的用户组", groups: "it-admins", wantRole: models.RoleAdmin},
+		{name: "多个匹配时按配置顺序取第一个", groups: []string{"it-admins", "library-staff"}, wantRole: models.RoleLibrarian},
+		{name: "没有匹配的用户组使用默认角色", groups: []string{"students"}, wantRole: models.RoleUser},
+		{name: "映射到不存在的角色被忽略", groups: []string{"ghosts"}, wantRole: models.RoleUser},
+		{
+			name: "单点登录账户移出用户组后恢复默认角色",
+			existing: &models.User{
+				Username: "alice", Email: "alice@example.com", Role: models.RoleLibrarian,
+				Status: models.UserStatusActive, AuthSource: models.AuthSourceOIDC, OIDCSubject: &subject,
+			},
+			groups:   []string{"students"},
+			wantRole: models.RoleUser,
+		},
+		{
+			name: "本地账户首次关联时按用户组升级",
+			existing: &models.User{
+				Username: "alice", Email: "alice@example.com", Role: models.RoleUser,
+				Status: models.UserStatusActive, AuthSource: models.AuthSourceLocal,
+			},
+			groups:   []string{"library-staff"},
+			wantRole: models.RoleLibrarian,
+		},
+		{
+			name: "本地账户不在映射组中时保留手工分配的角色",
+			existing: &models.User{
+				Username: "alice", Email: "alice@example.com", Role: models.RoleLibrarian,
+				Status: models.UserStatusActive, AuthSource: models.AuthSourceLocal,
+			},
+			groups:   []string{"students"},
+			wantRole: models.RoleLibrarian,
+		},
+		{
+			name: "审计日志无法写入时不同步角色",
+			existing: &models.User{
+				Username: "alice", Email: "alice@example.com", Role: models.RoleUser,
+				Status: models.UserStatusActive, AuthSource: models.AuthSourceLocal,
+			},
+			groups:           []string{"library-staff"},
+			auditUnavailable: true,
+			wantRole:         models.RoleUser,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			svc, db := newTestOIDCService(t, idp, mappings)
+			if tt.existing != nil {
+				existing := *tt.existing
+				existing.Password = "stub:secret"
+				if err := db.Create(&existing).Error; err != nil {
+					t.Fatalf("创建已有账户失败: %v", err)
+				}
+			}
+			if tt.auditUnavailable {
+				if err := db.Migrator().DropTable(&models.AuditEvent{}); err != nil {
+					t.Fatalf("删除审计表失败: %v", err)
+				}
+			}
+
+			claims := aliceClaims()
+			claims["groups"] = tt.groups
+			auth, state := beginTestLogin(t, svc)
+			code := idp.authorize(t, auth.URL, claims)
+
+			result, err := svc.CompleteLogin(context.Background(), auth.StateCookie, state, code, ClientInfo{})
+			if err != nil {
+				t.Fatalf("单点登录失败: %v", err)
+			}
+			if result.User.Role != tt.wantRole {
+				t.Errorf("登录结果中的角色 = %s，期望 %s", result.User.Role, tt.wantRole)
+			}
+
+			var stored models.User
+			if err := db.First(&stored, result.User.ID).Error; err != nil {
+				t.Fatalf("查询账户失败: %v", err)
+			}
+			if stored.Role != tt.wantRole {
+				t.Errorf("数据库中的角色 = %s，期望 %s", stored.Role, tt.wantRole)
+			}
+
+			// 已有账户的角色发生变化时以身份源的名义留下审计记录
+			if tt.existing != nil && !tt.auditUnavailable {
+				var events int64
+				if err := db.Model(&models.AuditEvent{}).
+					Where("action = ? AND actor_name = ?", models.AuditUserRoleAssign, models.AuthSourceOIDC).
+					Count(&events).Error; err != nil {
+					t.Fatalf("查询审计记录失败: %v", err)
+				}
+				if wantAudit := tt.existing.Role != tt.wantRole; (events > 0) != wantAudit {
+					t.Errorf("角色同步审计记录数 = %d，期望有记录: %v", events, wantAudit)
+				}
+			}
+		})
+	}
+}
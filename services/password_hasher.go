@@ -0,0 +1,226 @@
+package services
+
+import (
+	"book-management-system/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+// PasswordHasher 密码哈希器
+// Hash 生成带算法标识的哈希串；Verify 可以校验任意受支持算法生成的密码，开启 ALLOW_LEGACY_PLAINTEXT 时还接受历史明文；
+// NeedsRehash 判断已存储的哈希是否需要按当前算法和参数重新生成
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2Params argon2id 参数
+type Argon2Params struct {
+	Memory      uint32 // 内存开销，单位KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewPasswordHasher 根据配置创建密码哈希器
+func NewPasswordHasher() PasswordHasher {
+	cfg := config.AppConfig
+	switch cfg.PasswordHashAlgorithm {
+	case HashAlgorithmArgon2id:
+		return NewArgon2idHasher(Argon2Params{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		})
+	default:
+		return NewBcryptHasher(cfg.BcryptCost)
+	}
+}
+
+// ---------------- bcrypt ----------------
+
+type bcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) PasswordHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("密码加密失败: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(encoded, password string) (bool, error) {
+	return verifyPassword(encoded, password)
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	if !isBcryptHash(encoded) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+// ---------------- argon2id ----------------
+
+type argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) PasswordHasher {
+	if params.Memory == 0 {
+		params.Memory = 64 * 1024
+	}
+	if params.Iterations == 0 {
+		params.Iterations = 3
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = 2
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = 16
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = 32
+	}
+	return &argon2idHasher{params: params}
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("生成盐值失败: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	// PHC 字符串格式: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(encoded, password string) (bool, error) {
+	return verifyPassword(encoded, password)
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	if !isArgon2idHash(encoded) {
+		return true
+	}
+	params, salt, key, err := decodeArgon2idHash(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+// ---------------- 通用校验 ----------------
+
+// verifyPassword 根据哈希串前缀选择算法进行校验
+// 无法识别前缀的值视为迁移前遗留的明文密码，只有开启 ALLOW_LEGACY_PLAINTEXT 时才使用常量时间比较，登录成功后会被重新哈希；
+// 默认拒绝，避免数据库被写入任意字符串后可以直接用它登录
+func verifyPassword(encoded, password string) (bool, error) {
+	switch {
+	case isBcryptHash(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == nil {
+			return true, nil
+		}
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, fmt.Errorf("校验密码失败: %w", err)
+	case isArgon2idHash(encoded):
+		params, salt, key, err := decodeArgon2idHash(encoded)
+		if err != nil {
+			return false, err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1, nil
+	case config.AppConfig.AllowLegacyPlaintext:
+		return subtle.ConstantTimeCompare([]byte(encoded), []byte(password)) == 1, nil
+	default:
+		return false, nil
+	}
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func isArgon2idHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func decodeArgon2idHash(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("argon2id 哈希格式错误")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, errors.New("argon2id 哈希版本错误")
+	}
+	if version != argon2.Version {
+		return params, nil, nil, errors.New("不支持的 argon2 版本")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errors.New("argon2id 哈希参数错误")
+	}
+	// argon2 在并行度为0时会 panic
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errors.New("argon2id 哈希参数错误")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errors.New("argon2id 盐值格式错误")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errors.New("argon2id 哈希值格式错误")
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"book-management-system/config"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params 足够小的 argon2id 参数，让测试保持快速
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// mustHash 用指定哈希器生成密码哈希，失败时终止测试
+func mustHash(t *testing.T, h PasswordHasher, password string) string {
+	t.Helper()
+
+	encoded, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("生成密码哈希失败: %v", err)
+	}
+	return encoded
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	bcryptHasher := NewBcryptHasher(bcrypt.MinCost)
+	argon2Hasher := NewArgon2idHasher(testArgon2Params)
+	bcryptHash := mustHash(t, bcryptHasher, "secret")
+	argon2Hash := mustHash(t, argon2Hasher, "secret")
+
+	tests := []struct {
+		name     string
+		hasher   PasswordHasher
+		encoded  string
+		password string
+		// allowPlaintext 对应 ALLOW_LEGACY_PLAINTEXT
+		allowPlaintext bool
+		want           bool
+		wantErr        bool
+	}{
+		{name: "bcrypt 密码正确", hasher: bcryptHasher, encoded: bcryptHash, password: "secret", want: true},
+		{name: "bcrypt 密码错误", hasher: bcryptHasher, encoded: bcryptHash, password: "wrong"},
+		{name: "argon2id 密码正确", hasher: argon2Hasher, encoded: argon2Hash, password: "secret", want: true},
+		{name: "argon2id 密码错误", hasher: argon2Hasher, encoded: argon2Hash, password: "wrong"},
+		{name: "按前缀识别其他算法的哈希", hasher: bcryptHasher, encoded: argon2Hash, password: "secret", want: true},
+		{name: "默认拒绝历史明文", hasher: bcryptHasher, encoded: "secret", password: "secret"},
+		{name: "开启后接受历史明文", hasher: bcryptHasher, encoded: "secret", password: "secret", allowPlaintext: true, want: true},
+		{name: "开启后历史明文不匹配", hasher: argon2Hasher, encoded: "secret", password: "wrong", allowPlaintext: true},
+		{name: "开启后不把哈希串当作明文", hasher: bcryptHasher, encoded: bcryptHash, password: bcryptHash, allowPlaintext: true},
+		{name: "argon2id 格式错误", hasher: argon2Hasher, encoded: "$argon2id$v=19$m=1024", password: "secret", wantErr: true},
+		{name: "argon2id 并行度为0", hasher: argon2Hasher, encoded: "$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$a2V5", password: "secret", wantErr: true},
+		{name: "argon2id 版本不支持", hasher: argon2Hasher, encoded: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", password: "secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &config.Config{AllowLegacyPlaintext: tt.allowPlaintext})
+
+			got, err := tt.hasher.Verify(tt.encoded, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() 返回错误 %v，期望出错 %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify() = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	bcryptHasher := NewBcryptHasher(bcrypt.MinCost)
+	argon2Hasher := NewArgon2idHasher(testArgon2Params)
+
+	stronger := testArgon2Params
+	stronger.Memory *= 2
+	strongerArgon2Hasher := NewArgon2idHasher(stronger)
+
+	bcryptHash := mustHash(t, bcryptHasher, "secret")
+	argon2Hash := mustHash(t, argon2Hasher, "secret")
+
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{name: "bcrypt 成本相同", hasher: bcryptHasher, encoded: bcryptHash},
+		{name: "bcrypt 成本变化", hasher: NewBcryptHasher(bcrypt.MinCost + 1), encoded: bcryptHash, want: true},
+		{name: "bcrypt 切换到 argon2id", hasher: argon2Hasher, encoded: bcryptHash, want: true},
+		{name: "argon2id 参数相同", hasher: argon2Hasher, encoded: argon2Hash},
+		{name: "argon2id 参数变化", hasher: strongerArgon2Hasher, encoded: argon2Hash, want: true},
+		{name: "argon2id 切换到 bcrypt", hasher: bcryptHasher, encoded: argon2Hash, want: true},
+		{name: "argon2id 格式错误", hasher: argon2Hasher, encoded: "$argon2id$v=19$m=1024", want: true},
+		{name: "历史明文", hasher: bcryptHasher, encoded: "secret", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash() = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("重置链接无效或已过期")
+
+// PasswordResetService 通过邮件发送一次性重置令牌，帮助忘记密码的用户重新设置密码
+// 令牌明文只出现在邮件中，数据库只保存其SHA-256摘要
+type PasswordResetService interface {
+	RequestReset(email string)
+	ResetPassword(actor Actor, token, newPassword string) error
+}
+
+type passwordResetService struct {
+	userRepo    repositories.UserRepository
+	resetRepo   repositories.PasswordResetRepository
+	hasher      PasswordHasher
+	revocations TokenRevocationService
+	mailer      Mailer
+	guard       LoginGuard
+	audit       AuditService
+}
+
+func NewPasswordResetService(userRepo repositories.UserRepository, resetRepo repositories.PasswordResetRepository, hasher PasswordHasher, revocations TokenRevocationService, mailer Mailer, guard LoginGuard, audit AuditService) PasswordResetService {
+	return &passwordResetService{
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		hasher:      hasher,
+		revocations: revocations,
+		mailer:      mailer,
+		guard:       guard,
+		audit:       audit,
+	}
+}
+
+// RequestReset 为邮箱对应的账户发送重置邮件
+// 查询账户、生成令牌和发信都在后台完成，调用方无论邮箱是否存在都立即得到相同结果，
+// 避免通过响应内容或耗时探测账户是否存在
+func (s *passwordResetService) RequestReset(email string) {
+	go func() {
+		if err := s.sendReset(email); err != nil {
+			log.Printf("处理密码重置请求失败: %v", err)
+		}
+	}()
+}
+
+func (s *passwordResetService) sendReset(email string) error {
+	// 不论账户是否存在都计数，发往同一地址的邮件超出限制时静默丢弃
+	allowed, err := s.guard.AllowMailTo(email)
+	if err != nil || !allowed {
+		return err
+	}
+
+	// LDAP 账户的密码由目录管理，不发送重置邮件
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || user.Status == models.UserStatusSuspended || user.AuthSource == models.AuthSourceLDAP {
+		return nil
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := config.AppConfig.PasswordResetExpire
+	if err := s.resetRepo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppConfig.AppBaseURL, url.QueryEscape(token))
+	if err := s.mailer.Send(MailMessage{
+		To:      user.Email,
+		Subject: "重置您的密码",
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置密码的请求。请在 %v 内使用以下链接设置新密码，链接只能使用一次：\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会改变。\n",
+			user.Username, ttl, link),
+	}); err != nil {
+		return fmt.Errorf("发送密码重置邮件给用户 %d 失败: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码
+// 令牌核销与密码更新在同一事务中完成，成功后该用户的其他重置令牌全部作废，并撤销其所有已登录的会话
+func (s *passwordResetService) ResetPassword(actor Actor, token, newPassword string) error {
+	if len(newPassword) < 6 {
+		return errors.New("密码长度至少6个字符")
+	}
+
+	resetToken, err := s.resetRepo.FindByHash(utils.HashToken(token))
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.FindByID(resetToken.UserID)
+	if err != nil || user.Status == models.UserStatusSuspended {
+		return ErrInvalidResetToken
+	}
+
+	passwordHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// 请求未登录，重置令牌证明了操作者就是账户本人
+	actor.UserID = user.ID
+	actor.Username = user.Username
+	if err := s.audit.RecordSecurity(actor, models.AuditUserPasswordReset, models.AuditTargetUser, user.ID, nil, nil); err != nil {
+		return err
+	}
+
+	consumed, err := s.resetRepo.Consume(resetToken, passwordHash)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+
+	// 能收到重置邮件说明用户拥有该邮箱，等待验证的账户一并视为已验证
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+			return err
+		}
+	}
+
+	return s.revocations.RevokeAllForUser(user.ID)
+}
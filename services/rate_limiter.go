@@ -0,0 +1,26 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/repositories"
+	"time"
+)
+
+// RateLimiter 按令牌桶算法限制请求频率
+type RateLimiter interface {
+	// Allow 在 scope 下为 key 取一个令牌，不同 scope 的计数互不影响
+	Allow(scope, key string, limit config.RateLimit) (repositories.RateLimitResult, error)
+}
+
+type rateLimiter struct {
+	store repositories.RateLimitStore
+}
+
+func NewRateLimiter(store repositories.RateLimitStore) RateLimiter {
+	return &rateLimiter{store: store}
+}
+
+func (l *rateLimiter) Allow(scope, key string, limit config.RateLimit) (repositories.RateLimitResult, error) {
+	refillRate := float64(limit.Requests) / limit.Period.Seconds()
+	return l.store.Take(scope+":"+key, limit.Burst, refillRate, time.Now())
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，该登录会话已被撤销，请重新登录")
+)
+
+// RefreshTokenService 管理长期有效的不透明刷新令牌
+// 令牌明文只返回给客户端一次，数据库只保存其SHA-256摘要
+// 每个令牌族对应一条登录会话记录，撤销令牌族时同时撤销会话
+type RefreshTokenService interface {
+	Issue(userID uint, client ClientInfo) (*models.Session, string, error)
+	Rotate(refreshToken string, client ClientInfo) (*models.Session, string, error)
+	Revoke(refreshToken string) error
+	RevokeAllForUser(userID uint) error
+}
+
+type refreshTokenService struct {
+	tokenRepo   repositories.RefreshTokenRepository
+	sessionRepo repositories.SessionRepository
+	ttl         time.Duration
+}
+
+func NewRefreshTokenService(tokenRepo repositories.RefreshTokenRepository, sessionRepo repositories.SessionRepository, ttl time.Duration) RefreshTokenService {
+	return &refreshTokenService{tokenRepo: tokenRepo, sessionRepo: sessionRepo, ttl: ttl}
+}
+
+// Issue 为一次新的登录创建会话并签发刷新令牌，开启新的令牌族
+func (s *refreshTokenService) Issue(userID uint, client ClientInfo) (*models.Session, string, error) {
+	familyID, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		UserAgent:  truncate(client.UserAgent, 512),
+		Device:     utils.DescribeUserAgent(client.UserAgent),
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, "", err
+	}
+
+	raw, err := s.create(userID, familyID, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, raw, nil
+}
+
+// Rotate 使用刷新令牌换取新令牌，旧令牌立即失效，会话的到期时间随之顺延
+// 已使用或已撤销的令牌再次出现时，撤销整个令牌族
+func (s *refreshTokenService) Rotate(refreshToken string, client ClientInfo) (*models.Session, string, error) {
+	if refreshToken == "" {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	token, err := s.tokenRepo.FindByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		s.revokeFamily(token)
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.FindByFamily(token.FamilyID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	marked, err := s.tokenRepo.MarkUsed(token.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !marked {
+		// 并发请求中另一方已抢先使用该令牌
+		s.revokeFamily(token)
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	newToken, err := s.create(token.UserID, token.FamilyID, &token.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.sessionRepo.Extend(session.ID, client.IP, time.Now().Add(s.ttl)); err != nil {
+		log.Printf("更新会话 %d 失败: %v", session.ID, err)
+	}
+
+	return session, newToken, nil
+}
+
+// Revoke 撤销刷新令牌所在的整个令牌族及其会话（用于退出登录）
+func (s *refreshTokenService) Revoke(refreshToken string) error {
+	token, err := s.tokenRepo.FindByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		return ErrInvalidRefreshToken
+	}
+	if err := s.tokenRepo.RevokeFamily(token.FamilyID); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeByFamily(token.FamilyID)
+}
+
+func (s *refreshTokenService) RevokeAllForUser(userID uint) error {
+	if err := s.tokenRepo.RevokeAllByUser(userID); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAllByUser(userID)
+}
+
+func (s *refreshTokenService) create(userID uint, familyID string, parentID *uint) (string, error) {
+	raw, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(raw),
+		ParentID:  parentID,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+func (s *refreshTokenService) revokeFamily(token *models.RefreshToken) {
+	log.Printf("检测到刷新令牌重用: 用户 %d, 令牌族 %s", token.UserID, token.FamilyID)
+	if err := s.tokenRepo.RevokeFamily(token.FamilyID); err != nil {
+		log.Printf("撤销令牌族 %s 失败: %v", token.FamilyID, err)
+	}
+	if err := s.sessionRepo.RevokeByFamily(token.FamilyID); err != nil {
+		log.Printf("撤销令牌族 %s 的会话失败: %v", token.FamilyID, err)
+	}
+}
+
+// truncate 按字节截断过长的字符串，保证不超过数据库字段长度
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// mustRotate 轮换刷新令牌并返回新令牌，失败时终止测试
+func mustRotate(t *testing.T, s RefreshTokenService, refreshToken string) string {
+	t.Helper()
+
+	_, next, err := s.Rotate(refreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("轮换刷新令牌失败: %v", err)
+	}
+	return next
+}
+
+func TestRefreshTokenRotate(t *testing.T) {
+	tests := []struct {
+		name string
+		// present 在登录之后执行，返回本次提交的刷新令牌
+		present func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string
+		wantErr error
+		// wantFamilyRevoked 期望整个令牌族和会话被撤销
+		wantFamilyRevoked bool
+	}{
+		{
+			name: "正常轮换",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				return issued
+			},
+		},
+		{
+			name: "连续轮换使用最新令牌",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				return mustRotate(t, s, mustRotate(t, s, issued))
+			},
+		},
+		{
+			name: "重用已轮换的令牌撤销整个令牌族",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				mustRotate(t, s, mustRotate(t, s, issued))
+				return issued
+			},
+			wantErr:           ErrRefreshTokenReused,
+			wantFamilyRevoked: true,
+		},
+		{
+			name: "退出登录后再次使用",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				if err := s.Revoke(issued); err != nil {
+					t.Fatal(err)
+				}
+				return issued
+			},
+			wantErr:           ErrRefreshTokenReused,
+			wantFamilyRevoked: true,
+		},
+		{
+			name: "未知令牌不影响已有会话",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				return "unknown"
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "过期令牌",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				if err := db.Model(&models.RefreshToken{}).Where("family_id = ?", session.FamilyID).
+					Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+					t.Fatal(err)
+				}
+				return issued
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "会话已被撤销",
+			present: func(t *testing.T, db *gorm.DB, s RefreshTokenService, session *models.Session, issued string) string {
+				if err := repositories.NewSessionRepository().Revoke(session.ID); err != nil {
+					t.Fatal(err)
+				}
+				return issued
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			s := NewRefreshTokenService(repositories.NewRefreshTokenRepository(), repositories.NewSessionRepository(), time.Hour)
+
+			session, issued, err := s.Issue(1, ClientInfo{})
+			if err != nil {
+				t.Fatalf("签发刷新令牌失败: %v", err)
+			}
+			other, _, err := s.Issue(1, ClientInfo{})
+			if err != nil {
+				t.Fatalf("签发刷新令牌失败: %v", err)
+			}
+
+			_, next, err := s.Rotate(tt.present(t, db, s, session, issued), ClientInfo{})
+			if tt.wantErr == nil {
+				if err != nil || next == "" {
+					t.Fatalf("期望轮换成功，实际返回 %v", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("期望 %v，实际返回 %v", tt.wantErr, err)
+			}
+
+			var active int64
+			if err := db.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", session.FamilyID).
+				Count(&active).Error; err != nil {
+				t.Fatal(err)
+			}
+			var stored models.Session
+			if err := db.First(&stored, session.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantFamilyRevoked {
+				if active != 0 {
+					t.Errorf("令牌族中仍有 %d 个未撤销的令牌", active)
+				}
+				if stored.RevokedAt == nil {
+					t.Error("会话未被撤销")
+				}
+			} else if active == 0 {
+				t.Error("令牌族不应被撤销")
+			}
+
+			// 重用检测只影响被盗用的令牌族，同一用户的其他登录不受影响
+			var otherStored models.Session
+			if err := db.First(&otherStored, other.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if otherStored.RevokedAt != nil {
+				t.Error("其他会话不应被撤销")
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 角色权限缓存有效期，多实例部署时其他实例的修改最迟在此时间后生效
+const rolePermissionCacheTTL = time.Minute
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+// ErrRoleAuthority 操作者不能授予目标角色，也不能管理拥有该角色的用户
+var ErrRoleAuthority = errors.New("授予默认角色以外的角色或管理其用户需要 role:manage 权限")
+
+type RoleService interface {
+	EnsureDefaultRoles() error
+	GetAllRoles() ([]models.Role, error)
+	CreateRole(actor Actor, name models.UserRole, description string, permissions []models.Permission) (*models.Role, error)
+	UpdateRole(actor Actor, name models.UserRole, description string, permissions []models.Permission) (*models.Role, error)
+	DeleteRole(actor Actor, name models.UserRole) error
+	AssignRole(actor Actor, userID uint, role models.UserRole) error
+	AuthorizeRole(actor Actor, role models.UserRole) error
+	HasPermissions(role models.UserRole, permissions ...models.Permission) (bool, error)
+	RequiresTwoFactor(role models.UserRole) (bool, error)
+}
+
+type roleService struct {
+	roleRepo    repositories.RoleRepository
+	userRepo    repositories.UserRepository
+	revocations TokenRevocationService
+	audit       AuditService
+
+	mu       sync.RWMutex
+	cache    map[models.UserRole][]models.Permission
+	loadedAt time.Time
+}
+
+func NewRoleService(roleRepo repositories.RoleRepository, userRepo repositories.UserRepository, revocations TokenRevocationService, audit AuditService) RoleService {
+	return &roleService{
+		roleRepo:    roleRepo,
+		userRepo:    userRepo,
+		revocations: revocations,
+		audit:       audit,
+	}
+}
+
+// EnsureDefaultRoles 创建缺失的内置角色，已存在的角色保持不变
+func (s *roleService) EnsureDefaultRoles() error {
+	for _, role := range models.DefaultRoles() {
+		exists, err := s.roleRepo.ExistsByName(role.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if err := s.roleRepo.Create(&role); err != nil {
+			return fmt.Errorf("创建内置角色 %s 失败: %w", role.Name, err)
+		}
+	}
+
+	s.invalidateCache()
+	return nil
+}
+
+func (s *roleService) GetAllRoles() ([]models.Role, error) {
+	return s.roleRepo.FindAll()
+}
+
+func (s *roleService) CreateRole(actor Actor, name models.UserRole, description string, permissions []models.Permission) (*models.Role, error) {
+	if !roleNamePattern.MatchString(string(name)) {
+		return nil, errors.New("角色名只能包含小写字母、数字、下划线和短横线，长度2-50，且以字母开头")
+	}
+
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+	if err := s.audit.RecordSecurity(actor, models.AuditRoleCreate, models.AuditTargetRole, role.Name, nil, role); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.Create(role); err != nil {
+		return nil, err
+	}
+
+	s.invalidateCache()
+	return role, nil
+}
+
+func (s *roleService) UpdateRole(actor Actor, name models.UserRole, description string, permissions []models.Permission) (*models.Role, error) {
+	if name == models.RoleAdmin {
+		return nil, errors.New("管理员角色始终拥有全部权限，不能修改")
+	}
+
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	role, err := s.roleRepo.FindByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *role
+	role.Description = description
+	role.Permissions = permissions
+	if err := s.audit.RecordSecurity(actor, models.AuditRoleUpdate, models.AuditTargetRole, role.Name, before, role); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.Update(role); err != nil {
+		return nil, err
+	}
+
+	s.invalidateCache()
+	return role, nil
+}
+
+func (s *roleService) DeleteRole(actor Actor, name models.UserRole) error {
+	role, err := s.roleRepo.FindByName(name)
+	if err != nil {
+		return err
+	}
+
+	if role.BuiltIn {
+		return errors.New("内置角色不能删除")
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditRoleDelete, models.AuditTargetRole, role.Name, role, nil); err != nil {
+		return err
+	}
+	if err := s.roleRepo.Delete(name); err != nil {
+		return err
+	}
+
+	s.invalidateCache()
+	return nil
+}
+
+// AssignRole 为用户分配角色，并撤销其现有令牌使新角色立即生效
+func (s *roleService) AssignRole(actor Actor, userID uint, role models.UserRole) error {
+	exists, err := s.roleRepo.ExistsByName(role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("角色不存在")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == role {
+		return nil
+	}
+
+	if err := ensureAnotherAdmin(s.userRepo, user); err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserRoleAssign, models.AuditTargetUser, userID,
+		map[string]any{"role": user.Role}, map[string]any{"role": role}); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateRole(userID, role); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeAllForUser(userID)
+}
+
+// AuthorizeRole 检查操作者能否授予该角色，或停用、删除、重置拥有该角色的用户
+// 默认的读者角色只需 user:manage；其他角色还需要 role:manage，否则持有 user:manage 的自定义角色可以借此创建管理员或停用其他管理员
+func (s *roleService) AuthorizeRole(actor Actor, role models.UserRole) error {
+	if role == models.RoleUser || actor.system {
+		return nil
+	}
+
+	allowed, err := s.HasPermissions(actor.Role, models.PermissionRoleManage)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrRoleAuthority
+	}
+
+	// 使用 API 密钥时还要求密钥的作用范围包含 role:manage
+	if actor.Scopes != nil {
+		key := models.APIKey{Scopes: actor.Scopes}
+		if !key.HasScope(models.PermissionRoleManage) {
+			return ErrRoleAuthority
+		}
+	}
+	return nil
+}
+
+// HasPermissions 检查角色是否同时拥有全部指定权限
+func (s *roleService) HasPermissions(role models.UserRole, permissions ...models.Permission) (bool, error) {
+	granted, err := s.permissionsOf(role)
+	if err != nil {
+		return false, err
+	}
+
+	granted = append(granted[:len(granted):len(granted)], models.ImplicitPermissions...)
+	for _, required := range permissions {
+		found := false
+		for _, p := range granted {
+			if p == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RequiresTwoFactor 开启 ADMIN_2FA_REQUIRED 时，拥有任一管理类权限的角色必须启用两步验证，
+// 按权限而不是角色名判断，自定义的管理角色同样受约束
+func (s *roleService) RequiresTwoFactor(role models.UserRole) (bool, error) {
+	if !config.AppConfig.Admin2FARequired {
+		return false, nil
+	}
+
+	granted, err := s.permissionsOf(role)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range granted {
+		for _, admin := range models.AdminPermissions {
+			if p == admin {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *roleService) permissionsOf(role models.UserRole) ([]models.Permission, error) {
+	// 管理员角色始终拥有全部权限，包括后续版本新增的权限
+	if role == models.RoleAdmin {
+		return models.AllPermissions, nil
+	}
+
+	s.mu.RLock()
+	if s.cache != nil && time.Since(s.loadedAt) < rolePermissionCacheTTL {
+		permissions := s.cache[role]
+		s.mu.RUnlock()
+		return permissions, nil
+	}
+	s.mu.RUnlock()
+
+	roles, err := s.roleRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[models.UserRole][]models.Permission, len(roles))
+	for _, r := range roles {
+		cache[r.Name] = r.Permissions
+	}
+
+	s.mu.Lock()
+	s.cache = cache
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return cache[role], nil
+}
+
+func (s *roleService) invalidateCache() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+func validatePermissions(permissions []models.Permission) error {
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return fmt.Errorf("未知的权限: %s", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"log"
+	"time"
+)
+
+// sessionTouchInterval 会话最近活跃时间的更新间隔，避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrSessionNotFound = errors.New("会话不存在或已退出")
+	ErrSessionRevoked  = errors.New("会话已退出，请重新登录")
+)
+
+// ClientInfo 发起登录或请求的客户端信息
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionService 查看和管理用户在各设备上的登录会话
+type SessionService interface {
+	List(userID uint, spec repositories.QuerySpec) (*repositories.Page[models.Session], error)
+	Revoke(actor Actor, userID, sessionID uint) error
+	End(userID, sessionID uint) error
+	Validate(sessionID uint, client ClientInfo) error
+}
+
+type sessionService struct {
+	sessionRepo repositories.SessionRepository
+	tokenRepo   repositories.RefreshTokenRepository
+	audit       AuditService
+}
+
+func NewSessionService(sessionRepo repositories.SessionRepository, tokenRepo repositories.RefreshTokenRepository, audit AuditService) SessionService {
+	return &sessionService{sessionRepo: sessionRepo, tokenRepo: tokenRepo, audit: audit}
+}
+
+func (s *sessionService) List(userID uint, spec repositories.QuerySpec) (*repositories.Page[models.Session], error) {
+	return s.sessionRepo.FindActiveByUser(userID, spec)
+}
+
+// Revoke 用户本人或管理员退出指定会话，该会话的访问令牌和刷新令牌立即失效
+func (s *sessionService) Revoke(actor Actor, userID, sessionID uint) error {
+	session, err := s.activeSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserSessionRevoke, models.AuditTargetUser, userID, session, nil); err != nil {
+		return err
+	}
+	return s.end(session)
+}
+
+// End 用户退出登录时结束当前会话，属于正常登出，不写审计记录
+func (s *sessionService) End(userID, sessionID uint) error {
+	session, err := s.activeSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.end(session)
+}
+
+func (s *sessionService) activeSession(userID, sessionID uint) (*models.Session, error) {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *sessionService) end(session *models.Session) error {
+	if err := s.tokenRepo.RevokeFamily(session.FamilyID); err != nil {
+		return err
+	}
+	return s.sessionRepo.Revoke(session.ID)
+}
+
+// Validate 检查访问令牌所属的会话是否仍然有效，并记录最近活跃的时间和 IP
+func (s *sessionService) Validate(sessionID uint, client ClientInfo) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval || session.IP != client.IP {
+		if err := s.sessionRepo.Touch(session.ID, client.IP, now); err != nil {
+			log.Printf("更新会话 %d 的活跃时间失败: %v", session.ID, err)
+		}
+	}
+	return nil
+}
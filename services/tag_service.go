@@ -0,0 +1,136 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	maxTagNameRunes = 50
+	maxBookTags     = 20
+)
+
+type TagService interface {
+	SearchTags(query string, spec repositories.QuerySpec) (*repositories.Page[models.TagCount], error)
+	GetTagBooks(id uint, spec repositories.QuerySpec) (*repositories.Page[models.Book], error)
+	RenameTag(actor Actor, id uint, name string) (*models.Tag, error)
+	DeleteTag(actor Actor, id uint) error
+	ResolveTags(names []string) ([]models.Tag, error)
+}
+
+type tagService struct {
+	tagRepo repositories.TagRepository
+	audit   AuditService
+}
+
+func NewTagService(tagRepo repositories.TagRepository, audit AuditService) TagService {
+	return &tagService{tagRepo: tagRepo, audit: audit}
+}
+
+func (s *tagService) SearchTags(query string, spec repositories.QuerySpec) (*repositories.Page[models.TagCount], error) {
+	return s.tagRepo.Search(strings.TrimSpace(query), spec)
+}
+
+func (s *tagService) GetTagBooks(id uint, spec repositories.QuerySpec) (*repositories.Page[models.Book], error) {
+	if _, err := s.tagRepo.FindByID(id); err != nil {
+		return nil, err
+	}
+	return s.tagRepo.FindBooks(id, spec)
+}
+
+// RenameTag 修改标签名称，新名称与其他标签相同时把本标签合并到该标签，返回改名或合并后的标签
+func (s *tagService) RenameTag(actor Actor, id uint, name string) (*models.Tag, error) {
+	tag, err := s.tagRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *tag
+
+	name, err = normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	key := models.TagKey(name)
+
+	if existing, err := s.tagRepo.FindByKey(key); err == nil && existing.ID != id {
+		if err := s.tagRepo.Merge(tag, existing); err != nil {
+			return nil, err
+		}
+		s.audit.Record(actor, models.AuditTagMerge, models.AuditTargetTag, id, before, existing)
+		return existing, nil
+	}
+
+	tag.Name, tag.NameKey = name, key
+	if err := s.tagRepo.Update(tag); err != nil {
+		return nil, fmt.Errorf("更新标签失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditTagUpdate, models.AuditTargetTag, id, before, tag)
+	return tag, nil
+}
+
+func (s *tagService) DeleteTag(actor Actor, id uint) error {
+	tag, err := s.tagRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tagRepo.Delete(id); err != nil {
+		return fmt.Errorf("删除标签失败: %w", err)
+	}
+
+	s.audit.Record(actor, models.AuditTagDelete, models.AuditTargetTag, id, tag, nil)
+	return nil
+}
+
+// ResolveTags 按名称查找图书的标签，忽略空项和重复项，结果按名称排列
+// 不存在的标签只做规范化、不保存，其ID为 0，由图书仓库在写入图书的事务中创建
+func (s *tagService) ResolveTags(names []string) ([]models.Tag, error) {
+	unique := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		name, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if key := models.TagKey(name); !seen[key] {
+			seen[key] = true
+			unique = append(unique, name)
+		}
+	}
+	if len(unique) > maxBookTags {
+		return nil, fmt.Errorf("一种图书最多 %d 个标签", maxBookTags)
+	}
+
+	tags := make([]models.Tag, 0, len(unique))
+	for _, name := range unique {
+		key := models.TagKey(name)
+		tag, err := s.tagRepo.FindByKey(key)
+		if err != nil {
+			tag = &models.Tag{Name: name, NameKey: key}
+		}
+		tags = append(tags, *tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// normalizeTagName 去除两端空白并合并连续空白
+func normalizeTagName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", errors.New("标签名称不能为空")
+	}
+	if len([]rune(name)) > maxTagNameRunes {
+		return "", fmt.Errorf("标签名称不能超过 %d 个字符", maxTagNameRunes)
+	}
+	return name, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"time"
+)
+
+// TokenRevocationService 访问令牌撤销服务，由认证中间件在每次请求时检查
+type TokenRevocationService interface {
+	RevokeToken(claims *utils.Claims) error
+	RevokeAllForUser(userID uint) error
+	IsRevoked(claims *utils.Claims) (bool, error)
+}
+
+type tokenRevocationService struct {
+	revocationRepo repositories.TokenRevocationRepository
+	refreshTokens  RefreshTokenService
+}
+
+func NewTokenRevocationService(revocationRepo repositories.TokenRevocationRepository, refreshTokens RefreshTokenService) TokenRevocationService {
+	return &tokenRevocationService{revocationRepo: revocationRepo, refreshTokens: refreshTokens}
+}
+
+// RevokeToken 撤销单个访问令牌，记录保留到令牌自然过期为止
+func (s *tokenRevocationService) RevokeToken(claims *utils.Claims) error {
+	return s.revocationRepo.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
+// RevokeAllForUser 撤销用户当前持有的全部访问令牌和刷新令牌（退出所有设备）
+func (s *tokenRevocationService) RevokeAllForUser(userID uint) error {
+	if err := s.revocationRepo.RevokeUserTokens(userID, time.Now()); err != nil {
+		return err
+	}
+	return s.refreshTokens.RevokeAllForUser(userID)
+}
+
+func (s *tokenRevocationService) IsRevoked(claims *utils.Claims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := s.revocationRepo.IsTokenRevoked(claims.ID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	revokedBefore, err := s.revocationRepo.UserRevokedBefore(claims.UserID)
+	if err != nil {
+		return false, err
+	}
+	if revokedBefore.IsZero() {
+		return false, nil
+	}
+
+	// 按微秒比较，撤销后立即重新登录签发的令牌不受影响
+	if claims.IssuedAtMicros != 0 {
+		return claims.IssuedAtMicros <= revokedBefore.UnixMicro(), nil
+	}
+	// 缺少微秒签发时间的旧令牌只能按秒比较，同一秒内签发的也视为已撤销
+	return claims.IssuedAt != nil && claims.IssuedAt.Unix() <= revokedBefore.Unix(), nil
+}
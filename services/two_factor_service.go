@@ -0,0 +1,306 @@
+package services
+
+import (
+	"book-management-system/config"
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"book-management-system/utils"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew 允许前后各一个时间步（30秒）的时钟偏差
+	totpSkew = 1
+)
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("验证码错误")
+	ErrTwoFactorNotEnabled  = errors.New("尚未启用两步验证")
+)
+
+// TOTPEnrollment 开始绑定验证器时返回给用户的密钥信息
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatus 两步验证状态
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorService 基于 RFC 6238 TOTP 的两步验证
+type TwoFactorService interface {
+	Status(userID uint) (*TwoFactorStatus, error)
+	BeginEnrollment(userID uint) (*TOTPEnrollment, error)
+	ConfirmEnrollment(actor Actor, code string) ([]string, error)
+	Disable(actor Actor, password, code string) error
+	RegenerateRecoveryCodes(actor Actor, password, code string) ([]string, error)
+	VerifyCode(user *models.User, code string) error
+	Reset(actor Actor, userID uint) error
+}
+
+type twoFactorService struct {
+	userRepo     repositories.UserRepository
+	recoveryRepo repositories.RecoveryCodeRepository
+	hasher       PasswordHasher
+	roles        RoleService
+	revocations  TokenRevocationService
+	guard        LoginGuard
+	audit        AuditService
+}
+
+func NewTwoFactorService(userRepo repositories.UserRepository, recoveryRepo repositories.RecoveryCodeRepository, hasher PasswordHasher, roles RoleService, revocations TokenRevocationService, guard LoginGuard, audit AuditService) TwoFactorService {
+	return &twoFactorService{
+		userRepo:     userRepo,
+		recoveryRepo: recoveryRepo,
+		hasher:       hasher,
+		roles:        roles,
+		revocations:  revocations,
+		guard:        guard,
+		audit:        audit,
+	}
+}
+
+func (s *twoFactorService) Status(userID uint) (*TwoFactorStatus, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TOTPEnabled}
+	if user.TOTPEnabled {
+		if status.RecoveryCodesRemaining, err = s.recoveryRepo.CountUnused(userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginEnrollment 生成新的密钥，用户使用验证器扫码后需调用 ConfirmEnrollment 才会生效
+func (s *twoFactorService) BeginEnrollment(userID uint) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("已启用两步验证，如需更换验证器请先停用")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateTOTP(userID, secret, false); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    utils.TOTPURI(config.AppConfig.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment 校验验证器生成的第一个验证码后启用两步验证，并返回一组恢复码
+// 恢复码明文只在此时返回一次
+func (s *twoFactorService) ConfirmEnrollment(actor Actor, code string) ([]string, error) {
+	userID := actor.UserID
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("已启用两步验证")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("请先开始绑定验证器")
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserTwoFactorEnable, models.AuditTargetUser, userID,
+		map[string]any{"totp_enabled": false}, map[string]any{"totp_enabled": true}); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateTOTP(userID, user.TOTPSecret, true); err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.AdvanceTOTPStep(userID, step); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// Disable 停用两步验证，需要同时提供密码和验证码（或恢复码）
+func (s *twoFactorService) Disable(actor Actor, password, code string) error {
+	userID := actor.UserID
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	required, err := s.roles.RequiresTwoFactor(user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("拥有管理权限的账户必须启用两步验证，不能停用")
+	}
+
+	if err := s.verifyPasswordAndCode(user, password, code); err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserTwoFactorDisable, models.AuditTargetUser, userID,
+		map[string]any{"totp_enabled": true}, map[string]any{"totp_enabled": false}); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateTOTP(userID, "", false); err != nil {
+		return err
+	}
+	return s.recoveryRepo.DeleteByUser(userID)
+}
+
+// RegenerateRecoveryCodes 作废原有恢复码并生成新的一组，与停用一样需要同时提供密码和验证码
+func (s *twoFactorService) RegenerateRecoveryCodes(actor Actor, password, code string) ([]string, error) {
+	userID := actor.UserID
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verifyPasswordAndCode(user, password, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserRecoveryCodesRegenerate, models.AuditTargetUser, userID, nil, nil); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(userID)
+}
+
+// verifyPasswordAndCode 校验已登录用户在敏感操作前再次提交的密码和验证码
+// 失败与登录失败一样计入账户的失败次数并触发锁定，访问令牌泄露后也无法不受限制地猜测验证码
+func (s *twoFactorService) verifyPasswordAndCode(user *models.User, password, code string) error {
+	if err := s.guard.CheckAccount(user.Username); err != nil {
+		return err
+	}
+
+	valid, err := s.hasher.Verify(user.Password, password)
+	if err != nil || !valid {
+		s.recordFailure(user.Username)
+		return errors.New("密码错误")
+	}
+	if err := s.VerifyCode(user, code); err != nil {
+		s.recordFailure(user.Username)
+		return err
+	}
+
+	if err := s.guard.RecordSuccess(user.Username); err != nil {
+		log.Printf("清除用户 %d 的登录失败计数失败: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (s *twoFactorService) recordFailure(username string) {
+	if err := s.guard.RecordFailure(username); err != nil {
+		log.Printf("记录登录失败次数失败: %v", err)
+	}
+}
+
+// VerifyCode 校验验证码或恢复码，两者都只能使用一次
+func (s *twoFactorService) VerifyCode(user *models.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), totpSkew); ok {
+		advanced, err := s.userRepo.AdvanceTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return errors.New("验证码已被使用，请等待下一个验证码")
+		}
+		return nil
+	}
+
+	consumed, err := s.recoveryRepo.Consume(user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Reset 管理员为丢失验证器和恢复码的用户清除两步验证，并撤销其全部会话
+func (s *twoFactorService) Reset(actor Actor, userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserTwoFactorReset, models.AuditTargetUser, userID,
+		map[string]any{"totp_enabled": user.TOTPEnabled}, map[string]any{"totp_enabled": false}); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateTOTP(userID, "", false); err != nil {
+		return err
+	}
+	if err := s.recoveryRepo.DeleteByUser(userID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeAllForUser(userID)
+}
+
+func (s *twoFactorService) issueRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := s.recoveryRepo.ReplaceForUser(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode 生成形如 abcde-fghij 的恢复码
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"book-management-system/models"
+	"book-management-system/repositories"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// UserService 管理员对用户账户的管理操作
+type UserService interface {
+	CreateUser(actor Actor, username, password, email string, role models.UserRole) (*models.User, error)
+	SuspendUser(actor Actor, userID uint) error
+	ActivateUser(actor Actor, userID uint) error
+	DeleteUser(actor Actor, userID uint) error
+	RestoreUser(actor Actor, userID uint) (*models.User, error)
+	GetDeletedUsers(filter repositories.UserFilter, spec repositories.QuerySpec) (*repositories.Page[models.User], error)
+	UnlockUser(actor Actor, userID uint) error
+	ResetPassword(actor Actor, userID uint, password string) error
+	GetUserRole(userID uint) (models.UserRole, error)
+}
+
+type userService struct {
+	userRepo    repositories.UserRepository
+	roleRepo    repositories.RoleRepository
+	resetRepo   repositories.PasswordResetRepository
+	roles       RoleService
+	hasher      PasswordHasher
+	revocations TokenRevocationService
+	guard       LoginGuard
+	audit       AuditService
+}
+
+func NewUserService(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, resetRepo repositories.PasswordResetRepository, roles RoleService, hasher PasswordHasher, revocations TokenRevocationService, guard LoginGuard, audit AuditService) UserService {
+	return &userService{
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		resetRepo:   resetRepo,
+		roles:       roles,
+		hasher:      hasher,
+		revocations: revocations,
+		guard:       guard,
+		audit:       audit,
+	}
+}
+
+func (s *userService) CreateUser(actor Actor, username, password, email string, role models.UserRole) (*models.User, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	exists, err := s.roleRepo.ExistsByName(role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("角色不存在")
+	}
+	if err := s.roles.AuthorizeRole(actor, role); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// 管理员创建的账户视为邮箱已核实
+	verifiedAt := time.Now()
+	user := &models.User{
+		Username:        username,
+		Password:        passwordHash,
+		Email:           email,
+		EmailVerifiedAt: &verifiedAt,
+		Role:            role,
+		Status:          models.UserStatusActive,
+	}
+	if err := createAuditedUser(s.userRepo, s.audit, actor, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createAuditedUser 创建账户并写入审计记录，管理员创建和外部身份源首次登录创建的账户都经过这里
+// 新账户的ID在写入后才确定，审计记录无法写入时删除刚创建的账户
+func createAuditedUser(userRepo repositories.UserRepository, audit AuditService, actor Actor, user *models.User) error {
+	if err := userRepo.Create(user); err != nil {
+		return err
+	}
+
+	if err := audit.RecordSecurity(actor, models.AuditUserCreate, models.AuditTargetUser, user.ID, nil, user); err != nil {
+		if deleteErr := userRepo.Delete(user.ID); deleteErr != nil {
+			log.Printf("删除未能审计的新账户 %d 失败: %v", user.ID, deleteErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// SuspendUser 停用账户并撤销其全部会话，已签发的令牌会被认证中间件拒绝
+func (s *userService) SuspendUser(actor Actor, userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Status == models.UserStatusSuspended {
+		return errors.New("账户已处于停用状态")
+	}
+
+	if err := ensureAnotherAdmin(s.userRepo, user); err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserSuspend, models.AuditTargetUser, userID,
+		map[string]any{"status": user.Status}, map[string]any{"status": models.UserStatusSuspended}); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateStatus(userID, models.UserStatusSuspended); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeAllForUser(userID)
+}
+
+// ActivateUser 重新启用被停用的账户；等待验证邮箱的账户只能由用户本人完成验证
+func (s *userService) ActivateUser(actor Actor, userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	switch user.Status {
+	case models.UserStatusActive:
+		return errors.New("账户已处于启用状态")
+	case models.UserStatusPending:
+		return errors.New("账户尚未验证邮箱，不能直接启用")
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserActivate, models.AuditTargetUser, userID,
+		map[string]any{"status": user.Status}, map[string]any{"status": models.UserStatusActive}); err != nil {
+		return err
+	}
+	return s.userRepo.UpdateStatus(userID, models.UserStatusActive)
+}
+
+// DeleteUser 软删除用户，可通过 RestoreUser 恢复
+func (s *userService) DeleteUser(actor Actor, userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureAnotherAdmin(s.userRepo, user); err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserDelete, models.AuditTargetUser, userID, user, nil); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(userID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeAllForUser(userID)
+}
+
+// RestoreUser 恢复已软删除的用户，先确认用户确实处于删除状态再写审计记录
+func (s *userService) RestoreUser(actor Actor, userID uint) (*models.User, error) {
+	if _, err := s.userRepo.FindDeletedByID(userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserRestore, models.AuditTargetUser, userID,
+		map[string]any{"deleted": true}, map[string]any{"deleted": false}); err != nil {
+		return nil, err
+	}
+	return s.userRepo.Restore(userID)
+}
+
+func (s *userService) GetDeletedUsers(filter repositories.UserFilter, spec repositories.QuerySpec) (*repositories.Page[models.User], error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	return s.userRepo.FindDeleted(filter, spec)
+}
+
+// UnlockUser 解除因连续登录失败造成的账户锁定
+func (s *userService) UnlockUser(actor Actor, userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserUnlock, models.AuditTargetUser, userID, nil, nil); err != nil {
+		return err
+	}
+	return s.guard.UnlockAccount(user.Username)
+}
+
+// ResetPassword 管理员直接为用户设置新密码
+// 与邮件重置一样作废未使用的重置令牌、撤销全部 API 密钥和会话，并解除登录锁定
+func (s *userService) ResetPassword(actor Actor, userID uint, password string) error {
+	if len(password) < 6 {
+		return errors.New("密码长度至少6个字符")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.AuthSource == models.AuthSourceLDAP {
+		return errors.New("LDAP 账户的密码需要在目录服务中修改")
+	}
+
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.audit.RecordSecurity(actor, models.AuditUserPasswordReset, models.AuditTargetUser, userID, nil, nil); err != nil {
+		return err
+	}
+	if err := s.resetRepo.Reset(userID, passwordHash); err != nil {
+		return err
+	}
+
+	// 内存撤销存储只对当前进程有效，刷新令牌始终在数据库中撤销
+	if err := s.revocations.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.guard.UnlockAccount(user.Username)
+}
+
+// GetUserRole 查询用户的角色，已软删除的用户也能查到
+func (s *userService) GetUserRole(userID uint) (models.UserRole, error) {
+	return s.userRepo.FindRole(userID)
+}
+
+// ensureAnotherAdmin 当用户是管理员时，确保除其之外至少还有一个可用的管理员
+// 这里只用于在写入审计记录之前尽早拒绝，并发操作之间的最终检查由仓库在更新用户的事务中完成
+func ensureAnotherAdmin(userRepo repositories.UserRepository, user *models.User) error {
+	if user.Role != models.RoleAdmin || user.Status != models.UserStatusActive {
+		return nil
+	}
+
+	adminCount, err := userRepo.CountActiveByRole(models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if adminCount <= 1 {
+		return repositories.ErrLastAdmin
+	}
+	return nil
+}
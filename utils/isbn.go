@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidISBN = errors.New("ISBN 格式或校验位不正确")
+
+// NormalizeISBN 校验 ISBN-10 或 ISBN-13 并统一转换为不带连字符的 ISBN-13
+// 同一本书的 ISBN-10 和 ISBN-13 会得到相同的结果，便于查重
+func NormalizeISBN(raw string) (string, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	for _, prefix := range []string{"ISBN-13", "ISBN-10", "ISBN13", "ISBN10", "ISBN"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			s = strings.TrimLeft(rest, ": ")
+			break
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case r >= '0' && r <= '9', r == 'X':
+			b.WriteRune(r)
+		default:
+			return "", ErrInvalidISBN
+		}
+	}
+	isbn := b.String()
+
+	switch len(isbn) {
+	case 10:
+		if !validISBN10(isbn) {
+			return "", ErrInvalidISBN
+		}
+		// ISBN-10 对应 978 前缀的 ISBN-13，需要重新计算校验位
+		body := "978" + isbn[:9]
+		return body + string(isbn13CheckDigit(body)), nil
+	case 13:
+		if strings.ContainsRune(isbn, 'X') || !(strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979")) {
+			return "", ErrInvalidISBN
+		}
+		if isbn13CheckDigit(isbn[:12]) != isbn[12] {
+			return "", ErrInvalidISBN
+		}
+		return isbn, nil
+	}
+	return "", ErrInvalidISBN
+}
+
+// validISBN10 加权和（权重 10 到 1）能被 11 整除，校验位 X 表示 10
+func validISBN10(isbn string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := isbn[i]
+		var digit int
+		switch {
+		case c == 'X' && i == 9:
+			digit = 10
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		default:
+			return false
+		}
+		sum += digit * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// isbn13CheckDigit 计算前12位的校验位，奇数位权重为1，偶数位权重为3
+func isbn13CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		digit := int(body[i] - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
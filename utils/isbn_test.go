@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		// want 为空时期望返回 ErrInvalidISBN
+		want string
+	}{
+		{name: "ISBN-13 原样返回", raw: "9780306406157", want: "9780306406157"},
+		{name: "去掉连字符和空格", raw: " 978-0-306 40615-7 ", want: "9780306406157"},
+		{name: "去掉 ISBN 前缀", raw: "ISBN-13: 978-0-306-40615-7", want: "9780306406157"},
+		{name: "979 前缀", raw: "979-10-90636-07-1", want: "9791090636071"},
+		{name: "ISBN-10 转换为 ISBN-13", raw: "0-306-40615-2", want: "9780306406157"},
+		{name: "ISBN-10 带前缀", raw: "ISBN 0306406152", want: "9780306406157"},
+		{name: "ISBN-10 校验位 X", raw: "0-8044-2957-X", want: "9780804429573"},
+		{name: "ISBN-10 小写校验位 x", raw: "080442957x", want: "9780804429573"},
+		{name: "ISBN-10 校验位错误", raw: "0306406153"},
+		{name: "ISBN-10 中间出现 X", raw: "03064X6152"},
+		{name: "ISBN-13 校验位错误", raw: "9780306406158"},
+		{name: "ISBN-13 不允许 X", raw: "978030640615X"},
+		{name: "ISBN-13 前缀不是 978 或 979", raw: "9770306406158"},
+		{name: "包含其他字符", raw: "97803064A6157"},
+		{name: "长度不正确", raw: "978030640615"},
+		{name: "空字符串", raw: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeISBN(tt.raw)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Fatalf("NormalizeISBN(%q) = %q, %v，期望 ErrInvalidISBN", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeISBN(%q) = %q, %v，期望 %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
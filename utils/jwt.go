@@ -3,134 +3,167 @@ package utils
 import (
 	"book-management-system/config"
 	"book-management-system/models"
+	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenInvalid = errors.New("令牌无效")
+	ErrTokenExpired = errors.New("令牌已过期")
 )
 
 // Claims 结构体定义JWT的声明（payload）部分
-// 继承jwt.StandardClaims，包含了JWT标准声明如过期时间、签发时间等
+// 内嵌jwt.RegisteredClaims，包含签发者(iss)、受众(aud)、主体(sub)、过期时间(exp)、
+// 生效时间(nbf)、签发时间(iat)和令牌ID(jti)等标准声明
 // UserID: 用户ID，用于标识用户身份
 // Role: 用户角色，使用models.UserRole类型，用于权限控制
+// MFA: 令牌是否来自通过两步验证的登录
+// SessionID: 令牌所属的登录会话，会话撤销后令牌立即失效
+// IssuedAtMicros: 微秒精度的签发时间，iat 只精确到秒，无法区分与撤销操作同一秒内签发的令牌
 type Claims struct {
-	UserID uint            `json:"user_id"`
-	Role   models.UserRole `json:"role"`
-	jwt.StandardClaims
+	UserID         uint            `json:"user_id"`
+	Role           models.UserRole `json:"role"`
+	MFA            bool            `json:"mfa,omitempty"`
+	SessionID      uint            `json:"sid,omitempty"`
+	IssuedAtMicros int64           `json:"iat_us,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenService 访问令牌的签发与校验
+type TokenService interface {
+	// GenerateToken 使用当前签名密钥为用户签发属于指定会话的访问令牌
+	GenerateToken(user *models.User, sessionID uint) (string, error)
+	// ValidateToken 校验签名、签名算法、签发者、受众以及过期和生效时间
+	ValidateToken(tokenString string) (*Claims, error)
+	// SigningKey 返回当前签名密钥
+	SigningKey() *SigningKey
+	// JWKS 返回可公开的校验公钥
+	JWKS() JWKS
+}
+
+// TokenOptions 令牌的标准声明和校验参数
+type TokenOptions struct {
+	Issuer   string
+	Audience []string
+	Expire   time.Duration
+	// Leeway 校验 exp、nbf、iat 时允许的时钟偏差
+	Leeway time.Duration
+}
+
+type tokenService struct {
+	keys   *KeySet
+	opts   TokenOptions
+	parser *jwt.Parser
+}
+
+// NewTokenService 创建令牌服务，解析器只接受密钥集合中出现过的签名算法
+func NewTokenService(keys *KeySet, opts TokenOptions) TokenService {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(keys.Algorithms()),
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if opts.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(opts.Issuer))
+	}
+	if len(opts.Audience) > 0 {
+		parserOptions = append(parserOptions, jwt.WithAudience(opts.Audience...))
+	}
+
+	return &tokenService{
+		keys:   keys,
+		opts:   opts,
+		parser: jwt.NewParser(parserOptions...),
+	}
 }
 
-// GenerateToken 生成JWT令牌的函数
-// 参数: user - 用户模型指针，包含用户ID和角色信息
-// 返回值:
-//
-//	string - 生成的JWT令牌字符串
-//	error - 错误信息，生成成功时为nil
-//
-// 功能: 根据用户信息生成一个有过期时间的JWT令牌
-func GenerateToken(user *models.User) (string, error) {
-	// 计算令牌的过期时间：当前时间 + 配置中指定的过期时长
-	expirationTime := time.Now().Add(config.AppConfig.JWTExpire)
-
-	// 创建Claims声明对象，包含自定义声明和标准声明
+// NewTokenServiceFromConfig 根据配置加载签名密钥并创建令牌服务
+func NewTokenServiceFromConfig(cfg *config.Config) (TokenService, error) {
+	keys, err := LoadKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTokenService(keys, TokenOptions{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Expire:   cfg.JWTExpire,
+		Leeway:   cfg.JWTLeeway,
+	}), nil
+}
+
+func (s *tokenService) GenerateToken(user *models.User, sessionID uint) (string, error) {
+	// 生成唯一的令牌ID（jti），用于服务端撤销单个令牌
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,   // 设置用户ID
-		Role:   user.Role, // 设置用户角色
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(), // 设置过期时间（Unix时间戳）
-			IssuedAt:  time.Now().Unix(),     // 设置签发时间（Unix时间戳）
+		UserID: user.ID,
+		Role:   user.Role,
+		// 启用两步验证的账户只能通过验证码登录，其令牌均视为已通过两步验证
+		MFA:            user.TOTPEnabled,
+		SessionID:      sessionID,
+		IssuedAtMicros: now.UnixMicro(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    s.opts.Issuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  s.opts.Audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.opts.Expire)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	// 使用HS256签名方法和自定义声明创建JWT令牌
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// 使用当前签名密钥的算法创建JWT令牌，并在头部写入 kid，便于校验方选择公钥
+	signing := s.keys.SigningKey()
+	token := jwt.NewWithClaims(signing.Method, claims)
+	token.Header["kid"] = signing.KID
 
-	// 使用配置中的密钥对令牌进行签名，返回签名后的令牌字符串
-	return token.SignedString([]byte(config.AppConfig.JWTSecret))
+	return token.SignedString(signing.Key)
 }
 
-// ValidateToken 验证JWT令牌的函数
-// 参数: tokenString - 需要验证的JWT令牌字符串
-// 返回值:
-//
-//	*Claims - 解析成功后的声明对象指针
-//	error - 错误信息，验证成功时为nil
-//
-// 功能: 验证JWT令牌的有效性，包括签名验证和过期时间检查
-func ValidateToken(tokenString string) (*Claims, error) {
-	// 检查token的段数
-	segments := strings.Split(tokenString, ".")
-	if len(segments) != 3 {
-		return nil, fmt.Errorf("token contains an invalid number of segments: %d", len(segments))
-	}
-	// 解析并验证令牌
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		// 验证回调函数，返回用于验证签名的密钥
-		// 这里使用配置中的JWT密钥
-		return []byte(config.AppConfig.JWTSecret), nil
+func (s *tokenService) ValidateToken(tokenString string) (*Claims, error) {
+	token, err := s.parser.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
+		// 按头部的 kid 选择校验密钥
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("未知的签名密钥: %q", kid)
+		}
+
+		// 固定签名算法，防止 alg 替换攻击（如用公钥作为 HMAC 密钥）
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("签名算法不匹配: %s", token.Method.Alg())
+		}
+		return key.Key, nil
 	})
-
-	// 如果解析过程中出现错误（如令牌格式错误、签名无效、已过期等），返回错误
 	if err != nil {
-		return nil, err
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
 	}
 
-	// 类型断言，将令牌的声明部分转换为自定义的Claims类型
-	// 同时检查令牌是否有效（token.Valid）
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		// 令牌有效，返回解析出的声明信息
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrTokenInvalid
 	}
+	return claims, nil
+}
 
-	// 令牌无效（可能是类型断言失败或token.Valid为false）
-	// 返回签名无效的错误
-	return nil, jwt.ErrSignatureInvalid
+func (s *tokenService) SigningKey() *SigningKey {
+	return s.keys.SigningKey()
 }
 
-// package utils
-
-// import (
-// 	"book-management-system/config"
-// 	"book-management-system/models"
-// 	"time"
-
-// 	"github.com/dgrijalva/jwt-go"
-// )
-
-// type Claims struct {
-// 	UserID uint            `json:"user_id"`
-// 	Role   models.UserRole `json:"role"`
-// 	jwt.StandardClaims
-// }
-
-// func GenerateToken(user *models.User) (string, error) {
-// 	expirationTime := time.Now().Add(config.AppConfig.JWTExpire)
-
-// 	claims := &Claims{
-// 		UserID: user.ID,
-// 		Role:   user.Role,
-// 		StandardClaims: jwt.StandardClaims{
-// 			ExpiresAt: expirationTime.Unix(),
-// 			IssuedAt:  time.Now().Unix(),
-// 		},
-// 	}
-
-// 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-// 	return token.SignedString([]byte(config.AppConfig.JWTSecret))
-// }
-
-// func ValidateToken(tokenString string) (*Claims, error) {
-// 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-// 		return []byte(config.AppConfig.JWTSecret), nil
-// 	})
-
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-// 		return claims, nil
-// 	}
-
-// 	return nil, jwt.ErrSignatureInvalid
-// }
+func (s *tokenService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"book-management-system/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// VerificationKey 用于校验令牌签名的密钥
+type VerificationKey struct {
+	KID    string
+	Method jwt.SigningMethod
+	Key    any // HS256 为 []byte，RS256 为 *rsa.PublicKey，EdDSA 为 ed25519.PublicKey
+}
+
+// SigningKey 当前用于签发令牌的密钥
+type SigningKey struct {
+	KID    string
+	Method jwt.SigningMethod
+	Key    any // HS256 为 []byte，RS256 为 *rsa.PrivateKey，EdDSA 为 ed25519.PrivateKey
+}
+
+// KeySet 一个签名密钥和多个校验密钥
+// 轮换密钥时，把旧密钥加入校验密钥列表，已签发的令牌在过期前仍然有效
+type KeySet struct {
+	signing      *SigningKey
+	verification map[string]*VerificationKey
+	order        []string
+}
+
+// JWK RFC 7517 JSON Web Key，只包含公钥参数
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeySet 根据配置构建密钥集合
+//   - HS256：使用 JWT_SECRET 签名，JWT_PREVIOUS_SECRETS 中的旧密钥仍可校验
+//   - RS256/EdDSA：使用 JWT_PRIVATE_KEY_FILE 签名，JWT_VERIFICATION_KEY_FILES 中的公钥（或私钥）仍可校验
+func LoadKeySet(cfg *config.Config) (*KeySet, error) {
+	ks := &KeySet{verification: make(map[string]*VerificationKey)}
+
+	switch cfg.JWTSigningAlgorithm {
+	case "HS256", "":
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET 不能为空")
+		}
+		secret := []byte(cfg.JWTSecret)
+		ks.signing = &SigningKey{KID: hmacKeyID(secret), Method: jwt.SigningMethodHS256, Key: secret}
+		ks.add(&VerificationKey{KID: ks.signing.KID, Method: jwt.SigningMethodHS256, Key: secret})
+
+		for _, previous := range cfg.JWTPreviousSecrets {
+			prev := []byte(previous)
+			ks.add(&VerificationKey{KID: hmacKeyID(prev), Method: jwt.SigningMethodHS256, Key: prev})
+		}
+
+	case "RS256", "EdDSA":
+		if cfg.JWTPrivateKeyFile == "" {
+			return nil, fmt.Errorf("使用 %s 签名时必须配置 JWT_PRIVATE_KEY_FILE", cfg.JWTSigningAlgorithm)
+		}
+		signing, err := loadSigningKey(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if signing.Method.Alg() != cfg.JWTSigningAlgorithm {
+			return nil, fmt.Errorf("私钥类型为 %s，与 JWT_SIGNING_ALG=%s 不一致", signing.Method.Alg(), cfg.JWTSigningAlgorithm)
+		}
+		ks.signing = signing
+
+		public, err := loadVerificationKey(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		ks.add(public)
+
+		for _, path := range cfg.JWTVerificationKeyFiles {
+			key, err := loadVerificationKey(path)
+			if err != nil {
+				return nil, err
+			}
+			ks.add(key)
+		}
+
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", cfg.JWTSigningAlgorithm)
+	}
+
+	return ks, nil
+}
+
+func (ks *KeySet) add(key *VerificationKey) {
+	if _, exists := ks.verification[key.KID]; exists {
+		return
+	}
+	ks.verification[key.KID] = key
+	ks.order = append(ks.order, key.KID)
+}
+
+// SigningKey 返回当前签名密钥
+func (ks *KeySet) SigningKey() *SigningKey {
+	return ks.signing
+}
+
+// Lookup 按 kid 查找校验密钥
+func (ks *KeySet) Lookup(kid string) (*VerificationKey, bool) {
+	key, ok := ks.verification[kid]
+	return key, ok
+}
+
+// Algorithms 返回所有校验密钥使用的签名算法
+func (ks *KeySet) Algorithms() []string {
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, kid := range ks.order {
+		alg := ks.verification[kid].Method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			algorithms = append(algorithms, alg)
+		}
+	}
+	return algorithms
+}
+
+// JWKS 返回所有非对称校验密钥的公钥，供其他服务校验令牌；HS256 密钥不会公开
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, kid := range ks.order {
+		if jwk, ok := publicJWK(ks.verification[kid]); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+func publicJWK(key *VerificationKey) (JWK, bool) {
+	switch pub := key.Key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: key.KID,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: key.KID,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true
+	}
+	return JWK{}, false
+}
+
+// hmacKeyID 对称密钥的 kid，取密钥摘要的前缀，不会泄露密钥本身
+func hmacKeyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return "hs-" + hex.EncodeToString(sum[:8])
+}
+
+// thumbprint 按 RFC 7638 计算公钥指纹作为 kid，同一把密钥在各实例上得到相同的 kid
+func thumbprint(public any) (string, error) {
+	var members any
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		}
+	case ed25519.PublicKey:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{Crv: "Ed25519", Kty: "OKP", X: base64.RawURLEncoding.EncodeToString(pub)}
+	default:
+		return "", errors.New("不支持的公钥类型")
+	}
+
+	raw, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func loadSigningKey(path string) (*SigningKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var private any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s 不是私钥: %s", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥 %s 失败: %w", path, err)
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s 中的私钥类型不受支持", path)
+	}
+	method, err := methodForKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	kid, err := thumbprint(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{KID: kid, Method: method, Key: private}, nil
+}
+
+// loadVerificationKey 从公钥或私钥 PEM 文件中取出公钥
+func loadVerificationKey(path string) (*VerificationKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var public any
+	switch block.Type {
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "RSA PRIVATE KEY", "PRIVATE KEY":
+		var signing *SigningKey
+		if signing, err = loadSigningKey(path); err == nil {
+			public = signing.Key.(crypto.Signer).Public()
+		}
+	default:
+		return nil, fmt.Errorf("%s 不是公钥: %s", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥 %s 失败: %w", path, err)
+	}
+
+	method, err := methodForKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	kid, err := thumbprint(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerificationKey{KID: kid, Method: method, Key: public}, nil
+}
+
+func methodForKey(public any) (jwt.SigningMethod, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, errors.New("只支持 RSA 和 Ed25519 密钥")
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是有效的 PEM 文件", path)
+	}
+	return block, nil
+}
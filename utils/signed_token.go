@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"book-management-system/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSignedTokenInvalid = errors.New("令牌无效")
+	ErrSignedTokenExpired = errors.New("令牌已过期")
+)
+
+// signedTokenPayload 签名令牌的载荷，Purpose 用于区分不同用途，防止令牌被挪用
+type signedTokenPayload struct {
+	Purpose   string          `json:"p"`
+	ExpiresAt int64           `json:"exp"`
+	Data      json.RawMessage `json:"d"`
+}
+
+// SignToken 生成带用途和有效期的HMAC签名令牌，格式为 base64(载荷).base64(签名)
+// 适用于邮件链接等无需服务端存储的一次性场景
+func SignToken(purpose string, data any, ttl time.Duration) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("序列化令牌数据失败: %w", err)
+	}
+
+	payload, err := json.Marshal(signedTokenPayload{
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		Data:      raw,
+	})
+	if err != nil {
+		return "", fmt.Errorf("序列化令牌失败: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signPayload(purpose, encoded)), nil
+}
+
+// VerifySignedToken 校验签名、用途和有效期，并将数据解析到 out
+func VerifySignedToken(token, purpose string, out any) error {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return ErrSignedTokenInvalid
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, signPayload(purpose, encoded)) {
+		return ErrSignedTokenInvalid
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrSignedTokenInvalid
+	}
+
+	var payload signedTokenPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil || payload.Purpose != purpose {
+		return ErrSignedTokenInvalid
+	}
+
+	if time.Now().Unix() > payload.ExpiresAt {
+		return ErrSignedTokenExpired
+	}
+
+	if err := json.Unmarshal(payload.Data, out); err != nil {
+		return ErrSignedTokenInvalid
+	}
+	return nil
+}
+
+func signPayload(purpose, encoded string) []byte {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "strings"
+
+// 按顺序匹配，Edge、Opera 的 UA 中同时包含 Chrome，必须先于 Chrome 判断
+var userAgentClients = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"PostmanRuntime/", "Postman"},
+	{"curl/", "curl"},
+	{"python-requests/", "Python"},
+	{"Go-http-client/", "Go"},
+}
+
+var userAgentPlatforms = []struct{ token, name string }{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// DescribeUserAgent 从 User-Agent 中提取简短的设备描述，例如 "Chrome on Windows"
+func DescribeUserAgent(userAgent string) string {
+	var client, platform string
+	for _, c := range userAgentClients {
+		if strings.Contains(userAgent, c.token) {
+			client = c.name
+			break
+		}
+	}
+	for _, p := range userAgentPlatforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case client != "" && platform != "":
+		return client + " on " + platform
+	case client != "":
+		return client
+	case platform != "":
+		return platform
+	}
+	return "未知设备"
+}